@@ -0,0 +1,302 @@
+// Package acs builds AWS SDK for Go v2 clients for Accelerated Cloud Storage (ACS)
+// and other S3-compatible endpoints.
+//
+// The only change needed to use ACS is a custom base endpoint and the matching
+// addressing style; LoadConfig resolves those once so S3 and IAM clients built
+// from the same Config agree on endpoint, region and credentials:
+//
+//	cfg, err := acs.LoadConfig(ctx, acs.WithRegion("global"), acs.WithAddressingStyle(acs.Auto))
+//	if err != nil {
+//		return err
+//	}
+//	s3Client := cfg.NewS3Client()
+package acs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Defaults applied when the corresponding option is not given.
+const (
+	DefaultEndpoint = "https://acceleratedprod.com"
+	DefaultRegion   = "global"
+)
+
+// AddressingStyle selects how bucket names are sent to the endpoint.
+type AddressingStyle string
+
+const (
+	// Virtual sends the bucket as a subdomain: https://<bucket>.<host>/<key>.
+	Virtual AddressingStyle = "virtual"
+	// Path sends the bucket as the first path segment: https://<host>/<bucket>/<key>.
+	Path AddressingStyle = "path"
+	// Auto picks virtual-hosted or path style per request (see Config.StyleFor).
+	Auto AddressingStyle = "auto"
+)
+
+// ParseAddressingStyle validates s as an addressing style.
+func ParseAddressingStyle(s string) (AddressingStyle, error) {
+	switch style := AddressingStyle(s); style {
+	case Virtual, Path, Auto:
+		return style, nil
+	default:
+		return "", &InvalidAddressingStyleError{Value: s}
+	}
+}
+
+// Option configures LoadConfig.
+type Option func(*options)
+
+type options struct {
+	endpoint    string
+	iamEndpoint string
+	region      string
+	iamRegion   string
+	style       AddressingStyle
+	credentials aws.CredentialsProvider
+	profile     string
+	httpClient  aws.HTTPClient
+	retryer     func() aws.Retryer
+	checksum    types.ChecksumAlgorithm
+	signing     PayloadSigning
+}
+
+// WithEndpoint sets the S3 endpoint URL. It must be absolute, e.g. "https://acceleratedprod.com".
+func WithEndpoint(endpoint string) Option { return func(o *options) { o.endpoint = endpoint } }
+
+// WithIAMEndpoint sets the IAM endpoint URL. It defaults to the S3 endpoint.
+func WithIAMEndpoint(endpoint string) Option { return func(o *options) { o.iamEndpoint = endpoint } }
+
+// WithRegion sets the signing region for S3 (and IAM, unless WithIAMRegion is given).
+func WithRegion(region string) Option { return func(o *options) { o.region = region } }
+
+// WithIAMRegion sets the signing region for IAM.
+func WithIAMRegion(region string) Option { return func(o *options) { o.iamRegion = region } }
+
+// WithAddressingStyle sets the bucket addressing style. It defaults to Virtual.
+func WithAddressingStyle(style AddressingStyle) Option { return func(o *options) { o.style = style } }
+
+// WithCredentials replaces the default credential chain.
+func WithCredentials(p aws.CredentialsProvider) Option {
+	return func(o *options) { o.credentials = p }
+}
+
+// WithSharedConfigProfile loads credentials from a named profile in the shared AWS config files.
+func WithSharedConfigProfile(name string) Option { return func(o *options) { o.profile = name } }
+
+// WithHTTPClient sets the HTTP client used by every client built from the Config.
+func WithHTTPClient(c aws.HTTPClient) Option { return func(o *options) { o.httpClient = c } }
+
+// WithRetryer sets the retryer factory used by every client built from the Config.
+func WithRetryer(fn func() aws.Retryer) Option { return func(o *options) { o.retryer = fn } }
+
+// WithChecksumAlgorithm makes S3 clients send a flexible checksum (CRC32,
+// CRC32C, SHA1 or SHA256) with every upload and copy that does not choose its
+// own, and validate the checksums returned on downloads. By default only the
+// SDK's defaults apply.
+func WithChecksumAlgorithm(alg types.ChecksumAlgorithm) Option {
+	return func(o *options) { o.checksum = alg }
+}
+
+// WithPayloadSigning sets how S3 clients sign PutObject and UploadPart bodies:
+// SignedPayload, UnsignedPayload or StreamingPayload. By default the SDK
+// decides.
+func WithPayloadSigning(mode PayloadSigning) Option {
+	return func(o *options) { o.signing = mode }
+}
+
+// Config is a resolved ACS connection configuration.
+type Config struct {
+	// AWS is the base SDK config: region, credentials, HTTP client and retryer.
+	AWS             aws.Config
+	Endpoint        string
+	IAMEndpoint     string
+	Region          string
+	IAMRegion       string
+	AddressingStyle AddressingStyle
+	// ChecksumAlgorithm is the default upload checksum, or "" for none.
+	ChecksumAlgorithm types.ChecksumAlgorithm
+	// PayloadSigning is how upload bodies are signed, or "" for the SDK default.
+	PayloadSigning PayloadSigning
+
+	auto *autoAddressing
+}
+
+// LoadConfig validates the options and loads the base AWS config. Invalid options
+// are reported together, joined into one error; use errors.As to inspect the
+// *InvalidEndpointError, *InvalidAddressingStyleError,
+// *InvalidChecksumAlgorithmError and *InvalidPayloadSigningError values, and
+// errors.Is for ErrMissingRegion.
+func LoadConfig(ctx context.Context, opts ...Option) (Config, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return Config{}, err
+	}
+
+	loadOpts := []func(*config.LoadOptions) error{config.WithRegion(o.region)}
+	if o.profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(o.profile))
+	}
+	if o.credentials != nil {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(o.credentials))
+	}
+	if o.httpClient != nil {
+		loadOpts = append(loadOpts, config.WithHTTPClient(o.httpClient))
+	}
+	if o.retryer != nil {
+		loadOpts = append(loadOpts, config.WithRetryer(o.retryer))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := Config{
+		AWS:               awsCfg,
+		Endpoint:          o.endpoint,
+		IAMEndpoint:       o.iamEndpoint,
+		Region:            o.region,
+		IAMRegion:         o.iamRegion,
+		AddressingStyle:   o.style,
+		ChecksumAlgorithm: o.checksum,
+		PayloadSigning:    o.signing,
+	}
+	if o.style == Auto {
+		c.auto = newAutoAddressing(o.endpoint)
+	}
+	return c, nil
+}
+
+func newOptions(opts []Option) options {
+	o := options{endpoint: DefaultEndpoint, region: DefaultRegion, style: Virtual}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.iamEndpoint == "" {
+		o.iamEndpoint = o.endpoint
+	}
+	if o.iamRegion == "" {
+		o.iamRegion = o.region
+	}
+	return o
+}
+
+// Validate reports every invalid option, as LoadConfig would, without loading any configuration.
+func Validate(opts ...Option) error {
+	return newOptions(opts).validate()
+}
+
+func (o options) validate() error {
+	var errs []error
+	if err := validateEndpoint("endpoint", o.endpoint); err != nil {
+		errs = append(errs, err)
+	}
+	if o.iamEndpoint != o.endpoint {
+		if err := validateEndpoint("iam_endpoint", o.iamEndpoint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if o.region == "" {
+		errs = append(errs, ErrMissingRegion)
+	}
+	if _, err := ParseAddressingStyle(string(o.style)); err != nil {
+		errs = append(errs, err)
+	}
+	if o.checksum != "" {
+		if alg, err := ParseChecksumAlgorithm(string(o.checksum)); err != nil || alg != o.checksum {
+			errs = append(errs, &InvalidChecksumAlgorithmError{Value: string(o.checksum)})
+		}
+	}
+	if _, err := ParsePayloadSigning(string(o.signing)); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// CheckCredentials retrieves credentials from the configured provider, returning
+// an error wrapping ErrNoCredentials when none can be found. The SDK caches the
+// result, so later requests do not pay for the lookup again.
+func (c Config) CheckCredentials(ctx context.Context) error {
+	if c.AWS.Credentials == nil {
+		return ErrNoCredentials
+	}
+	creds, err := c.AWS.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoCredentials, err)
+	}
+	if !creds.HasKeys() {
+		return ErrNoCredentials
+	}
+	return nil
+}
+
+// StyleFor returns the addressing style actually used for requests to bucket.
+// For Auto this is decided per bucket; the SDK always uses path style for IP endpoints.
+func (c Config) StyleFor(bucket string) AddressingStyle {
+	if c.auto != nil {
+		return c.auto.style(context.Background(), bucket)
+	}
+	if u, err := url.Parse(c.Endpoint); err == nil && net.ParseIP(u.Hostname()) != nil {
+		return Path
+	}
+	return c.AddressingStyle
+}
+
+// NewS3Client builds an S3 client for the configured endpoint and addressing style.
+// optFns are applied after the ACS settings.
+func (c Config) NewS3Client(optFns ...func(*s3.Options)) *s3.Client {
+	fns := append([]func(*s3.Options){func(o *s3.Options) {
+		// Set a custom base endpoint for S3-compatible services
+		o.BaseEndpoint = aws.String(c.Endpoint)
+		o.UsePathStyle = c.AddressingStyle == Path
+		o.Region = c.Region
+		if c.auto != nil {
+			o.EndpointResolverV2 = autoAddressingResolver{auto: c.auto, next: s3.NewDefaultEndpointResolverV2()}
+		}
+		if c.ChecksumAlgorithm != "" {
+			o.APIOptions = append(o.APIOptions, addChecksumDefaults(c.ChecksumAlgorithm))
+		}
+		if c.PayloadSigning != SDKPayloadSigning {
+			o.APIOptions = append(o.APIOptions, addPayloadSigning(c.PayloadSigning, endpointHTTPS(o)))
+		}
+	}}, optFns...)
+	return s3.NewFromConfig(c.AWS, fns...)
+}
+
+// NewIAMClient builds an IAM client for the configured IAM endpoint.
+// optFns are applied after the ACS settings.
+func (c Config) NewIAMClient(optFns ...func(*iam.Options)) *iam.Client {
+	fns := append([]func(*iam.Options){func(o *iam.Options) {
+		o.BaseEndpoint = aws.String(c.IAMEndpoint)
+		o.Region = c.IAMRegion
+	}}, optFns...)
+	return iam.NewFromConfig(c.AWS, fns...)
+}
+
+// NewS3Client is shorthand for LoadConfig followed by Config.NewS3Client.
+func NewS3Client(ctx context.Context, opts ...Option) (*s3.Client, error) {
+	c, err := LoadConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewS3Client(), nil
+}
+
+// NewIAMClient is shorthand for LoadConfig followed by Config.NewIAMClient.
+func NewIAMClient(ctx context.Context, opts ...Option) (*iam.Client, error) {
+	c, err := LoadConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewIAMClient(), nil
+}
@@ -0,0 +1,83 @@
+package acs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyendpoints "github.com/aws/smithy-go/endpoints"
+)
+
+// dnsBucket matches bucket names that can be used as a single DNS label.
+var dnsBucket = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+
+// autoAddressing decides between virtual-hosted and path style for each bucket.
+// Virtual-hosted style is used only when the bucket is a plain DNS label and the
+// endpoint host answers wildcard DNS; IP and localhost endpoints always use path style.
+type autoAddressing struct {
+	host string
+
+	once     sync.Once
+	wildcard bool
+}
+
+func newAutoAddressing(endpoint string) *autoAddressing {
+	a := &autoAddressing{}
+	if u, err := url.Parse(endpoint); err == nil {
+		a.host = u.Hostname()
+	}
+	return a
+}
+
+// style returns Virtual or Path for requests to bucket. An empty bucket
+// reports the endpoint-level decision.
+func (a *autoAddressing) style(ctx context.Context, bucket string) AddressingStyle {
+	if a.host == "" || net.ParseIP(a.host) != nil || a.host == "localhost" || strings.HasSuffix(a.host, ".localhost") {
+		return Path
+	}
+	if bucket != "" && (!dnsBucket.MatchString(bucket) || net.ParseIP(bucket) != nil) {
+		return Path
+	}
+	if !a.hasWildcardDNS(ctx) {
+		return Path
+	}
+	return Virtual
+}
+
+// hasWildcardDNS resolves a random subdomain of the endpoint host once and caches the answer.
+func (a *autoAddressing) hasWildcardDNS(ctx context.Context) bool {
+	a.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, "acs-probe-"+randomLabel()+"."+a.host)
+		a.wildcard = err == nil && len(addrs) > 0
+	})
+	return a.wildcard
+}
+
+// autoAddressingResolver wraps the S3 endpoint resolver, which the SDK's endpoint
+// middleware calls for every request, and forces path style per request when
+// autoAddressing rules out virtual-hosted style for that bucket.
+type autoAddressingResolver struct {
+	auto *autoAddressing
+	next s3.EndpointResolverV2
+}
+
+func (r autoAddressingResolver) ResolveEndpoint(ctx context.Context, params s3.EndpointParameters) (smithyendpoints.Endpoint, error) {
+	params.ForcePathStyle = aws.Bool(r.auto.style(ctx, aws.ToString(params.Bucket)) == Path)
+	return r.next.ResolveEndpoint(ctx, params)
+}
+
+func randomLabel() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
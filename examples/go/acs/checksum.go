@@ -0,0 +1,130 @@
+package acs
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// ChecksumAlgorithms are the flexible checksum algorithms S3 accepts on uploads.
+var ChecksumAlgorithms = []types.ChecksumAlgorithm{
+	types.ChecksumAlgorithmCrc32,
+	types.ChecksumAlgorithmCrc32c,
+	types.ChecksumAlgorithmSha1,
+	types.ChecksumAlgorithmSha256,
+}
+
+// ParseChecksumAlgorithm validates s, in any case, as a checksum algorithm.
+func ParseChecksumAlgorithm(s string) (types.ChecksumAlgorithm, error) {
+	for _, alg := range ChecksumAlgorithms {
+		if strings.EqualFold(s, string(alg)) {
+			return alg, nil
+		}
+	}
+	return "", &InvalidChecksumAlgorithmError{Value: s}
+}
+
+// NewChecksum returns a hash computing alg's checksum. An unknown algorithm
+// returns an *InvalidChecksumAlgorithmError.
+func NewChecksum(alg types.ChecksumAlgorithm) (hash.Hash, error) {
+	switch alg {
+	case types.ChecksumAlgorithmCrc32:
+		return crc32.NewIEEE(), nil
+	case types.ChecksumAlgorithmCrc32c:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	case types.ChecksumAlgorithmSha1:
+		return sha1.New(), nil
+	case types.ChecksumAlgorithmSha256:
+		return sha256.New(), nil
+	}
+	return nil, &InvalidChecksumAlgorithmError{Value: string(alg)}
+}
+
+// checksumDefaults fills in the configured algorithm on PutObject, UploadPart,
+// CreateMultipartUpload and CopyObject requests that do not name one, and
+// enables checksum validation on GetObject and HeadObject. It runs before the
+// SDK's checksum middleware, so the SDK computes and validates the checksums.
+// The caller's input is copied, never modified.
+type checksumDefaults struct {
+	algorithm types.ChecksumAlgorithm
+}
+
+func (checksumDefaults) ID() string { return "ACSChecksumDefaults" }
+
+func (m checksumDefaults) HandleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	switch p := in.Parameters.(type) {
+	case *s3.PutObjectInput:
+		if p.ChecksumAlgorithm == "" {
+			c := *p
+			c.ChecksumAlgorithm = m.algorithm
+			in.Parameters = &c
+		}
+	case *s3.UploadPartInput:
+		if p.ChecksumAlgorithm == "" {
+			c := *p
+			c.ChecksumAlgorithm = m.algorithm
+			in.Parameters = &c
+		}
+	case *s3.CreateMultipartUploadInput:
+		if p.ChecksumAlgorithm == "" {
+			c := *p
+			c.ChecksumAlgorithm = m.algorithm
+			in.Parameters = &c
+		}
+	case *s3.CopyObjectInput:
+		if p.ChecksumAlgorithm == "" {
+			c := *p
+			c.ChecksumAlgorithm = m.algorithm
+			in.Parameters = &c
+		}
+	case *s3.GetObjectInput:
+		if p.ChecksumMode == "" {
+			c := *p
+			c.ChecksumMode = types.ChecksumModeEnabled
+			in.Parameters = &c
+		}
+	case *s3.HeadObjectInput:
+		if p.ChecksumMode == "" {
+			c := *p
+			c.ChecksumMode = types.ChecksumModeEnabled
+			in.Parameters = &c
+		}
+	}
+	return next.HandleInitialize(ctx, in)
+}
+
+// presigning reports whether stack presigns a request rather than sending it,
+// which the SDK's presign client does by putting its presigner in place of the
+// signer. A presigned URL is used with a body the client never sees, so it
+// must not carry a checksum or payload hash computed here.
+func presigning(stack *middleware.Stack) bool {
+	_, ok := stack.Finalize.Get((*v4.PresignHTTPRequestMiddleware)(nil).ID())
+	return ok
+}
+
+// UseChecksumAlgorithm returns a per-client or per-request S3 option that
+// replaces the client's default checksum (see WithChecksumAlgorithm) with alg,
+// or drops it when alg is "".
+func UseChecksumAlgorithm(alg types.ChecksumAlgorithm) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, addChecksumDefaults(alg))
+	}
+}
+
+func addChecksumDefaults(algorithm types.ChecksumAlgorithm) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		stack.Initialize.Remove(checksumDefaults{}.ID())
+		if algorithm == "" || presigning(stack) {
+			return nil
+		}
+		return stack.Initialize.Add(checksumDefaults{algorithm: algorithm}, middleware.Before)
+	}
+}
@@ -0,0 +1,74 @@
+package acs
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrMissingRegion is returned when the signing region is empty.
+var ErrMissingRegion = errors.New("acs: region is empty")
+
+// ErrNoCredentials is returned by Config.CheckCredentials when the credential chain yields nothing.
+var ErrNoCredentials = errors.New("acs: no credentials in default chain")
+
+// InvalidAddressingStyleError reports an addressing style other than virtual, path or auto.
+type InvalidAddressingStyleError struct {
+	Value string
+}
+
+func (e *InvalidAddressingStyleError) Error() string {
+	return fmt.Sprintf("acs: invalid addressing style %q (want virtual, path or auto)", e.Value)
+}
+
+// InvalidChecksumAlgorithmError reports a checksum algorithm other than CRC32, CRC32C, SHA1 or SHA256.
+type InvalidChecksumAlgorithmError struct {
+	Value string
+}
+
+func (e *InvalidChecksumAlgorithmError) Error() string {
+	return fmt.Sprintf("acs: invalid checksum algorithm %q (want CRC32, CRC32C, SHA1 or SHA256)", e.Value)
+}
+
+// InvalidPayloadSigningError reports a payload signing mode other than signed, unsigned or streaming.
+type InvalidPayloadSigningError struct {
+	Value string
+}
+
+func (e *InvalidPayloadSigningError) Error() string {
+	return fmt.Sprintf("acs: invalid payload signing %q (want signed, unsigned or streaming)", e.Value)
+}
+
+// InvalidEndpointError reports an endpoint that is not an absolute http(s) URL.
+type InvalidEndpointError struct {
+	// Option is "endpoint" or "iam_endpoint".
+	Option   string
+	Endpoint string
+	Reason   string
+}
+
+func (e *InvalidEndpointError) Error() string {
+	return fmt.Sprintf("acs: invalid %s %q: %s", e.Option, e.Endpoint, e.Reason)
+}
+
+func validateEndpoint(option, endpoint string) error {
+	invalid := func(reason string) error {
+		return &InvalidEndpointError{Option: option, Endpoint: endpoint, Reason: reason}
+	}
+	if endpoint == "" {
+		return invalid("endpoint is empty")
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return invalid(err.Error())
+	}
+	switch {
+	case u.Scheme == "" || u.Opaque != "":
+		return invalid("missing scheme")
+	case u.Scheme != "http" && u.Scheme != "https":
+		return invalid(fmt.Sprintf("unsupported scheme %q", u.Scheme))
+	case u.Host == "":
+		return invalid("missing host")
+	}
+	return nil
+}
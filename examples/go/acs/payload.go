@@ -0,0 +1,395 @@
+package acs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// PayloadSigning selects how PutObject and UploadPart bodies are signed.
+type PayloadSigning string
+
+const (
+	// SDKPayloadSigning leaves the choice to the SDK: UNSIGNED-PAYLOAD over
+	// HTTPS (streaming with a trailing checksum when a checksum algorithm is
+	// set), and the body's SHA-256 over plain HTTP.
+	SDKPayloadSigning PayloadSigning = ""
+	// SignedPayload signs the SHA-256 of every body, which is read an extra
+	// time to compute it, and once more for a flexible checksum, which is sent
+	// as a header. Bodies must be seekable.
+	SignedPayload PayloadSigning = "signed"
+	// UnsignedPayload sends UNSIGNED-PAYLOAD and relies on TLS (and the
+	// flexible checksum, if any, sent as a header) for integrity. Bodies with a
+	// checksum must be seekable.
+	UnsignedPayload PayloadSigning = "unsigned"
+	// StreamingPayload sends bodies aws-chunked with the flexible checksum
+	// (CRC32 unless an algorithm is set) in a trailer, so a body is read once
+	// and never buffered or rewound, over HTTPS and plain HTTP alike. A
+	// checksum value given with the input is replaced by the one computed as
+	// the body is sent.
+	StreamingPayload PayloadSigning = "streaming"
+)
+
+// PayloadSigningModes are the modes that can be chosen explicitly.
+var PayloadSigningModes = []PayloadSigning{SignedPayload, UnsignedPayload, StreamingPayload}
+
+// ParsePayloadSigning validates s as a payload signing mode; "" is the SDK default.
+func ParsePayloadSigning(s string) (PayloadSigning, error) {
+	switch mode := PayloadSigning(s); mode {
+	case SDKPayloadSigning, SignedPayload, UnsignedPayload, StreamingPayload:
+		return mode, nil
+	default:
+		return "", &InvalidPayloadSigningError{Value: s}
+	}
+}
+
+// UsePayloadSigning returns a per-client or per-request S3 option that signs
+// PutObject and UploadPart bodies with mode, replacing the client's mode (see
+// WithPayloadSigning). Apply it after the endpoint is set.
+func UsePayloadSigning(mode PayloadSigning) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, addPayloadSigning(mode, endpointHTTPS(o)))
+	}
+}
+
+// endpointHTTPS reports whether o sends requests over HTTPS. The SDK streams
+// bodies with a trailing checksum only over HTTPS, and the stack must know
+// which way a streaming body goes before the endpoint is resolved.
+func endpointHTTPS(o *s3.Options) bool {
+	if o.EndpointOptions.DisableHTTPS {
+		return false
+	}
+	return o.BaseEndpoint == nil || strings.HasPrefix(strings.ToLower(*o.BaseEndpoint), "https://")
+}
+
+const (
+	payloadSigningID = "ACSPayloadSigning"
+	payloadCheckID   = "ACSPayloadCheck"
+
+	// emptySHA256 is the hex SHA-256 of an empty body.
+	emptySHA256              = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	// chunkSize is the size of the aws-chunked chunks a streaming body is sent in.
+	chunkSize = 64 * 1024
+)
+
+// The SDK's payload hash and signing middleware, identified through its own
+// exported types so an SDK upgrade cannot leave these stale.
+var (
+	payloadHashID = (*v4.ComputePayloadSHA256)(nil).ID()
+	signingID     = (*v4.SignHTTPRequestMiddleware)(nil).ID()
+)
+
+// addPayloadSigning puts the SDK's middleware for mode in the payload hash
+// slot: ComputePayloadSHA256, UnsignedPayload, or for streaming over HTTPS the
+// default one, which the SDK's trailing checksum overrides. Only streaming
+// over plain HTTP, which the SDK does not do, uses a hash and body encoding of
+// its own.
+func addPayloadSigning(mode PayloadSigning, https bool) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		stack.Initialize.Remove(payloadSigningID)
+		stack.Finalize.Remove(payloadCheckID)
+		if presigning(stack) || (stack.ID() != "PutObject" && stack.ID() != "UploadPart") {
+			return nil
+		}
+		encode := mode == StreamingPayload && !https
+		var err error
+		switch {
+		case mode == SignedPayload:
+			_, err = stack.Finalize.Swap(payloadHashID, &v4.ComputePayloadSHA256{})
+		case mode == UnsignedPayload:
+			err = v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware(stack)
+		case encode:
+			_, err = stack.Finalize.Swap(payloadHashID, streamingPayloadHash{})
+		default:
+			err = v4.UseDynamicPayloadSigningMiddleware(stack)
+		}
+		if err != nil || mode == SDKPayloadSigning {
+			return err
+		}
+
+		// After the checksum defaults, which pick the algorithm, and before
+		// the SDK's checksum middleware reads it.
+		m := payloadSigning{mode: mode, encode: encode}
+		if _, ok := stack.Initialize.Get(checksumDefaults{}.ID()); ok {
+			err = stack.Initialize.Insert(m, checksumDefaults{}.ID(), middleware.After)
+		} else {
+			err = stack.Initialize.Add(m, middleware.Before)
+		}
+		if err != nil {
+			return err
+		}
+		return stack.Finalize.Insert(payloadCheck{mode: mode}, signingID, middleware.Before)
+	}
+}
+
+// payloadChecksumKey holds the checksum algorithm of a streaming body encoded
+// here, taken off the input so the SDK's checksum middleware leaves it alone.
+type payloadChecksumKey struct{}
+
+// payloadSigning adjusts the flexible checksum of PutObject and UploadPart
+// inputs before the SDK's checksum middleware sees it. The caller's input is
+// copied, never modified.
+type payloadSigning struct {
+	mode PayloadSigning
+	// encode is set when a streaming body is encoded here, over plain HTTP.
+	encode bool
+}
+
+func (payloadSigning) ID() string { return payloadSigningID }
+
+func (m payloadSigning) HandleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	var alg types.ChecksumAlgorithm
+	var err error
+	switch p := in.Parameters.(type) {
+	case *s3.PutObjectInput:
+		c := *p
+		alg, err = m.checksum(c.Body, &c.ChecksumAlgorithm, &c.ChecksumCRC32, &c.ChecksumCRC32C, &c.ChecksumSHA1, &c.ChecksumSHA256)
+		in.Parameters = &c
+	case *s3.UploadPartInput:
+		c := *p
+		alg, err = m.checksum(c.Body, &c.ChecksumAlgorithm, &c.ChecksumCRC32, &c.ChecksumCRC32C, &c.ChecksumSHA1, &c.ChecksumSHA256)
+		in.Parameters = &c
+	}
+	if err != nil {
+		return middleware.InitializeOutput{}, middleware.Metadata{}, err
+	}
+	if alg != "" {
+		ctx = middleware.WithStackValue(ctx, payloadChecksumKey{}, alg)
+	}
+	return next.HandleInitialize(ctx, in)
+}
+
+// checksum sets an input's checksum fields up for the mode. The signed and
+// unsigned modes send the checksum as a header, computed here when not given
+// so the SDK neither moves it to a trailer (over HTTPS) nor signs the payload
+// while computing it (over HTTP). Streaming defaults the algorithm to CRC32
+// and drops a given value for the trailer to replace; when the body is
+// encoded here, the algorithm is taken off the input and returned.
+func (m payloadSigning) checksum(body io.Reader, alg *types.ChecksumAlgorithm, crc32, crc32c, sha1, sha256 **string) (types.ChecksumAlgorithm, error) {
+	fields := []struct {
+		alg types.ChecksumAlgorithm
+		v   **string
+	}{
+		{types.ChecksumAlgorithmCrc32, crc32},
+		{types.ChecksumAlgorithmCrc32c, crc32c},
+		{types.ChecksumAlgorithmSha1, sha1},
+		{types.ChecksumAlgorithmSha256, sha256},
+	}
+
+	if m.mode == StreamingPayload {
+		for _, f := range fields {
+			if *f.v != nil && *alg == "" {
+				*alg = f.alg
+			}
+			*f.v = nil
+		}
+		if *alg == "" {
+			*alg = types.ChecksumAlgorithmCrc32
+		}
+		if !m.encode {
+			return "", nil
+		}
+		taken := *alg
+		*alg = ""
+		return taken, nil
+	}
+
+	for _, f := range fields {
+		if *f.v != nil {
+			return "", nil
+		}
+	}
+	for _, f := range fields {
+		if f.alg != *alg {
+			continue
+		}
+		sum, err := NewChecksum(f.alg)
+		if err != nil {
+			return "", err
+		}
+		if body != nil {
+			seeker, ok := body.(io.ReadSeeker)
+			if !ok {
+				return "", fmt.Errorf("%s payload signing with a %s checksum needs a seekable body; use streaming", m.mode, f.alg)
+			}
+			start, err := seeker.Seek(0, io.SeekCurrent)
+			if err == nil {
+				_, err = io.Copy(sum, seeker)
+			}
+			if err == nil {
+				_, err = seeker.Seek(start, io.SeekStart)
+			}
+			if err != nil {
+				return "", fmt.Errorf("payload checksum error: %w", err)
+			}
+		}
+		*f.v = aws.String(base64.StdEncoding.EncodeToString(sum.Sum(nil)))
+	}
+	return "", nil
+}
+
+// streamingPayloadHash takes the payload hash slot when a streaming body is
+// encoded here: STREAMING-UNSIGNED-PAYLOAD-TRAILER, or for an empty body,
+// which has nothing to stream, its SHA-256 with the checksum as a header.
+type streamingPayloadHash struct{}
+
+func (streamingPayloadHash) ID() string { return payloadHashID }
+
+func (streamingPayloadHash) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	req, ok := in.Request.(*smithyhttp.Request)
+	if !ok {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, fmt.Errorf("unknown transport type %T", in.Request)
+	}
+	alg, _ := middleware.GetStackValue(ctx, payloadChecksumKey{}).(types.ChecksumAlgorithm)
+	sum, err := NewChecksum(alg)
+	if err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+	}
+	if req.ContentLength < 0 {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, fmt.Errorf("streaming payload signing needs the body length")
+	}
+	if req.ContentLength == 0 {
+		req.Header.Set("X-Amz-Sdk-Checksum-Algorithm", string(alg))
+		req.Header.Set(checksumHeader(alg), base64.StdEncoding.EncodeToString(sum.Sum(nil)))
+		return next.HandleFinalize(v4.SetPayloadHash(ctx, emptySHA256), in)
+	}
+	return next.HandleFinalize(v4.SetPayloadHash(ctx, streamingUnsignedTrailer), in)
+}
+
+// payloadCheck runs just before signing, inside the retry loop. It fails a
+// request whose payload hash is not what the mode asks for, so a change in
+// the SDK cannot quietly send bodies another way, and it encodes a streaming
+// body for plain HTTP, afresh on every attempt.
+type payloadCheck struct {
+	mode PayloadSigning
+}
+
+func (payloadCheck) ID() string { return payloadCheckID }
+
+func (m payloadCheck) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	req, ok := in.Request.(*smithyhttp.Request)
+	if !ok {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, fmt.Errorf("unknown transport type %T", in.Request)
+	}
+	payloadHash := v4.GetPayloadHash(ctx)
+	switch m.mode {
+	case SignedPayload:
+		sum, err := hex.DecodeString(payloadHash)
+		ok = err == nil && len(sum) == 32
+	case UnsignedPayload:
+		ok = payloadHash == unsignedPayload
+	case StreamingPayload:
+		ok = payloadHash == streamingUnsignedTrailer || req.ContentLength == 0
+	}
+	if !ok {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, fmt.Errorf("%s payload signing not applied: request would be signed with payload hash %q", m.mode, payloadHash)
+	}
+
+	alg, encode := middleware.GetStackValue(ctx, payloadChecksumKey{}).(types.ChecksumAlgorithm)
+	if !encode || payloadHash != streamingUnsignedTrailer {
+		return next.HandleFinalize(ctx, in)
+	}
+	length := req.ContentLength
+	chunked, err := newChunkedReader(req.GetStream(), alg, length)
+	if err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+	}
+	req, err = req.SetStream(chunked)
+	if err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, fmt.Errorf("streaming payload error: %w", err)
+	}
+	req.ContentLength = chunked.encodedLength()
+	req.Header.Add("Content-Encoding", "aws-chunked")
+	req.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(length))
+	req.Header.Set("X-Amz-Sdk-Checksum-Algorithm", string(alg))
+	req.Header.Set("X-Amz-Trailer", checksumHeader(alg))
+	in.Request = req
+	return next.HandleFinalize(ctx, in)
+}
+
+// chunkedReader encodes a body as aws-chunked: chunkSize chunks, each
+// "<hex size>\r\n<data>\r\n", then "0\r\n", the checksum trailer line and
+// "\r\n".
+type chunkedReader struct {
+	body   io.Reader
+	alg    types.ChecksumAlgorithm
+	length int64
+	hash   hash.Hash
+	buf    []byte
+	out    []byte
+	done   bool
+}
+
+func newChunkedReader(r io.Reader, alg types.ChecksumAlgorithm, length int64) (*chunkedReader, error) {
+	sum, err := NewChecksum(alg)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkedReader{body: r, alg: alg, length: length, hash: sum, buf: make([]byte, chunkSize)}, nil
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// next encodes the next chunk, or the final chunk and trailer, into out.
+func (c *chunkedReader) next() error {
+	n, err := io.ReadFull(c.body, c.buf)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+	case err != nil:
+		return err
+	}
+	if n > 0 {
+		c.hash.Write(c.buf[:n])
+		c.out = fmt.Appendf(c.out[:0], "%x\r\n", n)
+		c.out = append(c.out, c.buf[:n]...)
+		c.out = append(c.out, "\r\n"...)
+		return nil
+	}
+	sum := base64.StdEncoding.EncodeToString(c.hash.Sum(nil))
+	c.out = fmt.Appendf(c.out[:0], "0\r\n%s:%s\r\n\r\n", checksumHeader(c.alg), sum)
+	c.done = true
+	return nil
+}
+
+// encodedLength is the length of the whole encoded body, which is known
+// before it is read because every chunk but the last is full.
+func (c *chunkedReader) encodedLength() int64 {
+	chunk := func(n int64) int64 { return int64(len(fmt.Sprintf("%x", n))) + 4 + n }
+	full, rest := c.length/chunkSize, c.length%chunkSize
+	total := full * chunk(chunkSize)
+	if rest > 0 {
+		total += chunk(rest)
+	}
+	trailer := len("0\r\n") + len(checksumHeader(c.alg)) + 1 + base64.StdEncoding.EncodedLen(c.hash.Size()) + len("\r\n\r\n")
+	return total + int64(trailer)
+}
+
+func checksumHeader(alg types.ChecksumAlgorithm) string {
+	return "x-amz-checksum-" + strings.ToLower(string(alg))
+}
@@ -0,0 +1,285 @@
+package acs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"s3setup/internal/fakes3"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestChunkedReaderWireFormat(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789abcdef"), chunkSize/16)
+	for _, tc := range []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{"small", []byte("hello"), "5\r\nhello\r\n0\r\nx-amz-checksum-crc32:NhCmhg==\r\n\r\n"},
+		{"one full chunk", big, "10000\r\n" + string(big) + "\r\n0\r\nx-amz-checksum-crc32:" + checksumOf(big) + "\r\n\r\n"},
+		{
+			"full chunk and remainder", append(append([]byte{}, big...), 'x'),
+			"10000\r\n" + string(big) + "\r\n1\r\nx\r\n0\r\nx-amz-checksum-crc32:" + checksumOf(append(append([]byte{}, big...), 'x')) + "\r\n\r\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Reading the body a byte at a time must not change the chunking.
+			r, err := newChunkedReader(iotest.OneByteReader(bytes.NewReader(tc.body)), types.ChecksumAlgorithmCrc32, int64(len(tc.body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("encoded body:\n got %q\nwant %q", truncate(got), truncate([]byte(tc.want)))
+			}
+			if n := r.encodedLength(); n != int64(len(got)) {
+				t.Errorf("encodedLength = %d, encoded %d bytes", n, len(got))
+			}
+		})
+	}
+}
+
+func TestChunkedReaderEncodedLength(t *testing.T) {
+	for _, alg := range ChecksumAlgorithms {
+		for _, n := range []int{1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 17} {
+			r, err := newChunkedReader(bytes.NewReader(make([]byte, n)), alg, int64(n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.encodedLength() != int64(len(got)) {
+				t.Errorf("%s, %d bytes: encodedLength = %d, encoded %d bytes", alg, n, r.encodedLength(), len(got))
+			}
+		}
+	}
+}
+
+func TestNewChecksum(t *testing.T) {
+	data := []byte("hello, world")
+	for alg, want := range map[types.ChecksumAlgorithm]string{
+		types.ChecksumAlgorithmCrc32:  checksumOf(data),
+		types.ChecksumAlgorithmCrc32c: crc32cOf(data),
+		types.ChecksumAlgorithmSha1:   "t+I+wpryKwtOQdox6GjVciYSHIQ=",
+		types.ChecksumAlgorithmSha256: "Ccp+TqpuiunH0mEWcSkYSINkTQffuny/vEyKLgg2DVs=",
+	} {
+		h, err := NewChecksum(alg)
+		if err != nil {
+			t.Fatalf("NewChecksum(%s): %v", alg, err)
+		}
+		h.Write(data)
+		if got := base64.StdEncoding.EncodeToString(h.Sum(nil)); got != want {
+			t.Errorf("%s of %q = %s, want %s", alg, data, got, want)
+		}
+	}
+	// A typo must not fall back to another algorithm.
+	for _, alg := range []types.ChecksumAlgorithm{"", "crc32", "MD5"} {
+		var invalid *InvalidChecksumAlgorithmError
+		if h, err := NewChecksum(alg); !errors.As(err, &invalid) || h != nil {
+			t.Errorf("NewChecksum(%q) = %v, %v, want an InvalidChecksumAlgorithmError", alg, h, err)
+		}
+	}
+}
+
+// TestPayloadSigningModes uploads with each mode over plain HTTP, where
+// streaming bodies are encoded here, and over HTTPS, where the SDK streams
+// them. The first attempt of every upload fails, so the retry must send the
+// body again.
+func TestPayloadSigningModes(t *testing.T) {
+	for _, tls := range []bool{false, true} {
+		for _, mode := range PayloadSigningModes {
+			scheme := map[bool]string{false: "http", true: "https"}[tls]
+			t.Run(fmt.Sprintf("%s/%s", scheme, mode), func(t *testing.T) {
+				rec := &recorder{next: fakes3.NewHandler(), fail: map[string]bool{}}
+				srv := httptest.NewUnstartedServer(rec)
+				if tls {
+					srv.StartTLS()
+				} else {
+					srv.Start()
+				}
+				defer srv.Close()
+				client := testClient(srv, UsePayloadSigning(mode))
+				ctx := context.Background()
+				bucket := "signing"
+				if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: &bucket}); err != nil {
+					t.Fatal(err)
+				}
+
+				data := bytes.Repeat([]byte("payload "), 3*chunkSize/8+5)
+				for _, key := range []string{"data.bin", "empty.bin"} {
+					body := data
+					if key == "empty.bin" {
+						body = nil
+					}
+					if _, err := client.PutObject(ctx, &s3.PutObjectInput{
+						Bucket: &bucket, Key: aws.String(key), Body: bytes.NewReader(body),
+						ChecksumAlgorithm: types.ChecksumAlgorithmCrc32c,
+					}); err != nil {
+						t.Fatalf("put %s: %v", key, err)
+					}
+					get, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: aws.String(key), ChecksumMode: types.ChecksumModeEnabled})
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := io.ReadAll(get.Body)
+					get.Body.Close()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, body) {
+						t.Errorf("%s: stored %d bytes, want %d", key, len(got), len(body))
+					}
+					if want := crc32cOf(body); aws.ToString(get.ChecksumCRC32C) != want {
+						t.Errorf("%s: stored CRC32C %q, want %q", key, aws.ToString(get.ChecksumCRC32C), want)
+					}
+				}
+
+				hashes := rec.hashes()
+				if len(hashes) != 4 {
+					t.Fatalf("sent %d PUT attempts, want 4: %q", len(hashes), hashes)
+				}
+				for i, h := range hashes {
+					empty := i >= 2
+					switch {
+					case mode == SignedPayload && len(h) != 64:
+						t.Errorf("attempt %d sent x-amz-content-sha256 %q, want a SHA-256", i+1, h)
+					case mode == UnsignedPayload && h != unsignedPayload:
+						t.Errorf("attempt %d sent x-amz-content-sha256 %q, want %s", i+1, h, unsignedPayload)
+					case mode == StreamingPayload && !empty && h != streamingUnsignedTrailer:
+						t.Errorf("attempt %d sent x-amz-content-sha256 %q, want %s", i+1, h, streamingUnsignedTrailer)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestPayloadSigningNeedsSeekableBody(t *testing.T) {
+	srv := fakes3.NewServer()
+	defer srv.Close()
+	client := testClient(srv, UsePayloadSigning(UnsignedPayload))
+	ctx := context.Background()
+	bucket := "signing"
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: &bucket}); err != nil {
+		t.Fatal(err)
+	}
+	body := io.MultiReader(strings.NewReader("not seekable"))
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &bucket, Key: aws.String("k"), Body: body, ContentLength: aws.Int64(12),
+		ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+	})
+	if err == nil || !strings.Contains(err.Error(), "needs a seekable body") {
+		t.Fatalf("put with unseekable body: %v, want a seekable body error", err)
+	}
+
+	// Streaming reads the body once, so it takes the same body.
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &bucket, Key: aws.String("k"), Body: io.MultiReader(strings.NewReader("not seekable")), ContentLength: aws.Int64(12),
+		ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+	}, UsePayloadSigning(StreamingPayload))
+	if err != nil {
+		t.Fatalf("streaming put with unseekable body: %v", err)
+	}
+}
+
+// TestPayloadSigningNotApplied checks a request fails, rather than going out
+// signed another way, when the mode cannot take effect: here streaming was set
+// up for HTTPS, where the SDK streams the body, but the endpoint is plain HTTP.
+func TestPayloadSigningNotApplied(t *testing.T) {
+	srv := fakes3.NewServer()
+	defer srv.Close()
+	client := testClient(srv,
+		func(o *s3.Options) { o.BaseEndpoint = aws.String("https://s3.example.com") },
+		UsePayloadSigning(StreamingPayload),
+		func(o *s3.Options) { o.BaseEndpoint = aws.String(srv.URL) })
+	_, err := client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String("signing"), Key: aws.String("k"), Body: strings.NewReader("data"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "streaming payload signing not applied") {
+		t.Fatalf("put: %v, want a payload signing not applied error", err)
+	}
+}
+
+func testClient(srv *httptest.Server, optFns ...func(*s3.Options)) *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(srv.URL),
+		UsePathStyle: true,
+		HTTPClient:   srv.Client(),
+		Retryer: retry.NewStandard(func(o *retry.StandardOptions) {
+			o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+		}),
+	}, optFns...)
+}
+
+// recorder records the x-amz-content-sha256 of every PUT of an object, and
+// fails the first attempt of each with a 503.
+type recorder struct {
+	next http.Handler
+
+	mu   sync.Mutex
+	sent []string
+	fail map[string]bool
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPut && strings.Count(strings.Trim(req.URL.Path, "/"), "/") > 0 {
+		r.mu.Lock()
+		r.sent = append(r.sent, req.Header.Get("X-Amz-Content-Sha256"))
+		failed := r.fail[req.URL.Path]
+		r.fail[req.URL.Path] = true
+		r.mu.Unlock()
+		if !failed {
+			io.Copy(io.Discard, req.Body)
+			http.Error(w, "", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	r.next.ServeHTTP(w, req)
+}
+
+func (r *recorder) hashes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sent...)
+}
+
+func checksumOf(b []byte) string {
+	return base64.StdEncoding.EncodeToString(be32(crc32.ChecksumIEEE(b)))
+}
+
+func crc32cOf(b []byte) string {
+	return base64.StdEncoding.EncodeToString(be32(crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli))))
+}
+
+func be32(v uint32) []byte { return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)} }
+
+func truncate(b []byte) string {
+	if len(b) > 80 {
+		return string(b[:40]) + "..." + string(b[len(b)-40:])
+	}
+	return string(b)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// maxSkew is how far the local clock may drift from the server before SigV4
+// requests are rejected with RequestTimeTooSkewed.
+const maxSkew = 15 * time.Minute
+
+// check is one diagnostic layer. A failing fatal check skips the checks after it,
+// since they would only fail the same way.
+type check struct {
+	name  string
+	fatal bool
+	run   func(context.Context, *doctor) result
+}
+
+var checks = []check{
+	{"dns", true, checkDNS},
+	{"tls", true, checkTLS},
+	{"virtual host", false, checkVirtualHost},
+	{"clock skew", false, checkClock},
+	{"credentials", true, checkCredentials},
+	{"addressing", false, checkAddressing},
+}
+
+// checkDNS resolves the endpoint host.
+func checkDNS(ctx context.Context, d *doctor) result {
+	if net.ParseIP(d.host()) != nil {
+		return result{status: pass, detail: d.host() + " is an IP address"}
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, d.host())
+	if err != nil {
+		return result{status: fail, detail: fmt.Sprintf("cannot resolve %s: %v", d.host(), err),
+			hint: "check the endpoint host name and this machine's DNS settings"}
+	}
+	return result{status: pass, detail: fmt.Sprintf("%s -> %s", d.host(), strings.Join(addrs, ", "))}
+}
+
+// checkTLS performs a TLS handshake with the endpoint and checks its certificate.
+func checkTLS(ctx context.Context, d *doctor) result {
+	if d.endpoint.Scheme != "https" {
+		return result{status: skip, detail: "plain HTTP endpoint"}
+	}
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: d.host()}}
+	conn, err := dialer.DialContext(ctx, "tcp", d.hostPort())
+	if err != nil {
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			return result{status: fail, detail: fmt.Sprintf("certificate rejected: %v", certErr.Err),
+				hint: "the endpoint's certificate is invalid for this host, expired, or signed by an untrusted CA"}
+		}
+		return result{status: fail, detail: fmt.Sprintf("handshake with %s failed: %v", d.hostPort(), err),
+			hint: "check that the port serves HTTPS and that no proxy or firewall intercepts it"}
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	cert := state.PeerCertificates[0]
+	detail := fmt.Sprintf("%s, certificate for %s expires %s (issuer %s)",
+		tls.VersionName(state.Version), cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"), cert.Issuer.CommonName)
+	if left := time.Until(cert.NotAfter); left < 14*24*time.Hour {
+		return result{status: warn, detail: detail, hint: fmt.Sprintf("certificate expires in %s", left.Round(time.Hour))}
+	}
+	return result{status: pass, detail: detail}
+}
+
+// checkVirtualHost checks that a bucket subdomain resolves and is covered by the
+// endpoint certificate, as virtual-hosted requests need.
+func checkVirtualHost(ctx context.Context, d *doctor) result {
+	if net.ParseIP(d.host()) != nil {
+		return result{status: skip, detail: "IP endpoint; requests always use path style"}
+	}
+	style := d.cfg.StyleFor(d.dnsBucket)
+	if style != "virtual" {
+		return result{status: skip, detail: fmt.Sprintf("addressing %s uses path style for %s", d.cfg.AddressingStyle, d.dnsBucket)}
+	}
+
+	name := d.dnsBucket + "." + d.host()
+	hint := "set S3_ADDRESSING_STYLE=path (or auto), or add a wildcard DNS record for the endpoint"
+	addrs, err := net.DefaultResolver.LookupHost(ctx, name)
+	if err != nil {
+		return result{status: fail, detail: fmt.Sprintf("cannot resolve %s: %v", name, err), hint: hint}
+	}
+	if d.endpoint.Scheme == "https" {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: d.host()}}
+		conn, err := dialer.DialContext(ctx, "tcp", d.hostPort())
+		if err == nil {
+			defer conn.Close()
+			cert := conn.(*tls.Conn).ConnectionState().PeerCertificates[0]
+			if err := cert.VerifyHostname(name); err != nil {
+				return result{status: fail, detail: fmt.Sprintf("certificate does not cover %s", name),
+					hint: "set S3_ADDRESSING_STYLE=path (or auto), or use a wildcard certificate"}
+			}
+		}
+	}
+	return result{status: pass, detail: fmt.Sprintf("%s -> %s", name, strings.Join(addrs, ", "))}
+}
+
+// checkClock compares the local clock with the server Date header.
+func checkClock(ctx context.Context, d *doctor) result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, d.endpoint.String(), nil)
+	if err != nil {
+		return result{status: fail, detail: err.Error()}
+	}
+	before := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return result{status: fail, detail: fmt.Sprintf("request to %s failed: %v", d.endpoint, err)}
+	}
+	resp.Body.Close()
+	local := before.Add(time.Since(before) / 2)
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return result{status: skip, detail: "server sent no usable Date header"}
+	}
+	skew := serverTime.Sub(local).Round(time.Second)
+	detail := fmt.Sprintf("server time %s, local clock off by %s", serverTime.UTC().Format(time.RFC3339), skew)
+	hint := "synchronize the system clock (e.g. enable NTP)"
+	switch {
+	case skew.Abs() > maxSkew:
+		return result{status: fail, detail: detail, hint: hint + "; signed requests will be rejected"}
+	case skew.Abs() > time.Minute:
+		return result{status: warn, detail: detail, hint: hint}
+	}
+	return result{status: pass, detail: detail}
+}
+
+// checkCredentials makes a cheap signed request to confirm the endpoint accepts the credentials.
+func checkCredentials(ctx context.Context, d *doctor) result {
+	out, err := d.client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err == nil {
+		return result{status: pass, detail: fmt.Sprintf("ListBuckets succeeded (%d buckets)", len(out.Buckets))}
+	}
+
+	res := result{status: fail, detail: fmt.Sprintf("ListBuckets failed: %v", err)}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		res.hint = "the endpoint could not be reached; see the dns and tls checks"
+		return res
+	}
+	switch apiErr.ErrorCode() {
+	case "InvalidAccessKeyId":
+		res.hint = "the endpoint does not know this access key; check AWS_ACCESS_KEY_ID or the aws_profile in use"
+	case "SignatureDoesNotMatch":
+		res.hint = fmt.Sprintf("check the secret key, and that the region is %q", d.cfg.Region)
+	case "RequestTimeTooSkewed":
+		res.hint = "synchronize the system clock (see the clock skew check)"
+	case "AccessDenied":
+		res.hint = "the credentials are valid but may not list buckets; acs-doctor -bucket <name> probes an existing bucket instead"
+	}
+	return res
+}
+
+// checkAddressing probes the configured addressing style: with -bucket by reading
+// the bucket, otherwise by creating and removing a temporary bucket. When that
+// fails it tries the other style, to tell a wrong setting from a broken endpoint.
+func checkAddressing(ctx context.Context, d *doctor) result {
+	bucket := d.bucket
+	if bucket == "" {
+		bucket = d.probe
+	}
+	style := d.cfg.StyleFor(bucket)
+	left, err := d.probeBucket(ctx, bucket)
+	if err == nil {
+		return leftover(result{status: pass, detail: fmt.Sprintf("%s style works for %s", style, bucket)}, left)
+	}
+
+	res := result{status: fail, detail: fmt.Sprintf("%s style failed: %v", style, err)}
+	if net.ParseIP(d.host()) != nil {
+		return leftover(res, left)
+	}
+	other := "path"
+	if style == "path" {
+		other = "virtual"
+	}
+	otherLeft, err := d.probeBucket(ctx, bucket, withStyle(other))
+	if err == nil {
+		res.hint = fmt.Sprintf("%s style works; set S3_ADDRESSING_STYLE=%s", other, other)
+	}
+	if otherLeft != "" {
+		left = otherLeft
+	}
+	return leftover(res, left)
+}
+
+// leftover adds to res a temporary bucket probeBucket could not remove, as
+// described by left, turning a pass into a warning.
+func leftover(res result, left string) result {
+	if left == "" {
+		return res
+	}
+	res.detail += "; cleanup incomplete: " + left
+	if res.status == pass {
+		res.status = warn
+		res.hint = "delete the bucket by hand, or with janitor once it is older than its TTL"
+	}
+	return res
+}
+
+// probeBucket reads d.bucket, or creates, writes to and removes bucket when no
+// -bucket was given. When the bucket it created could not be removed, left
+// says what was left behind and why.
+func (d *doctor) probeBucket(ctx context.Context, bucket string, optFns ...func(*s3.Options)) (left string, err error) {
+	if d.bucket != "" {
+		if _, err := d.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}, optFns...); err != nil {
+			return "", fmt.Errorf("head bucket error: %w", err)
+		}
+		if _, err := d.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucket), MaxKeys: aws.Int32(1)}, optFns...); err != nil {
+			return "", fmt.Errorf("list objects error: %w", err)
+		}
+		return "", nil
+	}
+
+	if _, err := d.client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)}, optFns...); err != nil {
+		return "", fmt.Errorf("create bucket error: %w", err)
+	}
+	defer func() {
+		// Clean up with the same addressing style the bucket was created with.
+		report, cerr := common.EmptyAndDeleteBucket(ctx, s3.New(d.client.Options(), optFns...), bucket)
+		if cerr != nil {
+			left = fmt.Sprintf("%s: %v", report, cerr)
+		}
+	}()
+	if _, err := d.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}, optFns...); err != nil {
+		return "", fmt.Errorf("head bucket error: %w", err)
+	}
+	if _, err := d.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String("acs-doctor.txt"),
+		Body:   strings.NewReader("acs-doctor"),
+	}, optFns...); err != nil {
+		return "", fmt.Errorf("put object error: %w", err)
+	}
+	return "", nil
+}
+
+// withStyle overrides the client's addressing style for one request.
+func withStyle(style string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.UsePathStyle = style == "path"
+		o.EndpointResolverV2 = s3.NewDefaultEndpointResolverV2()
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// acs-doctor checks each layer between this machine and the ACS endpoint (config,
+// DNS, TLS, clock, credentials, addressing) and reports the first one that fails,
+// so a bad setup is diagnosed before the setup guides run.
+func main() {
+	bucket := flag.String("bucket", common.Env("DOCTOR_BUCKET", ""), "probe addressing with this existing bucket (read-only) instead of a temporary one")
+	timeout := flag.Duration("timeout", 15*time.Second, "timeout for each check")
+	flag.Parse()
+
+	ctx := context.Background()
+	fmt.Println("=== ACS doctor ===")
+
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		report("config", result{status: fail, detail: err.Error()})
+		fmt.Println()
+		fmt.Println("Fix the configuration above and run acs-doctor again.")
+		os.Exit(1)
+	}
+	printSettings(cfg)
+	probe, err := common.NewTestBucketName("acs-doctor")
+	if err != nil {
+		report("config", result{status: fail, detail: err.Error(), hint: "check TEST_RUN_ID"})
+		os.Exit(1)
+	}
+	report("config", result{status: pass, detail: "settings and credentials resolved"})
+
+	u, _ := url.Parse(cfg.Endpoint) // already validated
+	d := &doctor{
+		client:   client,
+		cfg:      cfg,
+		endpoint: u,
+		bucket:   *bucket,
+		probe:    probe,
+	}
+	d.dnsBucket = d.probe
+	if d.bucket != "" {
+		d.dnsBucket = d.bucket
+	}
+
+	var failed, blocked string
+	for _, c := range checks {
+		if blocked != "" {
+			report(c.name, result{status: skip, detail: blocked + " failed"})
+			continue
+		}
+		cctx, cancel := context.WithTimeout(ctx, *timeout)
+		res := c.run(cctx, d)
+		cancel()
+		report(c.name, res)
+		if res.status == fail {
+			if failed == "" {
+				failed = c.name
+			}
+			if c.fatal {
+				blocked = c.name
+			}
+		}
+	}
+
+	fmt.Println()
+	if failed != "" {
+		fmt.Printf("❌ First failing layer: %s\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("✅ All checks passed")
+}
+
+// doctor is the state shared by the checks.
+type doctor struct {
+	client   *s3.Client
+	cfg      common.ConfigValues
+	endpoint *url.URL
+	// bucket is the existing bucket given with -bucket, or "".
+	bucket string
+	// probe is the name of the temporary bucket created by the addressing check.
+	probe string
+	// dnsBucket is the bucket name used for the virtual-hosted DNS and certificate checks.
+	dnsBucket string
+}
+
+func (d *doctor) host() string { return d.endpoint.Hostname() }
+
+func (d *doctor) hostPort() string {
+	if port := d.endpoint.Port(); port != "" {
+		return net.JoinHostPort(d.host(), port)
+	}
+	if d.endpoint.Scheme == "https" {
+		return net.JoinHostPort(d.host(), "443")
+	}
+	return net.JoinHostPort(d.host(), "80")
+}
+
+type status int
+
+const (
+	pass status = iota
+	warn
+	fail
+	skip
+)
+
+func (s status) String() string {
+	return [...]string{"PASS", "WARN", "FAIL", "SKIP"}[s]
+}
+
+type result struct {
+	status status
+	detail string
+	hint   string
+}
+
+func report(name string, res result) {
+	fmt.Printf("[%s] %-18s %s\n", res.status, name, res.detail)
+	if res.hint != "" {
+		fmt.Printf("       %-18s hint: %s\n", "", res.hint)
+	}
+}
+
+func printSettings(cfg common.ConfigValues) {
+	show := func(label, value, setting string) {
+		if src := cfg.Sources[setting]; src != "" {
+			fmt.Printf("%-12s %s (%s)\n", label+":", value, src)
+		} else {
+			fmt.Printf("%-12s %s\n", label+":", value)
+		}
+	}
+	if cfg.Profile != "" {
+		fmt.Printf("%-12s %s\n", "Profile:", cfg.Profile)
+	}
+	show("Endpoint", cfg.Endpoint, "endpoint")
+	show("Region", cfg.Region, "region")
+	show("Addressing", cfg.AddressingStyle, "addressing_style")
+	if cfg.ChecksumAlgorithm != "" {
+		show("Checksum", cfg.ChecksumAlgorithm, "checksum_algorithm")
+	}
+	if cfg.PayloadSigning != "" {
+		show("Signing", cfg.PayloadSigning, "payload_signing")
+	}
+	fmt.Println()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"s3setup/internal/common"
+	"s3setup/internal/scenario"
+)
+
+// acs-suite runs the registered setup-guide scenarios and prints a pass/fail summary,
+// the Go counterpart of examples/cli/run_all_tests.sh.
+func main() {
+	runExpr := flag.String("run", "", "only run scenarios whose name matches this regular expression")
+	parallel := flag.Int("parallel", 1, "number of scenarios to run concurrently")
+	list := flag.Bool("list", false, "list scenario names and exit")
+	junitPath := flag.String("junit", common.Env("REPORT_JUNIT", ""), "write JUnit XML results to this file")
+	jsonPath := flag.String("json", common.Env("REPORT_JSON", ""), "write JSON results to this file")
+	flag.Parse()
+
+	var match *regexp.Regexp
+	if *runExpr != "" {
+		re, err := regexp.Compile(*runExpr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -run expression: %v\n", err)
+			os.Exit(2)
+		}
+		match = re
+	}
+
+	var selected []scenario.Scenario
+	for _, s := range scenario.All() {
+		if match == nil || match.MatchString(s.Name) {
+			selected = append(selected, s)
+		}
+	}
+	if *list {
+		for _, s := range selected {
+			fmt.Println(s.Name)
+		}
+		return
+	}
+	if len(selected) == 0 {
+		fmt.Fprintln(os.Stderr, "no scenarios match -run")
+		os.Exit(2)
+	}
+
+	fmt.Println("=========================================")
+	fmt.Println("Running ACS Go Setup Test Suite")
+	fmt.Println("=========================================")
+	fmt.Println()
+
+	start := time.Now()
+	results := run(context.Background(), selected, *parallel)
+	passed := summarize(results, time.Since(start))
+	if err := scenario.WriteReports(*junitPath, *jsonPath, "acs-suite", results); err != nil {
+		fmt.Fprintf(os.Stderr, "report error: %v\n", err)
+		os.Exit(1)
+	}
+	if !passed {
+		os.Exit(1)
+	}
+}
+
+// run executes the scenarios in order, or with up to parallel at once. Parallel runs
+// capture each scenario's output and print it whole when the scenario finishes.
+func run(ctx context.Context, scenarios []scenario.Scenario, parallel int) []scenario.Result {
+	results := make([]scenario.Result, len(scenarios))
+	if parallel <= 1 {
+		for i, s := range scenarios {
+			printHeader(s.Name)
+			results[i] = scenario.Run(ctx, s, os.Stdout)
+			printOutcome(results[i])
+		}
+		return results
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i, s := range scenarios {
+		wg.Add(1)
+		go func(i int, s scenario.Scenario) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			res := scenario.RunCaptured(ctx, s)
+			results[i] = res
+
+			mu.Lock()
+			defer mu.Unlock()
+			printHeader(s.Name)
+			fmt.Print(res.Output)
+			printOutcome(res)
+		}(i, s)
+	}
+	wg.Wait()
+	return results
+}
+
+func printHeader(name string) {
+	fmt.Println("----------------------------------------")
+	fmt.Printf("Running: %s\n", name)
+	fmt.Println("----------------------------------------")
+}
+
+func printOutcome(res scenario.Result) {
+	if res.Passed() {
+		fmt.Printf("✅ PASSED: %s (%s)\n", res.Name, res.Duration.Round(time.Millisecond))
+	} else {
+		fmt.Printf("❌ FAILED: %s (exit %d): %v\n", res.Name, res.Code, res.Err)
+	}
+	fmt.Println()
+}
+
+// summarize prints the totals and reports whether every scenario passed.
+func summarize(results []scenario.Result, elapsed time.Duration) bool {
+	var failed []scenario.Result
+	for _, res := range results {
+		if !res.Passed() {
+			failed = append(failed, res)
+		}
+	}
+
+	fmt.Println("=========================================")
+	fmt.Println("Test Results Summary")
+	fmt.Println("=========================================")
+	fmt.Printf("Passed: %d\n", len(results)-len(failed))
+	fmt.Printf("Failed: %d\n", len(failed))
+	fmt.Printf("Time:   %s\n", elapsed.Round(time.Millisecond))
+
+	if len(failed) > 0 {
+		fmt.Println()
+		fmt.Println("Failed tests:")
+		for _, res := range failed {
+			fmt.Printf("  - %s: %v\n", res.Name, res.Err)
+		}
+		return false
+	}
+	fmt.Println()
+	fmt.Println("🎉 All tests passed!")
+	return true
+}
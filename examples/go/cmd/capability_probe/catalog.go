@@ -0,0 +1,689 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// probe exercises one capability. It returns nil when the operation behaves like
+// S3, a differs error when it succeeds with other results, or the SDK error.
+type probe struct {
+	name     string
+	category string
+	run      func(context.Context, *prober) error
+}
+
+// catalog is every probe, in output order. Add new probes at the end of their
+// category so existing rows keep their place in diffs.
+var catalog = []probe{
+	{"GetBucketLocation", "bucket", probeBucketLocation},
+	{"ListObjects (v1)", "bucket", probeListObjectsV1},
+	{"ListObjectsV2 delimiter", "bucket", probeListDelimiter},
+	{"BucketVersioning", "bucket", probeVersioning},
+	{"BucketTagging", "bucket", probeBucketTagging},
+	{"BucketACL", "bucket", probeBucketACL},
+	{"BucketCORS", "bucket", probeCORS},
+	{"BucketLifecycle", "bucket", probeLifecycle},
+	{"BucketPolicy", "bucket", probeBucketPolicy},
+	{"BucketEncryption", "bucket", probeBucketEncryption},
+	{"PublicAccessBlock", "bucket", probePublicAccessBlock},
+	{"BucketWebsite", "bucket", probeWebsite},
+	{"ObjectLock", "bucket", probeObjectLock},
+
+	{"Content-Type and metadata", "object", probeMetadata},
+	{"Range GET", "object", probeRange},
+	{"Conditional GET", "object", probeConditional},
+	{"ObjectTagging", "object", probeObjectTagging},
+	{"ObjectACL", "object", probeObjectACL},
+	{"GetObjectAttributes", "object", probeObjectAttributes},
+	{"CopyObject REPLACE", "object", probeCopyReplace},
+	{"DeleteObjects", "object", probeDeleteObjects},
+	{"SSE-S3", "object", probeSSES3},
+	{"SSE-C", "object", probeSSEC},
+
+	{"MultipartUpload", "multipart", probeMultipart},
+	{"ListParts", "multipart", probeListParts},
+	{"ListMultipartUploads", "multipart", probeListUploads},
+	{"UploadPartCopy", "multipart", probeUploadPartCopy},
+
+	{"Presigned GET", "presign", probePresignGet},
+	{"Presigned PUT", "presign", probePresignPut},
+}
+
+func probeBucketLocation(ctx context.Context, p *prober) error {
+	_, err := p.client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: aws.String(p.bucket)})
+	return err
+}
+
+func probeListObjectsV1(ctx context.Context, p *prober) error {
+	if _, err := p.put(ctx, "v1/a.txt", "a"); err != nil {
+		return err
+	}
+	out, err := p.client.ListObjects(ctx, &s3.ListObjectsInput{Bucket: aws.String(p.bucket), Prefix: aws.String("v1/")})
+	if err != nil {
+		return err
+	}
+	if len(out.Contents) != 1 || aws.ToString(out.Contents[0].Key) != "v1/a.txt" {
+		return differs("listed %d objects, want v1/a.txt only", len(out.Contents))
+	}
+	return nil
+}
+
+func probeListDelimiter(ctx context.Context, p *prober) error {
+	for _, key := range []string{"dir/a.txt", "dir/sub/b.txt"} {
+		if _, err := p.put(ctx, key, "x"); err != nil {
+			return err
+		}
+	}
+	out, err := p.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(p.bucket),
+		Prefix:    aws.String("dir/"),
+		Delimiter: aws.String("/"),
+	})
+	if err != nil {
+		return err
+	}
+	if len(out.Contents) != 1 || len(out.CommonPrefixes) != 1 || aws.ToString(out.CommonPrefixes[0].Prefix) != "dir/sub/" {
+		return differs("got %d objects and %d common prefixes, want 1 and dir/sub/", len(out.Contents), len(out.CommonPrefixes))
+	}
+	return nil
+}
+
+func probeVersioning(ctx context.Context, p *prober) error {
+	bucket, err := p.newBucket(ctx, "ver")
+	if err != nil {
+		return err
+	}
+	if _, err := p.client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucket),
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	}); err != nil {
+		return err
+	}
+	got, err := p.client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return err
+	}
+	if got.Status != types.BucketVersioningStatusEnabled {
+		return differs("versioning status %q after enabling", got.Status)
+	}
+	for _, body := range []string{"one", "two"} {
+		if _, err := p.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket), Key: aws.String("versioned.txt"), Body: strings.NewReader(body),
+		}); err != nil {
+			return err
+		}
+	}
+	vs, err := p.client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return err
+	}
+	if len(vs.Versions) != 2 || aws.ToString(vs.Versions[0].VersionId) == aws.ToString(vs.Versions[1].VersionId) {
+		return differs("listed %d versions after two writes, want 2 distinct", len(vs.Versions))
+	}
+	return nil
+}
+
+func probeBucketTagging(ctx context.Context, p *prober) error {
+	if _, err := p.client.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(p.bucket),
+		Tagging: &types.Tagging{TagSet: []types.Tag{{Key: aws.String("probe"), Value: aws.String("capability")}}},
+	}); err != nil {
+		return err
+	}
+	got, err := p.client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(p.bucket)})
+	if err != nil {
+		return err
+	}
+	if len(got.TagSet) != 1 || aws.ToString(got.TagSet[0].Value) != "capability" {
+		return differs("read back %d tags, want probe=capability", len(got.TagSet))
+	}
+	_, err = p.client.DeleteBucketTagging(ctx, &s3.DeleteBucketTaggingInput{Bucket: aws.String(p.bucket)})
+	return err
+}
+
+func probeBucketACL(ctx context.Context, p *prober) error {
+	got, err := p.client.GetBucketAcl(ctx, &s3.GetBucketAclInput{Bucket: aws.String(p.bucket)})
+	if err != nil {
+		return err
+	}
+	if _, err := p.client.PutBucketAcl(ctx, &s3.PutBucketAclInput{Bucket: aws.String(p.bucket), ACL: types.BucketCannedACLPrivate}); err != nil {
+		return err
+	}
+	if got.Owner == nil || len(got.Grants) == 0 {
+		return differs("ACL has no owner or grants")
+	}
+	return nil
+}
+
+func probeCORS(ctx context.Context, p *prober) error {
+	if _, err := p.client.PutBucketCors(ctx, &s3.PutBucketCorsInput{
+		Bucket: aws.String(p.bucket),
+		CORSConfiguration: &types.CORSConfiguration{CORSRules: []types.CORSRule{{
+			AllowedMethods: []string{"GET"},
+			AllowedOrigins: []string{"https://example.com"},
+		}}},
+	}); err != nil {
+		return err
+	}
+	got, err := p.client.GetBucketCors(ctx, &s3.GetBucketCorsInput{Bucket: aws.String(p.bucket)})
+	if err != nil {
+		return err
+	}
+	if len(got.CORSRules) != 1 {
+		return differs("read back %d CORS rules, want 1", len(got.CORSRules))
+	}
+	_, err = p.client.DeleteBucketCors(ctx, &s3.DeleteBucketCorsInput{Bucket: aws.String(p.bucket)})
+	return err
+}
+
+func probeLifecycle(ctx context.Context, p *prober) error {
+	if _, err := p.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(p.bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: []types.LifecycleRule{{
+			ID:         aws.String("expire-tmp"),
+			Status:     types.ExpirationStatusEnabled,
+			Filter:     &types.LifecycleRuleFilter{Prefix: aws.String("tmp/")},
+			Expiration: &types.LifecycleExpiration{Days: aws.Int32(1)},
+		}}},
+	}); err != nil {
+		return err
+	}
+	got, err := p.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(p.bucket)})
+	if err != nil {
+		return err
+	}
+	if len(got.Rules) != 1 || aws.ToString(got.Rules[0].ID) != "expire-tmp" {
+		return differs("read back %d lifecycle rules, want expire-tmp", len(got.Rules))
+	}
+	_, err = p.client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{Bucket: aws.String(p.bucket)})
+	return err
+}
+
+func probeBucketPolicy(ctx context.Context, p *prober) error {
+	// Deny writes under a prefix nothing uses, so the policy cannot get in the way.
+	policy := fmt.Sprintf(`{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:PutObject","Resource":"arn:aws:s3:::%s/policy-probe/*"}]}`, p.bucket)
+	if _, err := p.client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{Bucket: aws.String(p.bucket), Policy: aws.String(policy)}); err != nil {
+		return err
+	}
+	got, err := p.client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(p.bucket)})
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(aws.ToString(got.Policy), "policy-probe") {
+		return differs("read back a different policy")
+	}
+	_, err = p.client.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{Bucket: aws.String(p.bucket)})
+	return err
+}
+
+func probeBucketEncryption(ctx context.Context, p *prober) error {
+	if _, err := p.client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: aws.String(p.bucket),
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{Rules: []types.ServerSideEncryptionRule{{
+			ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{SSEAlgorithm: types.ServerSideEncryptionAes256},
+		}}},
+	}); err != nil {
+		return err
+	}
+	got, err := p.client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(p.bucket)})
+	if err != nil {
+		return err
+	}
+	if c := got.ServerSideEncryptionConfiguration; c == nil || len(c.Rules) != 1 || c.Rules[0].ApplyServerSideEncryptionByDefault == nil ||
+		c.Rules[0].ApplyServerSideEncryptionByDefault.SSEAlgorithm != types.ServerSideEncryptionAes256 {
+		return differs("read back a different default encryption")
+	}
+	_, err = p.client.DeleteBucketEncryption(ctx, &s3.DeleteBucketEncryptionInput{Bucket: aws.String(p.bucket)})
+	return err
+}
+
+func probePublicAccessBlock(ctx context.Context, p *prober) error {
+	if _, err := p.client.PutPublicAccessBlock(ctx, &s3.PutPublicAccessBlockInput{
+		Bucket: aws.String(p.bucket),
+		PublicAccessBlockConfiguration: &types.PublicAccessBlockConfiguration{
+			BlockPublicAcls:   aws.Bool(true),
+			BlockPublicPolicy: aws.Bool(true),
+		},
+	}); err != nil {
+		return err
+	}
+	got, err := p.client.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(p.bucket)})
+	if err != nil {
+		return err
+	}
+	if c := got.PublicAccessBlockConfiguration; c == nil || !aws.ToBool(c.BlockPublicAcls) || !aws.ToBool(c.BlockPublicPolicy) {
+		return differs("read back a different public access block")
+	}
+	_, err = p.client.DeletePublicAccessBlock(ctx, &s3.DeletePublicAccessBlockInput{Bucket: aws.String(p.bucket)})
+	return err
+}
+
+func probeWebsite(ctx context.Context, p *prober) error {
+	if _, err := p.client.PutBucketWebsite(ctx, &s3.PutBucketWebsiteInput{
+		Bucket:               aws.String(p.bucket),
+		WebsiteConfiguration: &types.WebsiteConfiguration{IndexDocument: &types.IndexDocument{Suffix: aws.String("index.html")}},
+	}); err != nil {
+		return err
+	}
+	got, err := p.client.GetBucketWebsite(ctx, &s3.GetBucketWebsiteInput{Bucket: aws.String(p.bucket)})
+	if err != nil {
+		return err
+	}
+	if got.IndexDocument == nil || aws.ToString(got.IndexDocument.Suffix) != "index.html" {
+		return differs("read back a different website configuration")
+	}
+	_, err = p.client.DeleteBucketWebsite(ctx, &s3.DeleteBucketWebsiteInput{Bucket: aws.String(p.bucket)})
+	return err
+}
+
+func probeObjectLock(ctx context.Context, p *prober) error {
+	bucket, err := p.newBucket(ctx, "lock", func(in *s3.CreateBucketInput) {
+		in.ObjectLockEnabledForBucket = aws.Bool(true)
+	})
+	if err != nil {
+		return err
+	}
+	got, err := p.client.GetObjectLockConfiguration(ctx, &s3.GetObjectLockConfigurationInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return err
+	}
+	if c := got.ObjectLockConfiguration; c == nil || c.ObjectLockEnabled != types.ObjectLockEnabledEnabled {
+		return differs("object lock not enabled on a bucket created with it")
+	}
+	return nil
+}
+
+func probeMetadata(ctx context.Context, p *prober) error {
+	if _, err := p.put(ctx, "meta.txt", "meta", func(in *s3.PutObjectInput) {
+		in.ContentType = aws.String("text/plain")
+		in.Metadata = map[string]string{"probe": "capability"}
+	}); err != nil {
+		return err
+	}
+	head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(p.bucket), Key: aws.String("meta.txt")})
+	if err != nil {
+		return err
+	}
+	if aws.ToString(head.ContentType) != "text/plain" || head.Metadata["probe"] != "capability" {
+		return differs("read back Content-Type %q and metadata %v", aws.ToString(head.ContentType), head.Metadata)
+	}
+	return nil
+}
+
+func probeRange(ctx context.Context, p *prober) error {
+	if _, err := p.put(ctx, "range.txt", "0123456789"); err != nil {
+		return err
+	}
+	got, err := p.get(ctx, "range.txt", func(in *s3.GetObjectInput) { in.Range = aws.String("bytes=2-5") })
+	if err != nil {
+		return err
+	}
+	if got != "2345" {
+		return differs("bytes=2-5 returned %q, want \"2345\"", got)
+	}
+	return nil
+}
+
+func probeConditional(ctx context.Context, p *prober) error {
+	put, err := p.put(ctx, "cond.txt", "cond")
+	if err != nil {
+		return err
+	}
+	_, err = p.get(ctx, "cond.txt", func(in *s3.GetObjectInput) { in.IfNoneMatch = put.ETag })
+	if got := httpStatus(err); got != http.StatusNotModified {
+		return differs("If-None-Match with the current ETag returned HTTP %d, want 304", got)
+	}
+	_, err = p.get(ctx, "cond.txt", func(in *s3.GetObjectInput) { in.IfMatch = aws.String(`"0000"`) })
+	if got := httpStatus(err); got != http.StatusPreconditionFailed {
+		return differs("If-Match with a stale ETag returned HTTP %d, want 412", got)
+	}
+	return nil
+}
+
+// httpStatus returns the HTTP status of a failed request, or 200 when err is nil.
+func httpStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		return status.HTTPStatusCode()
+	}
+	return 0
+}
+
+func probeObjectTagging(ctx context.Context, p *prober) error {
+	if _, err := p.put(ctx, "tagged.txt", "tags", func(in *s3.PutObjectInput) { in.Tagging = aws.String("probe=capability") }); err != nil {
+		return err
+	}
+	got, err := p.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String(p.bucket), Key: aws.String("tagged.txt")})
+	if err != nil {
+		return err
+	}
+	if len(got.TagSet) != 1 || aws.ToString(got.TagSet[0].Value) != "capability" {
+		return differs("read back %d tags, want probe=capability", len(got.TagSet))
+	}
+	_, err = p.client.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{Bucket: aws.String(p.bucket), Key: aws.String("tagged.txt")})
+	return err
+}
+
+func probeObjectACL(ctx context.Context, p *prober) error {
+	if _, err := p.put(ctx, "acl.txt", "acl"); err != nil {
+		return err
+	}
+	got, err := p.client.GetObjectAcl(ctx, &s3.GetObjectAclInput{Bucket: aws.String(p.bucket), Key: aws.String("acl.txt")})
+	if err != nil {
+		return err
+	}
+	if _, err := p.client.PutObjectAcl(ctx, &s3.PutObjectAclInput{
+		Bucket: aws.String(p.bucket), Key: aws.String("acl.txt"), ACL: types.ObjectCannedACLPrivate,
+	}); err != nil {
+		return err
+	}
+	if got.Owner == nil || len(got.Grants) == 0 {
+		return differs("ACL has no owner or grants")
+	}
+	return nil
+}
+
+func probeObjectAttributes(ctx context.Context, p *prober) error {
+	if _, err := p.put(ctx, "attrs.txt", "attributes"); err != nil {
+		return err
+	}
+	got, err := p.client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+		Bucket:           aws.String(p.bucket),
+		Key:              aws.String("attrs.txt"),
+		ObjectAttributes: []types.ObjectAttributes{types.ObjectAttributesEtag, types.ObjectAttributesObjectSize},
+	})
+	if err != nil {
+		return err
+	}
+	if aws.ToInt64(got.ObjectSize) != int64(len("attributes")) || got.ETag == nil {
+		return differs("ObjectSize %d and ETag %v, want %d and an ETag", aws.ToInt64(got.ObjectSize), got.ETag, len("attributes"))
+	}
+	return nil
+}
+
+func probeCopyReplace(ctx context.Context, p *prober) error {
+	if _, err := p.put(ctx, "copy-src.txt", "copy", func(in *s3.PutObjectInput) {
+		in.Metadata = map[string]string{"origin": "source"}
+	}); err != nil {
+		return err
+	}
+	if _, err := p.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(p.bucket),
+		Key:               aws.String("copy-dst.txt"),
+		CopySource:        aws.String(p.bucket + "/copy-src.txt"),
+		MetadataDirective: types.MetadataDirectiveReplace,
+		Metadata:          map[string]string{"origin": "copy"},
+	}); err != nil {
+		return err
+	}
+	head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(p.bucket), Key: aws.String("copy-dst.txt")})
+	if err != nil {
+		return err
+	}
+	if head.Metadata["origin"] != "copy" {
+		return differs("copy has metadata %v, want origin=copy", head.Metadata)
+	}
+	return nil
+}
+
+func probeDeleteObjects(ctx context.Context, p *prober) error {
+	var ids []types.ObjectIdentifier
+	for i := range 3 {
+		key := fmt.Sprintf("batch/%d.txt", i)
+		if _, err := p.put(ctx, key, "batch"); err != nil {
+			return err
+		}
+		ids = append(ids, types.ObjectIdentifier{Key: aws.String(key)})
+	}
+	out, err := p.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(p.bucket),
+		Delete: &types.Delete{Objects: ids},
+	})
+	if err != nil {
+		return err
+	}
+	if len(out.Deleted) != 3 || len(out.Errors) != 0 {
+		return differs("deleted %d and failed %d of 3 objects", len(out.Deleted), len(out.Errors))
+	}
+	left, err := p.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(p.bucket), Prefix: aws.String("batch/")})
+	if err != nil {
+		return err
+	}
+	if len(left.Contents) != 0 {
+		return differs("%d objects remain after DeleteObjects", len(left.Contents))
+	}
+	return nil
+}
+
+func probeSSES3(ctx context.Context, p *prober) error {
+	out, err := p.put(ctx, "sse-s3.txt", "sse", func(in *s3.PutObjectInput) {
+		in.ServerSideEncryption = types.ServerSideEncryptionAes256
+	})
+	if err != nil {
+		return err
+	}
+	if out.ServerSideEncryption != types.ServerSideEncryptionAes256 {
+		return differs("PutObject accepted AES256 but returned encryption %q", out.ServerSideEncryption)
+	}
+	return nil
+}
+
+func probeSSEC(ctx context.Context, p *prober) error {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	sum := md5.Sum(key)
+	withKey := func(alg, k, md5 **string) {
+		*alg = aws.String("AES256")
+		*k = aws.String(base64.StdEncoding.EncodeToString(key))
+		*md5 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	if _, err := p.put(ctx, "sse-c.txt", "customer key", func(in *s3.PutObjectInput) {
+		withKey(&in.SSECustomerAlgorithm, &in.SSECustomerKey, &in.SSECustomerKeyMD5)
+	}); err != nil {
+		return err
+	}
+	got, err := p.get(ctx, "sse-c.txt", func(in *s3.GetObjectInput) {
+		withKey(&in.SSECustomerAlgorithm, &in.SSECustomerKey, &in.SSECustomerKeyMD5)
+	})
+	if err != nil {
+		return err
+	}
+	if got != "customer key" {
+		return differs("read back %q with the customer key", got)
+	}
+	if _, err := p.get(ctx, "sse-c.txt"); err == nil {
+		return differs("object is readable without the customer key")
+	}
+	return nil
+}
+
+func probeMultipart(ctx context.Context, p *prober) error {
+	key := "mpu.bin"
+	create, err := p.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String(p.bucket), Key: aws.String(key)})
+	if err != nil {
+		return err
+	}
+	part, err := p.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket: aws.String(p.bucket), Key: aws.String(key), UploadId: create.UploadId,
+		PartNumber: aws.Int32(1), Body: strings.NewReader("only part"),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := p.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket: aws.String(p.bucket), Key: aws.String(key), UploadId: create.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: []types.CompletedPart{common.CompletedPart(1, part)}},
+	}); err != nil {
+		return err
+	}
+	got, err := p.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if got != "only part" {
+		return differs("completed object holds %q", got)
+	}
+	return nil
+}
+
+func probeListParts(ctx context.Context, p *prober) error {
+	key := "parts.bin"
+	create, err := p.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String(p.bucket), Key: aws.String(key)})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = p.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: aws.String(p.bucket), Key: aws.String(key), UploadId: create.UploadId})
+	}()
+	part, err := p.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket: aws.String(p.bucket), Key: aws.String(key), UploadId: create.UploadId,
+		PartNumber: aws.Int32(1), Body: strings.NewReader("listed part"),
+	})
+	if err != nil {
+		return err
+	}
+	parts, err := p.client.ListParts(ctx, &s3.ListPartsInput{Bucket: aws.String(p.bucket), Key: aws.String(key), UploadId: create.UploadId})
+	if err != nil {
+		return err
+	}
+	if len(parts.Parts) != 1 || aws.ToString(parts.Parts[0].ETag) != aws.ToString(part.ETag) {
+		return differs("ListParts returned %d parts, want part 1 with its ETag", len(parts.Parts))
+	}
+	return nil
+}
+
+func probeListUploads(ctx context.Context, p *prober) error {
+	key := "pending.bin"
+	create, err := p.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String(p.bucket), Key: aws.String(key)})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = p.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: aws.String(p.bucket), Key: aws.String(key), UploadId: create.UploadId})
+	}()
+	out, err := p.client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: aws.String(p.bucket)})
+	if err != nil {
+		return err
+	}
+	for _, u := range out.Uploads {
+		if aws.ToString(u.UploadId) == aws.ToString(create.UploadId) {
+			return nil
+		}
+	}
+	return differs("pending upload not listed")
+}
+
+func probeUploadPartCopy(ctx context.Context, p *prober) error {
+	if _, err := p.put(ctx, "upc-src.txt", "0123456789"); err != nil {
+		return err
+	}
+	key := "upc-dst.txt"
+	create, err := p.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String(p.bucket), Key: aws.String(key)})
+	if err != nil {
+		return err
+	}
+	part, err := p.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+		Bucket: aws.String(p.bucket), Key: aws.String(key), UploadId: create.UploadId, PartNumber: aws.Int32(1),
+		CopySource:      aws.String(p.bucket + "/upc-src.txt"),
+		CopySourceRange: aws.String("bytes=0-4"),
+	})
+	if err != nil {
+		_, _ = p.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: aws.String(p.bucket), Key: aws.String(key), UploadId: create.UploadId})
+		return err
+	}
+	if part.CopyPartResult == nil {
+		return differs("UploadPartCopy returned no CopyPartResult")
+	}
+	if _, err := p.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket: aws.String(p.bucket), Key: aws.String(key), UploadId: create.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: []types.CompletedPart{common.CopiedPart(1, part.CopyPartResult)}},
+	}); err != nil {
+		return err
+	}
+	got, err := p.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if got != "01234" {
+		return differs("copied range holds %q, want \"01234\"", got)
+	}
+	return nil
+}
+
+func probePresignGet(ctx context.Context, p *prober) error {
+	if _, err := p.put(ctx, "presigned-get.txt", "presigned"); err != nil {
+		return err
+	}
+	req, err := s3.NewPresignClient(p.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(p.bucket), Key: aws.String("presigned-get.txt"),
+	}, s3.WithPresignExpires(5*time.Minute))
+	if err != nil {
+		return err
+	}
+	status, body, err := presignedDo(ctx, req.Method, req.URL, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK || body != "presigned" {
+		return differs("presigned GET returned HTTP %d with %q", status, body)
+	}
+	return nil
+}
+
+func probePresignPut(ctx context.Context, p *prober) error {
+	req, err := s3.NewPresignClient(p.client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(p.bucket), Key: aws.String("presigned-put.txt"),
+	}, s3.WithPresignExpires(5*time.Minute))
+	if err != nil {
+		return err
+	}
+	status, _, err := presignedDo(ctx, req.Method, req.URL, []byte("uploaded"))
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return differs("presigned PUT returned HTTP %d", status)
+	}
+	got, err := p.get(ctx, "presigned-put.txt")
+	if err != nil {
+		return err
+	}
+	if got != "uploaded" {
+		return differs("presigned PUT stored %q", got)
+	}
+	return nil
+}
+
+// presignedDo sends a plain HTTP request to a presigned URL.
+func presignedDo(ctx context.Context, method, url string, body []byte) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), err
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// capability_probe tries a catalog of S3 operations against temporary buckets and
+// prints which ones the endpoint supports, as a Markdown or JSON matrix that can be
+// diffed between ACS releases.
+func main() {
+	format := flag.String("format", common.Env("PROBE_FORMAT", "markdown"), "output format: markdown or json")
+	outPath := flag.String("o", "", "write the matrix to this file instead of stdout")
+	flag.Parse()
+	if *format != "markdown" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "invalid -format %q (want markdown or json)\n", *format)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init error: %v\n", err)
+		os.Exit(1)
+	}
+
+	bucket, err := common.CreateTestBucket(ctx, client, common.Env("BUCKET_PREFIX", "capprobe"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	p := &prober{client: client, bucket: bucket, buckets: []string{bucket}}
+
+	fmt.Fprintf(os.Stderr, "Probing %s with bucket %s\n", cfg.Endpoint, bucket)
+	m := Matrix{Endpoint: cfg.Endpoint, Region: cfg.Region, AddressingStyle: cfg.AddressingStyle}
+	for _, pr := range catalog {
+		status, detail := classify(pr.run(ctx, p), p.buckets)
+		fmt.Fprintf(os.Stderr, "  %-24s %s\n", pr.name, status)
+		m.Capabilities = append(m.Capabilities, Capability{Name: pr.name, Category: pr.category, Status: status, Detail: detail})
+	}
+	p.cleanup(ctx)
+
+	out := os.Stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+	if *format == "json" {
+		err = m.WriteJSON(out)
+	} else {
+		err = m.WriteMarkdown(out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// prober holds the client and the buckets created for the probes.
+type prober struct {
+	client *s3.Client
+	// bucket is the shared bucket most probes use.
+	bucket string
+	// buckets lists every bucket to remove at the end, including bucket.
+	buckets []string
+}
+
+// newBucket creates an extra bucket for probes that change bucket-wide state
+// (versioning, object lock) and registers it for cleanup.
+func (p *prober) newBucket(ctx context.Context, suffix string, optFns ...func(*s3.CreateBucketInput)) (string, error) {
+	in := &s3.CreateBucketInput{Bucket: aws.String(p.bucket + "-" + suffix)}
+	for _, fn := range optFns {
+		fn(in)
+	}
+	if _, err := p.client.CreateBucket(ctx, in); err != nil {
+		return "", err
+	}
+	p.buckets = append(p.buckets, *in.Bucket)
+	return *in.Bucket, nil
+}
+
+// put writes a small object to the shared bucket.
+func (p *prober) put(ctx context.Context, key, body string, optFns ...func(*s3.PutObjectInput)) (*s3.PutObjectOutput, error) {
+	in := &s3.PutObjectInput{Bucket: aws.String(p.bucket), Key: aws.String(key), Body: common.BytesReader([]byte(body))}
+	for _, fn := range optFns {
+		fn(in)
+	}
+	return p.client.PutObject(ctx, in)
+}
+
+// get reads an object from the shared bucket.
+func (p *prober) get(ctx context.Context, key string, optFns ...func(*s3.GetObjectInput)) (string, error) {
+	in := &s3.GetObjectInput{Bucket: aws.String(p.bucket), Key: aws.String(key)}
+	for _, fn := range optFns {
+		fn(in)
+	}
+	out, err := p.client.GetObject(ctx, in)
+	if err != nil {
+		return "", err
+	}
+	data, err := common.ReadAll(out.Body)
+	return string(data), err
+}
+
+// cleanup empties and deletes every probe bucket, reporting anything left behind.
+func (p *prober) cleanup(ctx context.Context) {
+	for _, b := range p.buckets {
+		if report, err := common.EmptyAndDeleteBucket(ctx, p.client, b); err != nil {
+			fmt.Fprintf(os.Stderr, "cleanup incomplete: %s\n%v\n", report, err)
+		}
+	}
+}
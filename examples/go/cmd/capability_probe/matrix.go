@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+)
+
+// Status is the outcome of one capability probe.
+type Status string
+
+const (
+	// Supported means the operation succeeded and behaved like S3.
+	Supported Status = "supported"
+	// Unsupported means the endpoint answered NotImplemented (HTTP 501).
+	Unsupported Status = "unsupported"
+	// Differs means the operation failed another way, or succeeded with results S3 would not give.
+	Differs Status = "differs"
+)
+
+// Capability is one row of the matrix.
+type Capability struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Status   Status `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Matrix is the probe result for one endpoint. It holds no timestamps or
+// generated names, so runs against different releases diff cleanly.
+type Matrix struct {
+	Endpoint        string       `json:"endpoint"`
+	Region          string       `json:"region"`
+	AddressingStyle string       `json:"addressing_style"`
+	Capabilities    []Capability `json:"capabilities"`
+}
+
+// differsError reports an operation that succeeded but did not behave like S3.
+type differsError struct{ msg string }
+
+func (e *differsError) Error() string { return e.msg }
+
+func differs(format string, args ...any) error {
+	return &differsError{msg: fmt.Sprintf(format, args...)}
+}
+
+// classify maps a probe error to a status and a detail free of request IDs and
+// bucket names.
+func classify(err error, buckets []string) (Status, string) {
+	if err == nil {
+		return Supported, ""
+	}
+	var de *differsError
+	if errors.As(err, &de) {
+		return Differs, de.msg
+	}
+
+	op := ""
+	var opErr *smithy.OperationError
+	if errors.As(err, &opErr) {
+		op = opErr.OperationName + ": "
+	}
+	var respErr *awshttp.ResponseError
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented":
+		return Unsupported, op + "NotImplemented"
+	case errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotImplemented:
+		return Unsupported, op + "HTTP 501"
+	case apiErr != nil:
+		detail := op + apiErr.ErrorCode()
+		if msg := apiErr.ErrorMessage(); msg != "" {
+			detail += ": " + msg
+		}
+		return Differs, scrub(detail, buckets)
+	case respErr != nil:
+		// Leave out the request IDs, which change on every run.
+		return Differs, scrub(fmt.Sprintf("%sHTTP %d: %v", op, respErr.HTTPStatusCode(), respErr.Err), buckets)
+	}
+	return Differs, scrub(err.Error(), buckets)
+}
+
+// scrub replaces the generated bucket names, longest first, so details are stable across runs.
+func scrub(s string, buckets []string) string {
+	for i := len(buckets) - 1; i >= 0; i-- {
+		s = strings.ReplaceAll(s, buckets[i], "<bucket>")
+	}
+	return s
+}
+
+// WriteJSON writes the matrix as indented JSON.
+func (m Matrix) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// WriteMarkdown writes the matrix as a Markdown table with a per-status summary.
+func (m Matrix) WriteMarkdown(w io.Writer) error {
+	counts := map[Status]int{}
+	for _, c := range m.Capabilities {
+		counts[c.Status]++
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# ACS S3 compatibility matrix\n\n")
+	fmt.Fprintf(&b, "Endpoint: `%s` (region `%s`, %s addressing)\n\n", m.Endpoint, m.Region, m.AddressingStyle)
+	fmt.Fprintf(&b, "%d supported, %d unsupported, %d differs\n\n", counts[Supported], counts[Unsupported], counts[Differs])
+	b.WriteString("| Category | Capability | Status | Detail |\n")
+	b.WriteString("|---|---|---|---|\n")
+	for _, c := range m.Capabilities {
+		detail := strings.ReplaceAll(c.Detail, "|", `\|`)
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", c.Category, c.Name, c.Status, detail)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
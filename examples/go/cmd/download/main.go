@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"time"
+
+	"s3setup/internal/common"
+)
+
+// download fetches an object from ACS with parallel ranged GETs and verifies it.
+// Progress is kept in a sidecar state file next to the output, so an
+// interrupted download resumes where it stopped.
+func main() {
+	envPartMiB, err1 := common.EnvInt("DOWNLOAD_PART_SIZE_MIB", common.DefaultPartSize>>20)
+	envConcurrency, err2 := common.EnvInt("DOWNLOAD_CONCURRENCY", common.DefaultConcurrency)
+	if err := errors.Join(err1, err2); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	bucket := flag.String("bucket", common.Env("DOWNLOAD_BUCKET", ""), "source bucket (required)")
+	key := flag.String("key", "", "source key (required)")
+	partMiB := flag.Int64("part-size", envPartMiB, "range size in MiB")
+	concurrency := flag.Int("concurrency", int(envConcurrency), "ranges fetched at once")
+	retries := flag.Int("retries", common.DefaultPartRetries, "retries per failed range")
+	restart := flag.Bool("restart", false, "ignore any saved state and download from the start")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: download -bucket <bucket> -key <key> [flags] [output file]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *bucket == "" || *key == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	out := path.Base(*key)
+	if flag.NArg() == 1 {
+		out = flag.Arg(0)
+	}
+	if *partMiB < 1 {
+		fmt.Fprintf(os.Stderr, "invalid -part-size %d\n", *partMiB)
+		os.Exit(2)
+	}
+
+	// Ctrl-C stops the download; the state file keeps what was written.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init error: %v\n", err)
+		os.Exit(1)
+	}
+
+	start := time.Now()
+	d := common.NewDownloader(client, func(d *common.Downloader) {
+		d.PartSize = *partMiB << 20
+		d.Concurrency = *concurrency
+		d.PartRetries = *retries
+		d.Progress = func(done, total int64) { printProgress(done, total, start) }
+	})
+	obj, err := d.Stat(ctx, *bucket, *key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	st := loadState(statePath(out))
+	switch {
+	case st == nil || *restart:
+		st = newState(obj, d.PartSize)
+	case !st.matches(obj):
+		fmt.Println("The object changed since the saved download; starting over")
+		st = newState(obj, d.PartSize)
+	case !exists(out):
+		fmt.Printf("%s is missing; starting over\n", out)
+		st = newState(obj, d.PartSize)
+	default:
+		d.PartSize = st.PartSize
+		fmt.Printf("Resuming: %d of %d ranges already downloaded\n", len(st.Done), d.Ranges(obj.Size))
+	}
+
+	flags := os.O_RDWR | os.O_CREATE
+	if len(st.Done) == 0 {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(out, flags, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	if err := st.save(statePath(out)); err != nil {
+		fmt.Fprintf(os.Stderr, "state error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var saveErr error
+	d.RangeDone = func(i int) {
+		st.Done = append(st.Done, i)
+		if err := st.save(statePath(out)); err != nil && saveErr == nil {
+			saveErr = err
+		}
+	}
+
+	fmt.Printf("Downloading %s/%s/%s (%s) to %s\n", cfg.Endpoint, *bucket, *key, common.FormatBytes(obj.Size), out)
+	verified, err := d.Download(ctx, f, obj, st.done())
+	fmt.Fprintln(os.Stderr)
+	if saveErr != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not save download state: %v\n", saveErr)
+	}
+	if errors.Is(err, common.ErrIntegrity) {
+		// The ranges on disk cannot be trusted, so the next run starts over.
+		_ = os.Remove(statePath(out))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "download error: %v\n", err)
+		if !errors.Is(err, common.ErrIntegrity) {
+			fmt.Fprintf(os.Stderr, "Run the same command again to resume.\n")
+		}
+		os.Exit(1)
+	}
+	if err := f.Truncate(obj.Size); err != nil {
+		fmt.Fprintf(os.Stderr, "truncate error: %v\n", err)
+		os.Exit(1)
+	}
+	_ = os.Remove(statePath(out))
+
+	elapsed := time.Since(start)
+	fmt.Printf("Downloaded %s in %s (%s/s)\n", common.FormatBytes(obj.Size), elapsed.Round(time.Millisecond), common.FormatBytes(common.Rate(obj.Size, elapsed)))
+	if verified == "" {
+		fmt.Println("Not verified: the object has no usable checksum or ETag")
+	} else {
+		fmt.Printf("Verified: %s\n", verified)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// printProgress redraws the progress line on stderr.
+func printProgress(done, total int64, start time.Time) {
+	pct := 100.0
+	if total > 0 {
+		pct = float64(done) * 100 / float64(total)
+	}
+	fmt.Fprintf(os.Stderr, "\r%5.1f%%  %s / %s  %s/s   ", pct, common.FormatBytes(done), common.FormatBytes(total), common.FormatBytes(common.Rate(done, time.Since(start))))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"s3setup/internal/common"
+)
+
+// state is the sidecar file recording an unfinished download. It pins the
+// object by ETag, so a resume never mixes ranges of two versions.
+type state struct {
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	ETag      string `json:"etag"`
+	VersionID string `json:"version_id,omitempty"`
+	Size      int64  `json:"size"`
+	PartSize  int64  `json:"part_size"`
+	// Done lists the indexes of the ranges already written to the output file.
+	Done []int `json:"done"`
+}
+
+func statePath(out string) string { return out + ".acsdownload" }
+
+func newState(obj *common.ObjectInfo, partSize int64) *state {
+	return &state{
+		Bucket:    obj.Bucket,
+		Key:       obj.Key,
+		ETag:      obj.ETag,
+		VersionID: obj.VersionID,
+		Size:      obj.Size,
+		PartSize:  partSize,
+		Done:      []int{},
+	}
+}
+
+// loadState reads a saved state, or returns nil when there is none or it is unreadable.
+func loadState(path string) *state {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil || st.PartSize <= 0 {
+		return nil
+	}
+	return &st
+}
+
+// matches reports whether the saved download is of obj as it is now.
+func (s *state) matches(obj *common.ObjectInfo) bool {
+	return s.Bucket == obj.Bucket && s.Key == obj.Key && s.ETag == obj.ETag &&
+		s.VersionID == obj.VersionID && s.Size == obj.Size
+}
+
+func (s *state) done() map[int]bool {
+	m := make(map[int]bool, len(s.Done))
+	for _, i := range s.Done {
+		m[i] = true
+	}
+	return m
+}
+
+// save writes the state atomically with common.WriteFileAtomic.
+func (s *state) save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return common.WriteFileAtomic(path, data)
+}
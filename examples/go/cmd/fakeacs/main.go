@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"s3setup/internal/common"
+	"s3setup/internal/fakeiam"
+	"s3setup/internal/fakes3"
+)
+
+// fakeacs serves in-memory S3 and IAM endpoints on one listener, like ACS, so the
+// setup guides can run without network access.
+func main() {
+	addr := flag.String("addr", common.Env("FAKEACS_ADDR", "127.0.0.1:9000"), "listen address")
+	domain := flag.String("domain", "", "base domain for virtual-hosted bucket requests (<bucket>.<domain>)")
+	secretKey := flag.String("secret-key", common.Env("FAKEACS_SECRET_KEY", "test"), "secret key of every access key ID not created through the fake IAM, for verifying presigned URLs")
+	flag.Parse()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "listen error: %v\n", err)
+		os.Exit(1)
+	}
+
+	s3h := fakes3.NewHandler()
+	s3h.Domain = *domain
+	iamh := fakeiam.NewHandler()
+	s3h.SecretKey = iamh.SecretKeys(*secretKey)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fakeiam.IsQueryRequest(r) {
+			iamh.ServeHTTP(w, r)
+			return
+		}
+		s3h.ServeHTTP(w, r)
+	})
+
+	fmt.Printf("Serving fake ACS on http://%s\n", ln.Addr())
+	fmt.Printf("export S3_ENDPOINT=\"http://%s\"\n", ln.Addr())
+	fmt.Printf("export IAM_ENDPOINT=\"http://%s\"\n", ln.Addr())
+	if err := http.Serve(ln, handler); err != nil {
+		fmt.Fprintf(os.Stderr, "serve error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// bucketTimestamp is the layout of the UTC timestamp that follows the prefix in
+// names from common.NewTestBucketName, like smoketest-20250101120000-1a2b3c4d.
+const bucketTimestamp = "20060102150405"
+
+// iamBucketPrefix is the prefix the IAM example once named its buckets with,
+// followed by a UUID and no timestamp.
+const iamBucketPrefix = "iam-policy-test"
+
+var (
+	// testBucketName matches what follows the prefix in names from
+	// common.NewTestBucketName: the timestamp, an optional run ID and 8 hex digits.
+	testBucketName = regexp.MustCompile(`^-(\d{14})(?:-[a-z0-9-]+)?-[0-9a-f]{8}$`)
+	// uuidBucketName matches what follows iamBucketPrefix in the IAM example's
+	// older names.
+	uuidBucketName = regexp.MustCompile(`^-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+)
+
+// sweepBuckets empties and deletes buckets named like test buckets with one of
+// the prefixes and created before the cutoff. A bucket that only shares a
+// prefix, like copytest-archive, is left alone.
+func (j *janitor) sweepBuckets(ctx context.Context) {
+	fmt.Println("Buckets:")
+	pager := s3.NewListBucketsPaginator(j.clients.S3, &s3.ListBucketsInput{})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			j.fail("list buckets error: %v", err)
+			return
+		}
+		for _, b := range page.Buckets {
+			name := aws.ToString(b.Name)
+			prefix := j.matchPrefix(name)
+			if prefix == "" {
+				continue
+			}
+			created, source, ok := bucketCreated(name, prefix, aws.ToTime(b.CreationDate))
+			if !ok {
+				fmt.Printf("  %s: skipped, not named like a test bucket\n", name)
+				continue
+			}
+			if created.IsZero() {
+				fmt.Printf("  %s: skipped, no CreationDate\n", name)
+				continue
+			}
+			if !created.Before(j.cutoff) {
+				continue
+			}
+			fmt.Printf("  %s (created %s, from %s)\n", name, created.UTC().Format(time.RFC3339), source)
+			if j.dryRun {
+				j.buckets++
+				continue
+			}
+			report, err := common.EmptyAndDeleteBucket(ctx, j.clients.S3, name)
+			fmt.Printf("    %s\n", report)
+			if err != nil {
+				j.fail("%s: %v", name, err)
+				continue
+			}
+			if report.BucketDeleted {
+				j.buckets++
+			}
+		}
+	}
+}
+
+// matchPrefix returns the longest prefix that name starts with, followed by a
+// dash, or "" when none match.
+func (j *janitor) matchPrefix(name string) string {
+	match := ""
+	for _, p := range j.prefixes {
+		if strings.HasPrefix(name, p+"-") && len(p) > len(match) {
+			match = p
+		}
+	}
+	return match
+}
+
+// bucketCreated returns when a test bucket was created and where that came
+// from: the timestamp in a common.NewTestBucketName name, or CreationDate for
+// an iam-policy-test-<uuid> name. It reports false when name is neither.
+func bucketCreated(name, prefix string, creationDate time.Time) (time.Time, string, bool) {
+	rest := strings.TrimPrefix(name, prefix)
+	if m := testBucketName.FindStringSubmatch(rest); m != nil {
+		t, err := time.Parse(bucketTimestamp, m[1])
+		return t, "name", err == nil
+	}
+	if prefix == iamBucketPrefix && uuidBucketName.MatchString(rest) {
+		return creationDate, "CreationDate", true
+	}
+	return time.Time{}, "", false
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketCreated(t *testing.T) {
+	creationDate := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	named := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name, prefix string
+		want         time.Time
+		source       string
+		ok           bool
+	}{
+		{"smoketest-20250101120000-1a2b3c4d", "smoketest", named, "name", true},
+		{"smoketest-20250101120000-ci-1234-1a2b3c4d", "smoketest", named, "name", true},
+		{"iam-policy-test-20250101120000-1a2b3c4d", "iam-policy-test", named, "name", true},
+		{"iam-policy-test-0b6f1c2e-8a4d-4c1e-9f3a-2d5e7b9c1a0f", "iam-policy-test", creationDate, "CreationDate", true},
+		{"copytest-0b6f1c2e-8a4d-4c1e-9f3a-2d5e7b9c1a0f", "copytest", time.Time{}, "", false},
+		{"copytest-archive", "copytest", time.Time{}, "", false},
+		{"smoketest-prod", "smoketest", time.Time{}, "", false},
+		{"smoketest-20250101120000", "smoketest", time.Time{}, "", false},
+		{"smoketest-20250101120000-backup", "smoketest", time.Time{}, "", false},
+		{"smoketest-20251399120000-1a2b3c4d", "smoketest", time.Time{}, "name", false},
+	} {
+		got, source, ok := bucketCreated(tc.name, tc.prefix, creationDate)
+		if ok != tc.ok || source != tc.source || !got.Equal(tc.want) {
+			t.Errorf("bucketCreated(%q) = %s, %q, %v, want %s, %q, %v", tc.name, got, source, ok, tc.want, tc.source, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// policyPrefix is the name prefix of the bucket-scoped policies iam_examples creates.
+const policyPrefix = "S3BucketPolicy-"
+
+// sweepAccessKeys deletes inactive access keys created before the cutoff,
+// first detaching the policies attached to them. Only keys whose attached
+// policies are all S3BucketPolicy-* test policies are swept, unless allKeys
+// is set; keys with no policy at all may belong to anyone. Active keys are
+// never touched.
+func (j *janitor) sweepAccessKeys(ctx context.Context) {
+	fmt.Println("Inactive access keys:")
+	pager := iam.NewListAccessKeysPaginator(j.clients.IAM, &iam.ListAccessKeysInput{})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			j.fail("list access keys error: %v", err)
+			return
+		}
+		for _, k := range page.AccessKeyMetadata {
+			if k.Status != iamtypes.StatusTypeInactive || !aws.ToTime(k.CreateDate).Before(j.cutoff) {
+				continue
+			}
+			id := aws.ToString(k.AccessKeyId)
+			attached, err := j.clients.IAM.ListAttachedUserPolicies(ctx, &iam.ListAttachedUserPoliciesInput{UserName: aws.String(id)})
+			if err != nil {
+				j.fail("list attached user policies error: %v", err)
+				continue
+			}
+			if !j.allKeys && !testKey(attached.AttachedPolicies) {
+				continue
+			}
+			fmt.Printf("  %s**** (created %s)\n", id[:min(4, len(id))], aws.ToTime(k.CreateDate).UTC().Format(time.RFC3339))
+			if !j.detachAll(ctx, id, attached.AttachedPolicies) {
+				continue
+			}
+			if !j.dryRun {
+				if _, err := j.clients.IAM.DeleteAccessKey(ctx, &iam.DeleteAccessKeyInput{AccessKeyId: k.AccessKeyId}); err != nil {
+					j.fail("delete access key error: %v", err)
+					continue
+				}
+			}
+			j.keys++
+		}
+	}
+}
+
+// testKey reports whether a key's attached policies mark it as created by a
+// test: there is at least one, and every one is an S3BucketPolicy-* policy.
+func testKey(policies []iamtypes.AttachedPolicy) bool {
+	for _, p := range policies {
+		if !strings.HasPrefix(aws.ToString(p.PolicyName), policyPrefix) {
+			return false
+		}
+	}
+	return len(policies) > 0
+}
+
+// detachAll detaches policies from an access key, which ACS treats as the
+// user name. It records each detached policy so sweepPolicies counts it as
+// unattached, even in a dry run, and reports whether all were detached.
+func (j *janitor) detachAll(ctx context.Context, keyID string, policies []iamtypes.AttachedPolicy) bool {
+	for _, p := range policies {
+		fmt.Printf("    detach %s\n", aws.ToString(p.PolicyName))
+		if !j.dryRun {
+			if _, err := j.clients.IAM.DetachUserPolicy(ctx, &iam.DetachUserPolicyInput{UserName: aws.String(keyID), PolicyArn: p.PolicyArn}); err != nil {
+				j.fail("detach user policy error: %v", err)
+				return false
+			}
+		}
+		if j.detached == nil {
+			j.detached = map[string]int{}
+		}
+		j.detached[aws.ToString(p.PolicyArn)]++
+	}
+	return true
+}
+
+// sweepPolicies deletes S3BucketPolicy-* policies created before the cutoff that
+// are no longer attached to anything.
+func (j *janitor) sweepPolicies(ctx context.Context) {
+	fmt.Println("Orphaned policies:")
+	pager := iam.NewListPoliciesPaginator(j.clients.IAM, &iam.ListPoliciesInput{Scope: iamtypes.PolicyScopeTypeLocal})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			j.fail("list policies error: %v", err)
+			return
+		}
+		for _, p := range page.Policies {
+			arn := aws.ToString(p.Arn)
+			if !strings.HasPrefix(aws.ToString(p.PolicyName), policyPrefix) ||
+				!aws.ToTime(p.CreateDate).Before(j.cutoff) ||
+				int(aws.ToInt32(p.AttachmentCount))-j.detached[arn] > 0 {
+				continue
+			}
+			fmt.Printf("  %s (created %s)\n", aws.ToString(p.PolicyName), aws.ToTime(p.CreateDate).UTC().Format(time.RFC3339))
+			if !j.dryRun {
+				if _, err := j.clients.IAM.DeletePolicy(ctx, &iam.DeletePolicyInput{PolicyArn: p.Arn}); err != nil {
+					j.fail("delete policy error: %v", err)
+					continue
+				}
+			}
+			j.policies++
+		}
+	}
+}
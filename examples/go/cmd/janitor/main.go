@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"s3setup/internal/common"
+)
+
+// defaultPrefixes are the bucket name prefixes the guides, the suite and the
+// tools in cmd/ use for the buckets they create.
+const defaultPrefixes = "smoketest,objecttest,copytest,crosscopytest,mpuploadtest,checksumtest,signingtest,presigntest,acs-bucket-test,versiontest,iam-policy-test,capprobe,acs-doctor"
+
+// janitor removes test buckets, S3BucketPolicy-* IAM policies and the inactive
+// access keys they are attached to that crashed runs left behind, once they are
+// older than a TTL.
+func main() {
+	envTTL, err := envDuration("JANITOR_TTL", 24*time.Hour)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ttl := flag.Duration("ttl", envTTL, "only remove resources older than this")
+	prefixes := flag.String("prefixes", common.Env("JANITOR_PREFIXES", defaultPrefixes), "comma-separated bucket name prefixes to sweep")
+	dryRun := flag.Bool("dry-run", false, "print what would be removed without removing it")
+	skipIAM := flag.Bool("skip-iam", false, "only sweep buckets")
+	allKeys := flag.Bool("all-inactive-keys", false, "delete every inactive access key older than the TTL, not only those with S3BucketPolicy-* policies attached")
+	flag.Parse()
+
+	ctx := context.Background()
+	clients, cfg, err := common.NewClients(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init error: %v\n", err)
+		os.Exit(1)
+	}
+
+	j := &janitor{
+		clients:  clients,
+		cutoff:   time.Now().Add(-*ttl),
+		prefixes: splitList(*prefixes),
+		dryRun:   *dryRun,
+		allKeys:  *allKeys,
+	}
+	fmt.Printf("Using endpoint: %s\n", cfg.Endpoint)
+	fmt.Printf("Removing resources created before %s (TTL %s)\n", j.cutoff.UTC().Format(time.RFC3339), *ttl)
+	if j.dryRun {
+		fmt.Println("Dry run: nothing will be removed")
+	}
+
+	j.sweepBuckets(ctx)
+	if !*skipIAM {
+		j.sweepAccessKeys(ctx)
+		j.sweepPolicies(ctx)
+	}
+
+	verb := "Removed"
+	if j.dryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d buckets, %d access keys and %d policies\n", verb, j.buckets, j.keys, j.policies)
+	if j.failures > 0 {
+		fmt.Printf("❌ %d failures\n", j.failures)
+		os.Exit(1)
+	}
+}
+
+// janitor holds the sweep settings and tallies.
+type janitor struct {
+	clients  common.Clients
+	cutoff   time.Time
+	prefixes []string
+	dryRun   bool
+	// allKeys sweeps every inactive access key, not only test keys.
+	allKeys bool
+
+	// detached counts, per policy ARN, attachments removed (or that would be
+	// removed in a dry run) from swept access keys.
+	detached map[string]int
+
+	buckets, keys, policies, failures int
+}
+
+func (j *janitor) fail(format string, args ...any) {
+	j.failures++
+	fmt.Printf("  FAILED: "+format+"\n", args...)
+}
+
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v := common.Env(key, "")
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	return d, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// checkpoint is an append-only JSON-lines file: a header naming the source
+// and destination, then one record per migrated object. Appending keeps the
+// cost per object constant however many objects a migration has; a line cut
+// short by a crash is ignored on load, so that object is simply copied again.
+type checkpoint struct {
+	path string
+
+	// mu guards records and f: workers add records while run still asks done.
+	mu      sync.Mutex
+	records map[string]record
+	f       *os.File
+}
+
+type checkpointHeader struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+}
+
+// record is one migrated object, keyed by its source key.
+type record struct {
+	Key        string `json:"key"`
+	Size       int64  `json:"size"`
+	SourceETag string `json:"source_etag"`
+	DestETag   string `json:"dest_etag"`
+	// MD5 is the hex MD5 of the bytes streamed from source to destination.
+	MD5 string `json:"md5"`
+	// EndToEnd is set when MD5 matched the source ETag, so the copy is checked
+	// against the source and not only against what was read from it.
+	EndToEnd bool `json:"end_to_end"`
+}
+
+// openCheckpoint loads the checkpoint at path, or starts one when there is none
+// or restart is set. A checkpoint of a different source or destination is an
+// error, so a typo cannot skip objects that were never copied.
+func openCheckpoint(path string, h checkpointHeader, restart bool) (*checkpoint, error) {
+	c := &checkpoint{path: path, records: map[string]record{}}
+	if !restart {
+		if err := c.load(h); err != nil {
+			return nil, err
+		}
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if restart || len(c.records) == 0 {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	c.f = f
+	if flags&os.O_TRUNC != 0 {
+		err = c.append(h)
+	} else {
+		// End a line a crash may have cut short, so the next record starts on
+		// its own line.
+		_, err = f.Write([]byte{'\n'})
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *checkpoint) load(want checkpointHeader) error {
+	f, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !sc.Scan() {
+		return sc.Err()
+	}
+	var h checkpointHeader
+	if err := json.Unmarshal(sc.Bytes(), &h); err != nil {
+		return fmt.Errorf("checkpoint %s: %w", c.path, err)
+	}
+	if h != want {
+		return fmt.Errorf("checkpoint %s is for %s -> %s; use -restart or another -checkpoint", c.path, h.Source, h.Dest)
+	}
+	for sc.Scan() {
+		var r record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil || r.Key == "" {
+			continue
+		}
+		c.records[r.Key] = r
+	}
+	return sc.Err()
+}
+
+// done reports whether src was migrated at the size and ETag it has now, to
+// the destination object that is listed as dst.
+func (c *checkpoint) done(src, dst listedObject) bool {
+	c.mu.Lock()
+	r, ok := c.records[src.key]
+	c.mu.Unlock()
+	return ok && r.Size == src.size && r.SourceETag == src.etag && sameETag(r.DestETag, dst.etag)
+}
+
+// add records a migrated object.
+func (c *checkpoint) add(r record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records[r.Key] = r
+	return c.append(r)
+}
+
+func (c *checkpoint) append(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = c.f.Write(append(data, '\n'))
+	return err
+}
+
+func (c *checkpoint) Close() error { return c.f.Close() }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestCheckpointTruncatedLine reopens a checkpoint whose last record a crash
+// cut short: the records before it load, it is skipped, and the next record
+// appended after it still loads.
+func TestCheckpointTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.acsmigrate")
+	h := checkpointHeader{Source: "s3://src", Dest: "s3://dst"}
+	c, err := openCheckpoint(path, h, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if err := c.add(record{Key: key, Size: 1, SourceETag: `"` + key + `"`, DestETag: `"` + key + `"`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"key":"c","size":1,"source_et`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	c, err = openCheckpoint(path, h, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(c); got != "a,b" {
+		t.Errorf("loaded records %s, want a,b", got)
+	}
+	if err := c.add(record{Key: "d", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	c, err = openCheckpoint(path, h, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got := keys(c); got != "a,b,d" {
+		t.Errorf("loaded records %s after another append, want a,b,d", got)
+	}
+	if !c.done(listedObject{key: "a", size: 1, etag: `"a"`}, listedObject{key: "a", etag: `"a"`}) {
+		t.Error("a not done after reloading")
+	}
+}
+
+func TestCheckpointOtherMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.acsmigrate")
+	c, err := openCheckpoint(path, checkpointHeader{Source: "s3://src", Dest: "s3://dst"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.add(record{Key: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	other := checkpointHeader{Source: "s3://src", Dest: "s3://typo"}
+	if _, err := openCheckpoint(path, other, false); err == nil || !strings.Contains(err.Error(), "use -restart") {
+		t.Fatalf("open for another destination: %v, want an error", err)
+	}
+	c, err = openCheckpoint(path, other, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got := keys(c); got != "" {
+		t.Errorf("records %s after -restart, want none", got)
+	}
+}
+
+// keys lists the keys of c's records, sorted.
+func keys(c *checkpoint) string {
+	var ks []string
+	for k := range c.records {
+		ks = append(ks, k)
+	}
+	slices.Sort(ks)
+	return strings.Join(ks, ",")
+}
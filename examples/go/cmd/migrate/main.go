@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"s3setup/internal/common"
+)
+
+// migrate copies every object under a bucket prefix on a source endpoint (AWS
+// S3 or any S3-compatible service) to a bucket prefix on ACS, carrying content
+// type, user metadata and tags, and verifies the result. The source is
+// configured with SOURCE_-prefixed settings (see common.LoadSettingsFor), the
+// destination like every other tool.
+func main() {
+	envConcurrency, err1 := common.EnvInt("MIGRATE_CONCURRENCY", common.DefaultConcurrency)
+	envPartMiB, err2 := common.EnvInt("MIGRATE_PART_SIZE_MIB", common.DefaultPartSize>>20)
+	if err := errors.Join(err1, err2); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	sourceProfile := flag.String("source-profile", "", "ACS profile for the source (default $SOURCE_ACS_PROFILE)")
+	destProfile := flag.String("dest-profile", "", "ACS profile for the destination (default $ACS_PROFILE)")
+	concurrency := flag.Int("concurrency", int(envConcurrency), "objects copied at once")
+	partSizeMiB := flag.Int64("part-size", envPartMiB, "multipart part size in MiB")
+	checkpointPath := flag.String("checkpoint", "", "checkpoint file (default migrate-<source bucket>-<dest bucket>.acsmigrate)")
+	restart := flag.Bool("restart", false, "ignore the checkpoint and copy every object again")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: migrate [flags] [s3://]<source bucket>[/<prefix>] [s3://]<dest bucket>[/<prefix>]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 || *concurrency < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	srcBucket, srcPrefix := parseLocation(flag.Arg(0))
+	dstBucket, dstPrefix := parseLocation(flag.Arg(1))
+	if srcBucket == "" || dstBucket == "" {
+		fmt.Fprintln(os.Stderr, "both locations need a bucket")
+		os.Exit(2)
+	}
+	if *checkpointPath == "" {
+		*checkpointPath = fmt.Sprintf("migrate-%s-%s.acsmigrate", srcBucket, dstBucket)
+	}
+
+	// Ctrl-C stops new copies and cancels the running ones; the checkpoint
+	// keeps the finished ones.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	src, srcCfg, err := common.NewS3ClientFor(ctx, "SOURCE_", *sourceProfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "source init error: %v\n", err)
+		os.Exit(1)
+	}
+	dst, dstCfg, err := common.NewS3ClientFor(ctx, "", *destProfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "destination init error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Source endpoint:      %s\n", srcCfg.Endpoint)
+	fmt.Printf("Destination endpoint: %s\n", dstCfg.Endpoint)
+
+	header := checkpointHeader{
+		Source: fmt.Sprintf("%s s3://%s/%s", srcCfg.Endpoint, srcBucket, srcPrefix),
+		Dest:   fmt.Sprintf("%s s3://%s/%s", dstCfg.Endpoint, dstBucket, dstPrefix),
+	}
+	ckpt, err := openCheckpoint(*checkpointPath, header, *restart)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "checkpoint error: %v\n", err)
+		os.Exit(1)
+	}
+	defer ckpt.Close()
+
+	m := &migrator{
+		src: src, dst: dst,
+		srcBucket: srcBucket, srcPrefix: srcPrefix,
+		dstBucket: dstBucket, dstPrefix: dstPrefix,
+		uploader: common.NewUploader(dst, func(u *common.Uploader) {
+			u.PartSize = *partSizeMiB << 20
+		}),
+		checkpoint: ckpt,
+	}
+	if _, err := common.PartSizeFor(m.uploader.PartSize, 0); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -part-size: %v\n", err)
+		os.Exit(2)
+	}
+
+	objects, err := m.listSource(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate error: %v\n", err)
+		os.Exit(1)
+	}
+	dest, err := m.listDest(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate error: %v\n", err)
+		os.Exit(1)
+	}
+	if n := len(ckpt.records); n > 0 {
+		fmt.Printf("Resuming from %s (%d objects recorded)\n", *checkpointPath, n)
+	}
+	start := time.Now()
+	m.run(ctx, objects, dest, *concurrency)
+	elapsed := time.Since(start)
+	fmt.Printf("Migrated %d objects (%s) in %s (%s/s); %d already migrated\n",
+		m.copied, common.FormatBytes(m.copiedBytes), elapsed.Round(time.Millisecond),
+		common.FormatBytes(common.Rate(m.copiedBytes, elapsed)), m.skipped)
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "migrate interrupted; run the same command again to resume")
+		os.Exit(1)
+	}
+
+	v, err := m.verify(ctx, objects)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verify error: %v\n", err)
+		os.Exit(1)
+	}
+	v.print()
+	if m.failed > 0 {
+		fmt.Printf("❌ %d failures\n", m.failed)
+	}
+	if m.failed > 0 || !v.ok() {
+		fmt.Println("Run the same command again to retry the objects that are not migrated.")
+		os.Exit(1)
+	}
+	fmt.Println("✅ Migration verified")
+}
+
+// parseLocation splits [s3://]bucket/prefix. A non-empty prefix always ends in
+// "/", so bucket/dir and bucket/dir/ name the same objects.
+func parseLocation(s string) (bucket, prefix string) {
+	bucket, prefix, _ = strings.Cut(strings.TrimPrefix(s, "s3://"), "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return bucket, prefix
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"s3setup/internal/common"
+)
+
+// verification compares the source listing with the destination listing and
+// the checkpoint.
+type verification struct {
+	sourceObjects, destObjects int
+	sourceBytes, destBytes     int64
+	endToEnd, destOnly         int
+	missing, sizeMismatch      []string
+	checksumMismatch           []string
+	extra                      int
+}
+
+// verify lists the destination prefix and checks every source object against
+// it: the object must exist with the source size, and its ETag must be the one
+// recorded when the streamed bytes were checked.
+func (m *migrator) verify(ctx context.Context, source []listedObject) (*verification, error) {
+	dest, err := m.listDest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &verification{destObjects: len(dest)}
+	for _, o := range dest {
+		v.destBytes += o.size
+	}
+	for _, o := range source {
+		v.sourceObjects++
+		v.sourceBytes += o.size
+		d, ok := dest[m.dstKey(o.key)]
+		if !ok {
+			v.missing = append(v.missing, o.key)
+			continue
+		}
+		delete(dest, d.key)
+		if d.size != o.size {
+			v.sizeMismatch = append(v.sizeMismatch, o.key)
+			continue
+		}
+		r, ok := m.checkpoint.records[o.key]
+		if !ok || r.SourceETag != o.etag || !sameETag(r.DestETag, d.etag) {
+			v.checksumMismatch = append(v.checksumMismatch, o.key)
+			continue
+		}
+		if r.EndToEnd {
+			v.endToEnd++
+		} else {
+			v.destOnly++
+		}
+	}
+	v.extra = len(dest)
+	return v, nil
+}
+
+func (v *verification) ok() bool {
+	return len(v.missing) == 0 && len(v.sizeMismatch) == 0 && len(v.checksumMismatch) == 0
+}
+
+func (v *verification) print() {
+	fmt.Println("Verification:")
+	fmt.Printf("  Objects:   %d in source, %d in destination", v.sourceObjects, v.destObjects)
+	if v.extra > 0 {
+		fmt.Printf(" (%d not in source)", v.extra)
+	}
+	fmt.Println()
+	fmt.Printf("  Bytes:     %s in source, %s in destination\n", common.FormatBytes(v.sourceBytes), common.FormatBytes(v.destBytes))
+	fmt.Printf("  Checksums: %d match the source MD5, %d match the MD5 read from the source (the source ETag is not an MD5)\n", v.endToEnd, v.destOnly)
+	printKeys("Missing", v.missing)
+	printKeys("Size mismatch", v.sizeMismatch)
+	printKeys("Checksum mismatch", v.checksumMismatch)
+}
+
+// printKeys prints up to ten keys of a problem list.
+func printKeys(label string, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	fmt.Printf("  %s: %d\n", label, len(keys))
+	for i, k := range keys {
+		if i == 10 {
+			fmt.Printf("    ... and %d more\n", len(keys)-10)
+			break
+		}
+		fmt.Printf("    %s\n", k)
+	}
+}
+
+// sameETag compares ETags with or without their quotes. An empty ETag (an
+// object that is not listed) matches nothing.
+func sameETag(a, b string) bool {
+	a, b = strings.Trim(a, `"`), strings.Trim(b, `"`)
+	return a != "" && a == b
+}
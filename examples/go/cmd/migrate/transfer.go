@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// migrator holds the migration settings and tallies.
+type migrator struct {
+	src, dst     *s3.Client
+	srcBucket    string
+	srcPrefix    string
+	dstBucket    string
+	dstPrefix    string
+	uploader     *common.Uploader
+	checkpoint   *checkpoint
+	tagsWarnOnce sync.Once
+
+	mu                      sync.Mutex
+	copied, skipped, failed int
+	copiedBytes             int64
+}
+
+// listedObject is one object of a listing.
+type listedObject struct {
+	key  string
+	size int64
+	etag string
+}
+
+func (m *migrator) dstKey(srcKey string) string {
+	return m.dstPrefix + strings.TrimPrefix(srcKey, m.srcPrefix)
+}
+
+// listSource lists every object under the source prefix.
+func (m *migrator) listSource(ctx context.Context) ([]listedObject, error) {
+	var objects []listedObject
+	err := list(ctx, m.src, m.srcBucket, m.srcPrefix, func(o listedObject) { objects = append(objects, o) })
+	if err != nil {
+		return nil, fmt.Errorf("list source objects error: %w", err)
+	}
+	return objects, nil
+}
+
+// listDest lists every object under the destination prefix by key.
+func (m *migrator) listDest(ctx context.Context) (map[string]listedObject, error) {
+	objects := map[string]listedObject{}
+	err := list(ctx, m.dst, m.dstBucket, m.dstPrefix, func(o listedObject) { objects[o.key] = o })
+	if err != nil {
+		return nil, fmt.Errorf("list destination objects error: %w", err)
+	}
+	return objects, nil
+}
+
+func list(ctx context.Context, client *s3.Client, bucket, prefix string, fn func(listedObject)) error {
+	pager := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix)})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, o := range page.Contents {
+			fn(listedObject{key: aws.ToString(o.Key), size: aws.ToInt64(o.Size), etag: aws.ToString(o.ETag)})
+		}
+	}
+	return nil
+}
+
+// run migrates the objects that are not done with up to concurrency workers.
+// An object is done when the checkpoint has it at its current size and ETag
+// and dest still lists the ETag it was copied with.
+func (m *migrator) run(ctx context.Context, objects []listedObject, dest map[string]listedObject, concurrency int) {
+	queue := make(chan listedObject)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range queue {
+				m.migrate(ctx, o)
+			}
+		}()
+	}
+feed:
+	for _, o := range objects {
+		if m.checkpoint.done(o, dest[m.dstKey(o.key)]) {
+			m.mu.Lock()
+			m.skipped++
+			m.mu.Unlock()
+			continue
+		}
+		select {
+		case queue <- o:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (m *migrator) migrate(ctx context.Context, o listedObject) {
+	r, err := m.stream(ctx, o)
+	if err == nil {
+		err = m.checkpoint.add(*r)
+		if err != nil {
+			err = fmt.Errorf("checkpoint error: %w", err)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.failed++
+		fmt.Printf("  FAILED: %s: %v\n", o.key, err)
+		return
+	}
+	m.copied++
+	m.copiedBytes += o.size
+	fmt.Printf("migrated: s3://%s/%s to s3://%s/%s (%s)\n", m.srcBucket, o.key, m.dstBucket, m.dstKey(o.key), common.FormatBytes(o.size))
+}
+
+// stream copies one object by piping its GET body into an upload, as a
+// multipart upload when it is at least one part. The bytes are hashed on the
+// way through: the destination ETag must be their MD5 (or, for multipart, the
+// MD5 of the part MD5s), and when the source ETag is an MD5 it must match too.
+func (m *migrator) stream(ctx context.Context, o listedObject) (*record, error) {
+	get, err := m.src.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(m.srcBucket), Key: aws.String(o.key), IfMatch: aws.String(o.etag)})
+	if err != nil {
+		return nil, fmt.Errorf("get object error: %w", err)
+	}
+	defer get.Body.Close()
+	tags, err := m.tags(ctx, o.key)
+	if err != nil {
+		return nil, err
+	}
+
+	partSize, err := common.PartSizeFor(m.uploader.PartSize, o.size)
+	if err != nil {
+		return nil, err
+	}
+	h := newPartHasher(partSize)
+	res, err := m.uploader.Upload(ctx, &common.UploadInput{
+		Bucket:      m.dstBucket,
+		Key:         m.dstKey(o.key),
+		Body:        io.TeeReader(get.Body, h),
+		Size:        o.size,
+		ContentType: aws.ToString(get.ContentType),
+		Metadata:    get.Metadata,
+		Tagging:     tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sum := hex.EncodeToString(h.whole.Sum(nil))
+	want := sum
+	if res.UploadID != "" {
+		want = h.multipartETag()
+	}
+	if got := strings.Trim(res.ETag, `"`); got != want {
+		return nil, fmt.Errorf("%w: destination ETag is %s, want %s", common.ErrIntegrity, got, want)
+	}
+	if res.Size != o.size {
+		return nil, fmt.Errorf("%w: streamed %d bytes, source is %d", common.ErrIntegrity, res.Size, o.size)
+	}
+	r := &record{Key: o.key, Size: o.size, SourceETag: o.etag, DestETag: res.ETag, MD5: sum}
+	srcETag := strings.Trim(o.etag, `"`)
+	encrypted := get.ServerSideEncryption == types.ServerSideEncryptionAwsKms || get.SSECustomerAlgorithm != nil
+	if !encrypted && !strings.Contains(srcETag, "-") {
+		if srcETag != sum {
+			return nil, fmt.Errorf("%w: read MD5 %s, source ETag is %s", common.ErrIntegrity, sum, srcETag)
+		}
+		r.EndToEnd = true
+	}
+	return r, nil
+}
+
+// tags returns the source object's tag set as a URL-encoded query. An endpoint
+// without object tagging (NotImplemented) is warned about once and its objects
+// are copied without tags.
+func (m *migrator) tags(ctx context.Context, key string) (string, error) {
+	out, err := m.src.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String(m.srcBucket), Key: aws.String(key)})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented" {
+		m.tagsWarnOnce.Do(func() {
+			fmt.Println("warning: the source does not implement object tagging; objects are copied without tags")
+		})
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get object tagging error: %w", err)
+	}
+	q := url.Values{}
+	for _, t := range out.TagSet {
+		q.Set(aws.ToString(t.Key), aws.ToString(t.Value))
+	}
+	return q.Encode(), nil
+}
+
+// partHasher computes the MD5 of everything written to it and of every
+// partSize chunk, from which the ETag of a multipart upload follows.
+type partHasher struct {
+	partSize int64
+	whole    hash.Hash
+	part     hash.Hash
+	n        int64
+	sums     []byte
+	parts    int
+}
+
+func newPartHasher(partSize int64) *partHasher {
+	return &partHasher{partSize: partSize, whole: md5.New(), part: md5.New()}
+}
+
+func (h *partHasher) Write(p []byte) (int, error) {
+	h.whole.Write(p)
+	written := len(p)
+	for len(p) > 0 {
+		k := min(int64(len(p)), h.partSize-h.n)
+		h.part.Write(p[:k])
+		h.n += k
+		p = p[k:]
+		if h.n == h.partSize {
+			h.endPart()
+		}
+	}
+	return written, nil
+}
+
+func (h *partHasher) endPart() {
+	h.sums = h.part.Sum(h.sums)
+	h.parts++
+	h.part.Reset()
+	h.n = 0
+}
+
+// multipartETag returns the ETag of the bytes written, uploaded in parts of
+// partSize. It must be called once, after the last write.
+func (h *partHasher) multipartETag() string {
+	if h.n > 0 {
+		h.endPart()
+	}
+	sum := md5.Sum(h.sums)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), h.parts)
+}
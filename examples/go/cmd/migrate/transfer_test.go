@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestPartHasher(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		partSize int64
+		writes   []int
+		parts    int
+	}{
+		{"below one part", 10, []int{7}, 1},
+		{"exactly one part", 10, []int{10}, 1},
+		{"writes on part boundaries", 10, []int{10, 10, 10}, 3},
+		{"writes straddling boundaries", 10, []int{7, 7, 7, 9}, 3},
+		{"one write of several parts", 10, []int{35}, 4},
+		{"a byte at a time", 10, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, 2},
+		{"empty writes", 10, []int{0, 10, 0, 1, 0}, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var data []byte
+			h := newPartHasher(tc.partSize)
+			for _, n := range tc.writes {
+				p := make([]byte, n)
+				for i := range p {
+					p[i] = byte(len(data) + i)
+				}
+				if k, err := h.Write(p); k != n || err != nil {
+					t.Fatalf("Write(%d bytes) = %d, %v", n, k, err)
+				}
+				data = append(data, p...)
+			}
+
+			// S3's multipart ETag: the MD5 of the parts' MD5s, then the count.
+			var sums []byte
+			for off := 0; off < len(data); off += int(tc.partSize) {
+				sum := md5.Sum(data[off:min(off+int(tc.partSize), len(data))])
+				sums = append(sums, sum[:]...)
+			}
+			sum := md5.Sum(sums)
+			want := fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), tc.parts)
+			if got := h.multipartETag(); got != want {
+				t.Errorf("multipartETag = %s, want %s", got, want)
+			}
+			whole := md5.Sum(data)
+			if got := hex.EncodeToString(h.whole.Sum(nil)); got != hex.EncodeToString(whole[:]) {
+				t.Errorf("MD5 of the whole = %s, want %x", got, whole)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"s3setup/internal/common"
+)
+
+// presign prints presigned URLs for one object, for handing out temporary
+// download or upload links. The URL goes to stdout, one per line; the method,
+// expiry and any headers the holder must send go to stderr.
+func main() {
+	bucket := flag.String("bucket", common.Env("PRESIGN_BUCKET", ""), "bucket (required)")
+	key := flag.String("key", "", "object key (required)")
+	method := flag.String("method", "GET", "GET, HEAD, PUT, DELETE or UPLOADPART")
+	expires := flag.Duration("expires", common.DefaultPresignExpires, "how long the URLs stay valid (at most 168h)")
+	uploadID := flag.String("upload-id", "", "multipart upload for UPLOADPART (default: start a new one)")
+	parts := flag.Int("parts", 1, "number of UPLOADPART URLs, for parts 1 to N")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: presign -bucket <bucket> -key <key> [flags]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	*method = strings.ToUpper(*method)
+	if *bucket == "" || *key == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	switch {
+	case *method != "GET" && *method != "HEAD" && *method != "PUT" && *method != "DELETE" && *method != "UPLOADPART":
+		fmt.Fprintf(os.Stderr, "invalid -method %q\n", *method)
+		os.Exit(2)
+	case *expires < time.Second || *expires > common.MaxPresignExpires:
+		fmt.Fprintf(os.Stderr, "invalid -expires %s: want 1s to %s\n", *expires, common.MaxPresignExpires)
+		os.Exit(2)
+	case *parts < 1 || *parts > 10000:
+		fmt.Fprintf(os.Stderr, "invalid -parts %d: want 1 to 10000\n", *parts)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init error: %v\n", err)
+		os.Exit(1)
+	}
+	p := common.NewPresigner(client, func(p *common.Presigner) { p.Expires = *expires })
+
+	var reqs []*common.PresignedRequest
+	switch *method {
+	case "GET":
+		reqs, err = one(p.GetObject(ctx, *bucket, *key))
+	case "HEAD":
+		reqs, err = one(p.HeadObject(ctx, *bucket, *key))
+	case "PUT":
+		reqs, err = one(p.PutObject(ctx, *bucket, *key))
+	case "DELETE":
+		reqs, err = one(p.DeleteObject(ctx, *bucket, *key))
+	case "UPLOADPART":
+		if *uploadID == "" {
+			if *uploadID, err = p.CreateMultipartUpload(ctx, *bucket, *key); err != nil {
+				break
+			}
+			fmt.Fprintf(os.Stderr, "Started multipart upload %s; complete or abort it with the part ETags\n", *uploadID)
+		}
+		for n := 1; n <= *parts && err == nil; n++ {
+			var req *common.PresignedRequest
+			if req, err = p.UploadPart(ctx, *bucket, *key, *uploadID, int32(n)); err == nil {
+				reqs = append(reqs, req)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s s3://%s/%s on %s, valid until %s\n", reqs[0].Method, *bucket, *key, cfg.Endpoint, reqs[0].Expires.Format(time.RFC3339))
+	printHeaders(reqs[0])
+	for _, req := range reqs {
+		fmt.Println(req.URL)
+	}
+}
+
+func one(req *common.PresignedRequest, err error) ([]*common.PresignedRequest, error) {
+	if err != nil {
+		return nil, err
+	}
+	return []*common.PresignedRequest{req}, nil
+}
+
+// printHeaders lists the signed headers the holder must send, as curl flags.
+func printHeaders(req *common.PresignedRequest) {
+	var names []string
+	for name := range req.SignedHeader {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "Send header: -H '%s: %s'\n", name, strings.Join(req.SignedHeader[name], ","))
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"s3setup/internal/scenario"
+)
+
+func main() {
+	os.Exit(scenario.Main(scenario.Checksums))
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// mtimeKey is the user metadata entry holding a file's modification time,
+// as "<unix seconds>.<nanoseconds>". sync sets it on upload and restores it on
+// download.
+const mtimeKey = "mtime"
+
+// tmpSuffix marks the partial files of running downloads; walks skip them.
+const tmpSuffix = ".acssync"
+
+type localFile struct {
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+type remoteObject struct {
+	key          string
+	size         int64
+	etag         string
+	lastModified time.Time
+}
+
+// entry pairs the local and remote side of one relative path; either may be nil.
+type entry struct {
+	rel    string
+	local  *localFile
+	remote *remoteObject
+}
+
+// listLocal walks root and returns its regular files by slash-separated path
+// relative to root. A missing root is empty.
+func (s *syncer) listLocal() (map[string]*localFile, error) {
+	files := map[string]*localFile{}
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == s.root && os.IsNotExist(err) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(path, tmpSuffix) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !s.filters.included(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[rel] = &localFile{path: path, size: info.Size(), mtime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// listRemote lists the objects under the prefix by key relative to it. Folder
+// markers and keys that cannot be a local path (e.g. containing "..") are
+// skipped.
+func (s *syncer) listRemote(ctx context.Context) (map[string]*remoteObject, error) {
+	objects := map[string]*remoteObject{}
+	pager := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(s.prefix)})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list objects error: %w", err)
+		}
+		for _, o := range page.Contents {
+			key := aws.ToString(o.Key)
+			rel := strings.TrimPrefix(key, s.prefix)
+			if rel == "" || strings.HasSuffix(rel, "/") {
+				continue
+			}
+			if !filepath.IsLocal(filepath.FromSlash(rel)) {
+				fmt.Fprintf(os.Stderr, "warning: skipping %s: not a valid local path\n", key)
+				continue
+			}
+			if !s.filters.included(rel) {
+				continue
+			}
+			objects[rel] = &remoteObject{
+				key:          key,
+				size:         aws.ToInt64(o.Size),
+				etag:         strings.Trim(aws.ToString(o.ETag), `"`),
+				lastModified: aws.ToTime(o.LastModified),
+			}
+		}
+	}
+	return objects, nil
+}
+
+// same reports whether both sides of e hold the same content. Sizes must match.
+// An ETag that is a plain MD5 is compared with the local file's MD5. Otherwise
+// (multipart or encrypted objects) the mtime metadata is compared with the
+// local modification time to the second; without it, the destination is
+// current when it is not older than the source.
+func (s *syncer) same(ctx context.Context, e entry) (bool, error) {
+	if e.local.size != e.remote.size {
+		return false, nil
+	}
+	if common.IsMD5(e.remote.etag) {
+		sum, err := fileMD5(e.local.path)
+		return sum == e.remote.etag, err
+	}
+	mtime, ok, err := s.remoteMtime(ctx, e.remote)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		return mtime.Unix() == e.local.mtime.Unix(), nil
+	}
+	if s.upload {
+		return !e.local.mtime.After(e.remote.lastModified), nil
+	}
+	return !e.remote.lastModified.After(e.local.mtime), nil
+}
+
+// remoteMtime reads the mtime metadata of o, reporting false when it has none.
+func (s *syncer) remoteMtime(ctx context.Context, o *remoteObject) (time.Time, bool, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(o.key)})
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("head object error: %w", err)
+	}
+	t, ok := parseMtime(out.Metadata[mtimeKey])
+	return t, ok, nil
+}
+
+func formatMtime(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+func parseMtime(v string) (time.Time, bool) {
+	sec, nsec, _ := strings.Cut(v, ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var ns int64
+	if nsec != "" {
+		if ns, err = strconv.ParseInt((nsec + "000000000")[:9], 10, 64); err != nil {
+			return time.Time{}, false
+		}
+	}
+	return time.Unix(s, ns), true
+}
+
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("read error: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// filters are the -include and -exclude globs in command-line order. A path is
+// included unless the last filter matching it is an -exclude, as in aws s3 sync.
+type filters []filter
+
+type filter struct {
+	include bool
+	re      *regexp.Regexp
+}
+
+func (f *filters) add(include bool) func(string) error {
+	return func(glob string) error {
+		re, err := globRegexp(glob)
+		if err != nil {
+			return err
+		}
+		*f = append(*f, filter{include: include, re: re})
+		return nil
+	}
+}
+
+func (f filters) included(rel string) bool {
+	ok := true
+	for _, x := range f {
+		if x.re.MatchString(rel) {
+			ok = x.include
+		}
+	}
+	return ok
+}
+
+// globRegexp compiles a glob over slash-separated relative paths. "*" matches
+// any run of characters, including "/", "?" one character, and "[...]" a
+// character class ("[!...]" negated).
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := slices.Index(runes[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("glob %q: unterminated [", glob)
+			}
+			class := string(runes[i+1 : i+1+end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("glob %q: %w", glob, err)
+	}
+	return re, nil
+}
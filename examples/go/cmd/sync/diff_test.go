@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGlobRegexp(t *testing.T) {
+	for _, tc := range []struct {
+		glob  string
+		match []string
+		miss  []string
+	}{
+		{"*.log", []string{"a.log", "dir/a.log", ".log"}, []string{"a.log.gz", "a.txt"}},
+		{"logs/*", []string{"logs/a", "logs/a/b"}, []string{"logs", "x/logs/a"}},
+		{"?.txt", []string{"a.txt", "/.txt"}, []string{"ab.txt", ".txt"}},
+		{"[abc].txt", []string{"a.txt", "c.txt"}, []string{"d.txt", "ab.txt"}},
+		{"[a-c]?", []string{"a1", "cz"}, []string{"d1", "a"}},
+		{"[!abc].txt", []string{"d.txt", "1.txt"}, []string{"a.txt", "b.txt"}},
+		{"[!.]*", []string{"a", "a.b"}, []string{".hidden", ""}},
+		{`[\]x`, []string{`\x`}, []string{"x", `\\x`}},
+		{"a+b(c).txt", []string{"a+b(c).txt"}, []string{"aab(c).txt", "a+bc.txt"}},
+	} {
+		re, err := globRegexp(tc.glob)
+		if err != nil {
+			t.Errorf("globRegexp(%q): %v", tc.glob, err)
+			continue
+		}
+		for _, s := range tc.match {
+			if !re.MatchString(s) {
+				t.Errorf("%q does not match %q", tc.glob, s)
+			}
+		}
+		for _, s := range tc.miss {
+			if re.MatchString(s) {
+				t.Errorf("%q matches %q", tc.glob, s)
+			}
+		}
+	}
+	for _, glob := range []string{"[abc", "a[", "[]"} {
+		if _, err := globRegexp(glob); err == nil {
+			t.Errorf("globRegexp(%q) accepted an invalid class", glob)
+		}
+	}
+}
+
+func TestFiltersIncluded(t *testing.T) {
+	type flag struct {
+		include bool
+		glob    string
+	}
+	for _, tc := range []struct {
+		name    string
+		flags   []flag
+		path    string
+		include bool
+	}{
+		{"no filters", nil, "a.log", true},
+		{"excluded", []flag{{false, "*.log"}}, "a.log", false},
+		{"not matched", []flag{{false, "*.log"}}, "a.txt", true},
+		{"include after exclude", []flag{{false, "*"}, {true, "*.txt"}}, "a.txt", true},
+		{"include after exclude, not matched", []flag{{false, "*"}, {true, "*.txt"}}, "a.log", false},
+		{"exclude after include", []flag{{true, "*.txt"}, {false, "*"}}, "a.txt", false},
+		{"include only", []flag{{true, "*.txt"}}, "a.log", true},
+		{"last of several", []flag{{false, "*"}, {true, "keep/*"}, {false, "keep/tmp/*"}}, "keep/tmp/a", false},
+		{"last of several, included", []flag{{false, "*"}, {true, "keep/*"}, {false, "keep/tmp/*"}}, "keep/a", true},
+	} {
+		var fs filters
+		for _, f := range tc.flags {
+			if err := fs.add(f.include)(f.glob); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := fs.included(tc.path); got != tc.include {
+			t.Errorf("%s: included(%q) = %v, want %v", tc.name, tc.path, got, tc.include)
+		}
+	}
+}
+
+func TestParseMtime(t *testing.T) {
+	for _, tc := range []struct {
+		v    string
+		want time.Time
+		ok   bool
+	}{
+		{"1700000000", time.Unix(1700000000, 0), true},
+		{"1700000000.000000000", time.Unix(1700000000, 0), true},
+		{"1700000000.5", time.Unix(1700000000, 500000000), true},
+		{"1700000000.000001", time.Unix(1700000000, 1000), true},
+		{"1700000000.123456789", time.Unix(1700000000, 123456789), true},
+		// Digits past nanoseconds are dropped, not rounded.
+		{"1700000000.1234567899", time.Unix(1700000000, 123456789), true},
+		{"1700000000.", time.Unix(1700000000, 0), true},
+		{"", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+		{".5", time.Time{}, false},
+		{"1700000000.5s", time.Time{}, false},
+	} {
+		got, ok := parseMtime(tc.v)
+		if ok != tc.ok || !got.Equal(tc.want) {
+			t.Errorf("parseMtime(%q) = %v, %v, want %v, %v", tc.v, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	mtime := time.Unix(1700000000, 42)
+	if got, ok := parseMtime(formatMtime(mtime)); !ok || !got.Equal(mtime) {
+		t.Errorf("parseMtime(formatMtime(%v)) = %v, %v", mtime, got, ok)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"s3setup/internal/common"
+)
+
+// sync makes a bucket prefix match a local directory, or a local directory match
+// a bucket prefix, transferring only the files that differ.
+func main() {
+	envConcurrency, err := common.EnvInt("SYNC_CONCURRENCY", common.DefaultConcurrency)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var fs filters
+	concurrency := flag.Int("concurrency", int(envConcurrency), "files transferred at once")
+	del := flag.Bool("delete", false, "delete destination files that are not in the source")
+	dryRun := flag.Bool("dryrun", false, "print what would be transferred or deleted without doing it")
+	flag.Func("exclude", "skip paths matching this glob (repeatable)", fs.add(false))
+	flag.Func("include", "do not skip paths matching this glob, overriding earlier -exclude (repeatable)", fs.add(true))
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: sync [flags] <dir> s3://<bucket>[/<prefix>]\n       sync [flags] s3://<bucket>[/<prefix>] <dir>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 || *concurrency < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s := &syncer{filters: fs, delete: *del, dryRun: *dryRun}
+	src, dst := flag.Arg(0), flag.Arg(1)
+	switch {
+	case isS3URL(src) && isS3URL(dst):
+		fmt.Fprintln(os.Stderr, "sync between two buckets is not supported; one side must be a local directory")
+		os.Exit(2)
+	case isS3URL(dst):
+		s.upload, s.root = true, src
+		s.bucket, s.prefix = parseS3URL(dst)
+	case isS3URL(src):
+		s.root = dst
+		s.bucket, s.prefix = parseS3URL(src)
+	default:
+		fmt.Fprintln(os.Stderr, "one side must be an s3://<bucket>[/<prefix>] URL")
+		os.Exit(2)
+	}
+	if s.bucket == "" {
+		fmt.Fprintln(os.Stderr, "the s3:// URL has no bucket")
+		os.Exit(2)
+	}
+
+	// Ctrl-C stops new transfers and cancels the running ones.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init error: %v\n", err)
+		os.Exit(1)
+	}
+	s.client = client
+	s.uploader = common.NewUploader(client)
+	s.downloader = common.NewDownloader(client)
+
+	fmt.Printf("Using endpoint: %s\n", cfg.Endpoint)
+	if s.dryRun {
+		fmt.Println("Dry run: nothing will be transferred or deleted")
+	}
+	start := time.Now()
+	if err := s.run(ctx, *concurrency); err != nil {
+		fmt.Fprintf(os.Stderr, "sync error: %v\n", err)
+		os.Exit(1)
+	}
+	s.printSummary(time.Since(start))
+	if s.stats.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func isS3URL(s string) bool { return strings.HasPrefix(s, "s3://") }
+
+// parseS3URL splits s3://bucket/prefix. A non-empty prefix always ends in "/",
+// so s3://bucket/dir and s3://bucket/dir/ name the same files.
+func parseS3URL(s string) (bucket, prefix string) {
+	bucket, prefix, _ = strings.Cut(strings.TrimPrefix(s, "s3://"), "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return bucket, prefix
+}
+
+func (s *syncer) printSummary(elapsed time.Duration) {
+	st := s.stats
+	format := "Uploaded %d files (%s), downloaded %d files (%s), deleted %d files; %d unchanged\n"
+	if s.dryRun {
+		format = "Would upload %d files (%s), download %d files (%s), delete %d files; %d unchanged\n"
+	}
+	fmt.Printf(format, st.uploaded, common.FormatBytes(st.uploadedBytes), st.downloaded, common.FormatBytes(st.downloadedBytes), st.deleted, st.unchanged)
+	if !s.dryRun {
+		total := st.uploadedBytes + st.downloadedBytes
+		fmt.Printf("Transferred %s in %s (%s/s)\n", common.FormatBytes(total), elapsed.Round(time.Millisecond), common.FormatBytes(common.Rate(total, elapsed)))
+	}
+	if st.failed > 0 {
+		fmt.Printf("❌ %d failures\n", st.failed)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// syncer holds the sync settings and tallies.
+type syncer struct {
+	client     *s3.Client
+	uploader   *common.Uploader
+	downloader *common.Downloader
+
+	bucket string
+	prefix string
+	root   string
+	// upload is the direction: local directory to bucket when set, else bucket
+	// to local directory.
+	upload  bool
+	delete  bool
+	dryRun  bool
+	filters filters
+
+	mu    sync.Mutex
+	stats stats
+}
+
+type stats struct {
+	uploaded, downloaded, deleted, unchanged, failed int
+	uploadedBytes, downloadedBytes                   int64
+}
+
+// run lists both sides and handles every path with up to concurrency workers.
+func (s *syncer) run(ctx context.Context, concurrency int) error {
+	if info, err := os.Stat(s.root); err == nil && !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.root)
+	} else if err != nil && (s.upload || !os.IsNotExist(err)) {
+		return err
+	}
+	local, err := s.listLocal()
+	if err != nil {
+		return fmt.Errorf("walk %s error: %w", s.root, err)
+	}
+	remote, err := s.listRemote(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]entry, 0, len(local)+len(remote))
+	for rel, l := range local {
+		entries = append(entries, entry{rel: rel, local: l, remote: remote[rel]})
+	}
+	for rel, r := range remote {
+		if local[rel] == nil {
+			entries = append(entries, entry{rel: rel, remote: r})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].rel < entries[j].rel })
+
+	queue := make(chan entry)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range queue {
+				s.handle(ctx, e)
+			}
+		}()
+	}
+feed:
+	for _, e := range entries {
+		select {
+		case queue <- e:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// handle brings the destination side of e in line with the source side.
+func (s *syncer) handle(ctx context.Context, e entry) {
+	src, dst := e.local != nil, e.remote != nil
+	if !s.upload {
+		src, dst = dst, src
+	}
+	switch {
+	case src && dst:
+		same, err := s.same(ctx, e)
+		if err != nil {
+			s.fail("compare", e, err)
+			return
+		}
+		if same {
+			s.mu.Lock()
+			s.stats.unchanged++
+			s.mu.Unlock()
+			return
+		}
+		s.transfer(ctx, e)
+	case src:
+		s.transfer(ctx, e)
+	case s.delete:
+		s.remove(ctx, e)
+	}
+}
+
+func (s *syncer) transfer(ctx context.Context, e entry) {
+	if s.upload {
+		s.put(ctx, e)
+	} else {
+		s.get(ctx, e)
+	}
+}
+
+func (s *syncer) put(ctx context.Context, e entry) {
+	key := s.prefix + e.rel
+	if !s.dryRun {
+		_, err := s.uploader.UploadFile(ctx, e.local.path, common.UploadInput{
+			Bucket:      s.bucket,
+			Key:         key,
+			ContentType: mime.TypeByExtension(filepath.Ext(e.rel)),
+			Metadata:    map[string]string{mtimeKey: formatMtime(e.local.mtime)},
+		})
+		if err != nil {
+			s.fail("upload", e, err)
+			return
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.uploaded++
+	s.stats.uploadedBytes += e.local.size
+	s.report("upload: %s to s3://%s/%s", e.local.path, s.bucket, key)
+}
+
+// get downloads to a temporary file next to the target and renames it into
+// place once it is verified, so an interrupted sync never leaves a partial file
+// under the real name. The file gets the object's mtime metadata, or else its
+// LastModified, as modification time.
+func (s *syncer) get(ctx context.Context, e entry) {
+	path := filepath.Join(s.root, filepath.FromSlash(e.rel))
+	if !s.dryRun {
+		if err := s.download(ctx, e, path); err != nil {
+			s.fail("download", e, err)
+			return
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.downloaded++
+	s.stats.downloadedBytes += e.remote.size
+	s.report("download: s3://%s/%s to %s", s.bucket, e.remote.key, path)
+}
+
+func (s *syncer) download(ctx context.Context, e entry, path string) error {
+	mtime, ok, err := s.remoteMtime(ctx, e.remote)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		mtime = e.remote.lastModified
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+tmpSuffix)
+	if _, _, err := s.downloader.DownloadFile(ctx, s.bucket, e.remote.key, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Chtimes(tmp, time.Time{}, mtime); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// remove deletes the destination side of e, which has no source.
+func (s *syncer) remove(ctx context.Context, e entry) {
+	target := ""
+	if s.upload {
+		target = fmt.Sprintf("s3://%s/%s", s.bucket, e.remote.key)
+		if !s.dryRun {
+			if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(e.remote.key)}); err != nil {
+				s.fail("delete", e, fmt.Errorf("delete object error: %w", err))
+				return
+			}
+		}
+	} else {
+		target = e.local.path
+		if !s.dryRun {
+			if err := os.Remove(e.local.path); err != nil {
+				s.fail("delete", e, err)
+				return
+			}
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.deleted++
+	s.report("delete: %s", target)
+}
+
+// report prints one action; callers hold s.mu.
+func (s *syncer) report(format string, args ...any) {
+	if s.dryRun {
+		format = "(dryrun) " + format
+	}
+	fmt.Printf(format+"\n", args...)
+}
+
+func (s *syncer) fail(action string, e entry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.failed++
+	fmt.Printf("  FAILED: %s %s: %v\n", action, e.rel, err)
+}
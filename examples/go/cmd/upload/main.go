@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"s3setup/acs"
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// upload sends a local file to ACS as a parallel multipart upload, printing
+// progress as parts complete.
+func main() {
+	envPartMiB, err1 := common.EnvInt("UPLOAD_PART_SIZE_MIB", common.DefaultPartSize>>20)
+	envConcurrency, err2 := common.EnvInt("UPLOAD_CONCURRENCY", common.DefaultConcurrency)
+	if err := errors.Join(err1, err2); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	bucket := flag.String("bucket", common.Env("UPLOAD_BUCKET", ""), "destination bucket (required)")
+	key := flag.String("key", "", "destination key (default: the file's base name)")
+	partMiB := flag.Int64("part-size", envPartMiB, "part size in MiB (at least 5)")
+	concurrency := flag.Int("concurrency", int(envConcurrency), "parts uploaded at once")
+	retries := flag.Int("retries", common.DefaultPartRetries, "retries per failed part")
+	ctype := flag.String("content-type", "", "Content-Type (default: guessed from the file extension)")
+	resume := flag.Bool("resume", false, "record progress in a journal and continue an interrupted upload instead of aborting it")
+	journal := flag.String("journal", "", "journal file for -resume (default: <file>.acsupload)")
+	checksum := flag.String("checksum", "", "flexible checksum to send and store: CRC32, CRC32C, SHA1 or SHA256 (default $S3_CHECKSUM_ALGORITHM)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: upload -bucket <bucket> [flags] <file>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *bucket == "" || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+	if *key == "" {
+		*key = filepath.Base(path)
+	}
+	if *ctype == "" {
+		*ctype = mime.TypeByExtension(filepath.Ext(path))
+	}
+	if *journal == "" {
+		*journal = path + ".acsupload"
+	}
+	var alg types.ChecksumAlgorithm
+	if *checksum != "" {
+		var err error
+		if alg, err = acs.ParseChecksumAlgorithm(*checksum); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -checksum: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	// Ctrl-C cancels the upload, which aborts it unless -resume is set.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init error: %v\n", err)
+		os.Exit(1)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stat error: %v\n", err)
+		os.Exit(1)
+	}
+
+	start := time.Now()
+	uploader := common.NewUploader(client, func(u *common.Uploader) {
+		u.PartSize = *partMiB << 20
+		u.Concurrency = *concurrency
+		u.PartRetries = *retries
+		u.ChecksumAlgorithm = alg
+		u.Progress = func(done, total int64) { printProgress(done, total, start) }
+	})
+
+	fmt.Printf("Uploading %s (%s) to %s/%s/%s\n", path, common.FormatBytes(info.Size()), cfg.Endpoint, *bucket, *key)
+	in := common.UploadInput{Bucket: *bucket, Key: *key, ContentType: *ctype}
+	var res *common.UploadResult
+	if *resume {
+		res, err = uploader.ResumeUploadFile(ctx, path, in, *journal)
+	} else {
+		res, err = uploader.UploadFile(ctx, path, in)
+	}
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "upload error: %v\n", err)
+		if *resume {
+			fmt.Fprintf(os.Stderr, "Run the same command again to resume.\n")
+		}
+		os.Exit(1)
+	}
+	if res.Resumed > 0 {
+		fmt.Printf("Resumed upload %s: %d of %d parts were already uploaded\n", res.UploadID, res.Resumed, res.Parts)
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("Uploaded %s in %d parts in %s (%s/s)\n", common.FormatBytes(res.Size), res.Parts, elapsed.Round(time.Millisecond), common.FormatBytes(common.Rate(res.Size, elapsed)))
+	fmt.Printf("ETag: %s\n", res.ETag)
+	if res.Checksum != "" {
+		fmt.Printf("Checksum: %s %s\n", res.ChecksumAlgorithm, res.Checksum)
+	}
+	if res.VersionID != "" {
+		fmt.Printf("Version ID: %s\n", res.VersionID)
+	}
+}
+
+// printProgress redraws the progress line on stderr.
+func printProgress(done, total int64, start time.Time) {
+	pct := ""
+	if total > 0 {
+		pct = fmt.Sprintf("%5.1f%%  ", float64(done)*100/float64(total))
+	}
+	fmt.Fprintf(os.Stderr, "\r%s%s / %s  %s/s   ", pct, common.FormatBytes(done), common.FormatBytes(total), common.FormatBytes(common.Rate(done, time.Since(start))))
+}
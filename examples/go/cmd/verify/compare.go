@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// How two entries were found to hold the same content.
+const (
+	bySHA256   = "SHA-256"
+	byChecksum = "checksum or ETag"
+	bySize     = "size only"
+)
+
+// verifier compares a source location with a destination location.
+type verifier struct {
+	src, dst *location
+	sha256   bool
+
+	mu                     sync.Mutex
+	stats                  stats
+	srcObjects, dstObjects int
+	srcBytes, dstBytes     int64
+}
+
+type stats struct {
+	missing, extra, mismatched, failed int
+	// matched counts equal entries by how they were compared.
+	matched map[string]int
+}
+
+// pair is one relative path present on both sides.
+type pair struct {
+	rel      string
+	src, dst entry
+}
+
+// run lists both locations at the same time, reports the paths only one side
+// has, and compares the rest with up to concurrency workers.
+func (v *verifier) run(ctx context.Context, concurrency int) error {
+	var src, dst map[string]entry
+	var srcErr, dstErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); src, srcErr = v.src.list(ctx, concurrency) }()
+	go func() { defer wg.Done(); dst, dstErr = v.dst.list(ctx, concurrency) }()
+	wg.Wait()
+	if err := errors.Join(srcErr, dstErr); err != nil {
+		return err
+	}
+	v.srcObjects, v.srcBytes = len(src), totalSize(src)
+	v.dstObjects, v.dstBytes = len(dst), totalSize(dst)
+	v.stats.matched = map[string]int{}
+
+	var pairs []pair
+	for _, rel := range sortedKeys(src) {
+		d, ok := dst[rel]
+		if !ok {
+			v.stats.missing++
+			fmt.Printf("missing: %s\n", rel)
+			continue
+		}
+		pairs = append(pairs, pair{rel: rel, src: src[rel], dst: d})
+	}
+	for _, rel := range sortedKeys(dst) {
+		if _, ok := src[rel]; !ok {
+			v.stats.extra++
+			fmt.Printf("extra: %s\n", rel)
+		}
+	}
+
+	queue := make(chan pair)
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				v.check(ctx, p)
+			}
+		}()
+	}
+feed:
+	for _, p := range pairs {
+		select {
+		case queue <- p:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (v *verifier) check(ctx context.Context, p pair) {
+	method, mismatch, err := v.compare(ctx, p)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch {
+	case err != nil:
+		v.stats.failed++
+		fmt.Printf("  FAILED: %s: %v\n", p.rel, err)
+	case mismatch != "":
+		v.stats.mismatched++
+		fmt.Printf("mismatch: %s: %s\n", p.rel, mismatch)
+	default:
+		v.stats.matched[method]++
+	}
+}
+
+// compare checks one path present on both sides. It returns how the contents
+// were found equal, or else why they differ. Sizes must match. With -sha256
+// both contents are streamed and hashed. Otherwise two objects are compared by
+// ETag, which decides only when the ETags are equal or are both plain MD5s of
+// unencrypted objects, and an object and a file are compared with
+// Downloader.Verify: a stored checksum, or the object's (multipart) ETag
+// computed over the file.
+func (v *verifier) compare(ctx context.Context, p pair) (method, mismatch string, err error) {
+	if p.src.size != p.dst.size {
+		return "", fmt.Sprintf("size %d, destination %d", p.src.size, p.dst.size), nil
+	}
+	if v.sha256 {
+		a, err := v.src.sha256(ctx, p.src)
+		if err != nil {
+			return "", "", err
+		}
+		b, err := v.dst.sha256(ctx, p.dst)
+		if err != nil {
+			return "", "", err
+		}
+		if a != b {
+			return "", fmt.Sprintf("SHA-256 %s, destination %s", a, b), nil
+		}
+		return bySHA256, "", nil
+	}
+
+	switch {
+	case v.src.client != nil && v.dst.client != nil:
+		return v.compareETags(ctx, p)
+	case v.src.client != nil:
+		return v.src.verifyFile(ctx, p.src, p.dst.name)
+	default:
+		return v.dst.verifyFile(ctx, p.dst, p.src.name)
+	}
+}
+
+func (v *verifier) compareETags(ctx context.Context, p pair) (string, string, error) {
+	a, b := strings.Trim(p.src.etag, `"`), strings.Trim(p.dst.etag, `"`)
+	if a == b {
+		return byChecksum, "", nil
+	}
+	if !common.IsMD5(a) || !common.IsMD5(b) {
+		return bySize, "", nil
+	}
+	// The ETag of an SSE-KMS or SSE-C object is not the MD5 of its content.
+	for _, side := range []struct {
+		l *location
+		e entry
+	}{{v.src, p.src}, {v.dst, p.dst}} {
+		info, err := side.l.downloader.Stat(ctx, side.l.bucket, side.e.name)
+		if err != nil {
+			return "", "", err
+		}
+		if info.Encrypted {
+			return bySize, "", nil
+		}
+	}
+	return "", fmt.Sprintf("ETag %s, destination %s", a, b), nil
+}
+
+// verifyFile checks the local file at path against the object e of l.
+func (l *location) verifyFile(ctx context.Context, e entry, path string) (string, string, error) {
+	obj, err := l.downloader.Stat(ctx, l.bucket, e.name)
+	if err != nil {
+		return "", "", err
+	}
+	if obj.Size != e.size {
+		return "", "", fmt.Errorf("object changed while verifying")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	how, err := l.downloader.Verify(ctx, f, obj)
+	if errors.Is(err, common.ErrIntegrity) {
+		return "", err.Error(), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if how == "" {
+		return bySize, "", nil
+	}
+	return byChecksum, "", nil
+}
+
+// sha256 streams e's content through SHA-256. An object is read with If-Match
+// on its listed ETag, so one replaced since the listing is an error rather than
+// a mismatch.
+func (l *location) sha256(ctx context.Context, e entry) (string, error) {
+	var body io.ReadCloser
+	if l.client == nil {
+		f, err := os.Open(e.name)
+		if err != nil {
+			return "", err
+		}
+		body = f
+	} else {
+		out, err := l.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(l.bucket), Key: aws.String(e.name), IfMatch: aws.String(e.etag)})
+		if err != nil {
+			return "", fmt.Errorf("get object error: %w", err)
+		}
+		body = out.Body
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", fmt.Errorf("read error: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func totalSize(entries map[string]entry) int64 {
+	var n int64
+	for _, e := range entries {
+		n += e.size
+	}
+	return n
+}
+
+func sortedKeys(entries map[string]entry) []string {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
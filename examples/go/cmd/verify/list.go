@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// location is one side of a comparison: a bucket prefix, or a local directory
+// when client is nil.
+type location struct {
+	client     *s3.Client
+	downloader *common.Downloader
+	bucket     string
+	prefix     string
+	dir        string
+}
+
+// entry is one listed object or file, by path relative to its location.
+type entry struct {
+	// name is the object key or the file path.
+	name string
+	size int64
+	// etag is empty for local files.
+	etag string
+}
+
+func (l *location) String() string {
+	if l.client == nil {
+		return l.dir
+	}
+	return fmt.Sprintf("s3://%s/%s", l.bucket, l.prefix)
+}
+
+func (l *location) list(ctx context.Context, concurrency int) (map[string]entry, error) {
+	if l.client == nil {
+		return l.listLocal()
+	}
+	return l.listS3(ctx, concurrency)
+}
+
+// listS3 lists the objects under the prefix by key relative to it. The key
+// space is split at "/": every common prefix is listed by its own paginated
+// ListObjectsV2 stream, with up to concurrency of them running at once, so a
+// bucket with many "directories" lists in parallel. Folder markers are skipped.
+func (l *location) listS3(ctx context.Context, concurrency int) (map[string]entry, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		objects  = map[string]entry{}
+		firstErr error
+		wg       sync.WaitGroup
+		slots    = make(chan struct{}, concurrency)
+	)
+	var walk func(prefix string)
+	walk = func(prefix string) {
+		defer wg.Done()
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-slots }()
+
+		pager := s3.NewListObjectsV2Paginator(l.client, &s3.ListObjectsV2Input{
+			Bucket:    aws.String(l.bucket),
+			Prefix:    aws.String(prefix),
+			Delimiter: aws.String("/"),
+		})
+		for pager.HasMorePages() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("list %s error: %w", l, err)
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			for _, p := range page.CommonPrefixes {
+				wg.Add(1)
+				go walk(aws.ToString(p.Prefix))
+			}
+			mu.Lock()
+			for _, o := range page.Contents {
+				key := aws.ToString(o.Key)
+				rel := strings.TrimPrefix(key, l.prefix)
+				if rel == "" || strings.HasSuffix(rel, "/") {
+					continue
+				}
+				objects[rel] = entry{name: key, size: aws.ToInt64(o.Size), etag: aws.ToString(o.ETag)}
+			}
+			mu.Unlock()
+		}
+	}
+	wg.Add(1)
+	go walk(l.prefix)
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return objects, firstErr
+}
+
+// listLocal walks the directory and returns its regular files by
+// slash-separated path relative to it.
+func (l *location) listLocal() (map[string]entry, error) {
+	files := map[string]entry{}
+	err := filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = entry{name: path, size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s error: %w", l.dir, err)
+	}
+	return files, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"s3setup/internal/common"
+)
+
+// verify compares two locations (bucket prefixes on ACS or another S3
+// endpoint, or a local directory) and reports the paths missing from or extra
+// in the destination and the ones whose content differs.
+func main() {
+	envConcurrency, err := common.EnvInt("VERIFY_CONCURRENCY", 2*common.DefaultConcurrency)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	sourceProfile := flag.String("source-profile", "", "ACS profile for a source bucket (default $SOURCE_ACS_PROFILE)")
+	destProfile := flag.String("dest-profile", "", "ACS profile for a destination bucket (default $ACS_PROFILE)")
+	concurrency := flag.Int("concurrency", int(envConcurrency), "listings and comparisons run at once")
+	full := flag.Bool("sha256", false, "compare the full content of every pair by SHA-256 (reads everything)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: verify [flags] <source> <destination>\n  each is s3://<bucket>[/<prefix>] or a local directory\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 || *concurrency < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if !strings.HasPrefix(flag.Arg(0), "s3://") && !strings.HasPrefix(flag.Arg(1), "s3://") {
+		fmt.Fprintln(os.Stderr, "at least one side must be an s3://<bucket>[/<prefix>] URL")
+		os.Exit(2)
+	}
+
+	// Ctrl-C cancels the listings and comparisons.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// The source bucket has its own settings when any are given, as for
+	// migrate; otherwise both sides use the destination's.
+	srcPrefix := ""
+	if *sourceProfile != "" || os.Getenv("SOURCE_S3_ENDPOINT") != "" || os.Getenv("SOURCE_ACS_PROFILE") != "" {
+		srcPrefix = "SOURCE_"
+	} else {
+		*sourceProfile = *destProfile
+	}
+	src, err := newLocation(ctx, flag.Arg(0), "Source", srcPrefix, *sourceProfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "source init error: %v\n", err)
+		os.Exit(1)
+	}
+	dst, err := newLocation(ctx, flag.Arg(1), "Destination", "", *destProfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "destination init error: %v\n", err)
+		os.Exit(1)
+	}
+	v := &verifier{src: src, dst: dst, sha256: *full}
+	start := time.Now()
+	if err := v.run(ctx, *concurrency); err != nil {
+		fmt.Fprintf(os.Stderr, "verify error: %v\n", err)
+		os.Exit(1)
+	}
+	v.printSummary(time.Since(start))
+	st := v.stats
+	if st.missing+st.extra+st.mismatched+st.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// newLocation parses arg as s3://bucket/prefix, building a client from the
+// settings with envPrefix, or else as a local directory.
+func newLocation(ctx context.Context, arg, label, envPrefix, profile string) (*location, error) {
+	if !strings.HasPrefix(arg, "s3://") {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", arg)
+		}
+		fmt.Printf("%-12s %s\n", label+":", arg)
+		return &location{dir: arg}, nil
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(arg, "s3://"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("%s has no bucket", arg)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	client, cfg, err := common.NewS3ClientFor(ctx, envPrefix, profile)
+	if err != nil {
+		return nil, err
+	}
+	l := &location{client: client, downloader: common.NewDownloader(client), bucket: bucket, prefix: prefix}
+	fmt.Printf("%-12s %s on %s\n", label+":", l, cfg.Endpoint)
+	return l, nil
+}
+
+func (v *verifier) printSummary(elapsed time.Duration) {
+	st := v.stats
+	fmt.Printf("Compared %d objects (%s) in the source with %d (%s) in the destination in %s\n",
+		v.srcObjects, common.FormatBytes(v.srcBytes), v.dstObjects, common.FormatBytes(v.dstBytes), elapsed.Round(time.Millisecond))
+	fmt.Printf("Matched: %d by %s, %d by %s, %d by %s\n",
+		st.matched[bySHA256], bySHA256, st.matched[byChecksum], byChecksum, st.matched[bySize], bySize)
+	fmt.Printf("Missing from the destination: %d; extra in the destination: %d; mismatched: %d\n", st.missing, st.extra, st.mismatched)
+	if st.matched[bySize] > 0 {
+		fmt.Println("Objects matched by size only have ETags that cannot be compared (multipart or encrypted); run with -sha256 to compare their content.")
+	}
+	if st.failed > 0 {
+		fmt.Printf("❌ %d failures\n", st.failed)
+	}
+	switch {
+	case st.missing+st.extra+st.mismatched > 0:
+		fmt.Println("❌ Locations differ")
+	case st.failed == 0:
+		fmt.Println("✅ Locations match")
+	}
+}
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	minBucketName = 3
+	maxBucketName = 63
+	// bucketTimestamp is the layout of the UTC creation time embedded in test
+	// bucket names, which the janitor reads back.
+	bucketTimestamp = "20060102150405"
+	// createAttempts bounds how many fresh names CreateTestBucket tries.
+	createAttempts = 3
+)
+
+// NewTestBucketName returns a bucket name of the form
+// <prefix>-<UTC timestamp>[-<run ID>]-<random hex>, for example
+// smoketest-20250101120000-ci1234-1a2b3c4d. The run ID comes from TEST_RUN_ID
+// and is lowercased with anything outside [a-z0-9-] replaced by "-"; it is
+// shortened or dropped to keep the name within 63 characters. The result is
+// checked with ValidateBucketName, so an invalid prefix is an error.
+func NewTestBucketName(prefix string) (string, error) {
+	head := fmt.Sprintf("%s-%s-", prefix, time.Now().UTC().Format(bucketTimestamp))
+	suffix := RandomSuffix(4)
+	runID := sanitizeRunID(Env("TEST_RUN_ID", ""))
+	if room := maxBucketName - len(head) - len(suffix) - 1; len(runID) > room {
+		runID = strings.Trim(runID[:max(room, 0)], "-")
+	}
+	name := head + suffix
+	if runID != "" {
+		name = head + runID + "-" + suffix
+	}
+	if err := ValidateBucketName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// ValidateBucketName checks name against the S3 bucket naming rules the test
+// buckets must follow: 3 to 63 characters of lowercase letters, digits, dots
+// and hyphens, starting and ending with a letter or digit, no adjacent dots,
+// and not formatted as an IP address.
+func ValidateBucketName(name string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("invalid bucket name %q: %s", name, reason)
+	}
+	if len(name) < minBucketName || len(name) > maxBucketName {
+		return invalid(fmt.Sprintf("must be %d to %d characters long, is %d", minBucketName, maxBucketName, len(name)))
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '.':
+		case c >= 'A' && c <= 'Z':
+			return invalid("uppercase letters are not allowed")
+		case c == '_':
+			return invalid("underscores are not allowed")
+		default:
+			return invalid(fmt.Sprintf("character %q is not allowed", c))
+		}
+	}
+	if !isAlnum(name[0]) || !isAlnum(name[len(name)-1]) {
+		return invalid("must start and end with a letter or digit")
+	}
+	if strings.Contains(name, "..") {
+		return invalid("adjacent dots are not allowed")
+	}
+	if ip := net.ParseIP(name); ip != nil && ip.To4() != nil {
+		return invalid("must not be formatted as an IP address")
+	}
+	return nil
+}
+
+// CreateTestBucket creates a bucket named by NewTestBucketName(prefix). When
+// the name is already taken (BucketAlreadyExists or BucketAlreadyOwnedByYou,
+// e.g. a concurrent run got there first) it retries with a fresh name. It
+// returns the name of the bucket it created.
+func CreateTestBucket(ctx context.Context, client *s3.Client, prefix string, optFns ...func(*s3.CreateBucketInput)) (string, error) {
+	var taken []string
+	for range createAttempts {
+		name, err := NewTestBucketName(prefix)
+		if err != nil {
+			return "", err
+		}
+		in := &s3.CreateBucketInput{Bucket: aws.String(name)}
+		for _, fn := range optFns {
+			fn(in)
+		}
+		_, err = client.CreateBucket(ctx, in)
+		if err == nil {
+			return name, nil
+		}
+		if !hasErrorCode(err, "BucketAlreadyExists") && !hasErrorCode(err, "BucketAlreadyOwnedByYou") {
+			return "", fmt.Errorf("create bucket %s error: %w", name, err)
+		}
+		taken = append(taken, name)
+	}
+	return "", errors.New("create bucket error: names already taken: " + strings.Join(taken, ", "))
+}
+
+// sanitizeRunID lowercases id and replaces each run of characters that are not
+// allowed in a bucket name with a single "-".
+func sanitizeRunID(id string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(id) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+			dash = false
+			continue
+		}
+		if !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+func isAlnum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
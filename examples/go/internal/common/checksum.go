@@ -0,0 +1,93 @@
+package common
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"s3setup/acs"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Checksum returns data's checksum under alg, base64-encoded as S3 reports it.
+func Checksum(alg types.ChecksumAlgorithm, data []byte) (string, error) {
+	h, err := acs.NewChecksum(alg)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// CompositeChecksum returns the checksum S3 reports for a multipart object
+// whose parts have the given base64 checksums: the checksum of the
+// concatenated raw part checksums, then "-" and the part count.
+func CompositeChecksum(alg types.ChecksumAlgorithm, parts []string) (string, error) {
+	h, err := acs.NewChecksum(alg)
+	if err != nil {
+		return "", err
+	}
+	for i, p := range parts {
+		sum, err := base64.StdEncoding.DecodeString(p)
+		if err != nil {
+			return "", fmt.Errorf("part %d checksum %q: %w", i+1, p, err)
+		}
+		h.Write(sum)
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(h.Sum(nil)), len(parts)), nil
+}
+
+// CompletedPart returns the entry CompleteMultipartUpload needs for an
+// UploadPart response: the part number, ETag and checksum. An upload created
+// with a ChecksumAlgorithm cannot be completed without every part's checksum.
+func CompletedPart(number int32, out *s3.UploadPartOutput) types.CompletedPart {
+	alg, sum := checksumOf(out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumSHA1, out.ChecksumSHA256)
+	return withChecksum(types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(number)}, alg, sum)
+}
+
+// CopiedPart is CompletedPart for an UploadPartCopy result.
+func CopiedPart(number int32, r *types.CopyPartResult) types.CompletedPart {
+	alg, sum := checksumOf(r.ChecksumCRC32, r.ChecksumCRC32C, r.ChecksumSHA1, r.ChecksumSHA256)
+	return withChecksum(types.CompletedPart{ETag: r.ETag, PartNumber: aws.Int32(number)}, alg, sum)
+}
+
+// partChecksum returns the algorithm and value of a completed part's checksum.
+func partChecksum(p types.CompletedPart) (types.ChecksumAlgorithm, string) {
+	return checksumOf(p.ChecksumCRC32, p.ChecksumCRC32C, p.ChecksumSHA1, p.ChecksumSHA256)
+}
+
+// withChecksum sets p's checksum field for alg to sum. An empty alg leaves p as is.
+func withChecksum(p types.CompletedPart, alg types.ChecksumAlgorithm, sum string) types.CompletedPart {
+	switch alg {
+	case types.ChecksumAlgorithmCrc32:
+		p.ChecksumCRC32 = aws.String(sum)
+	case types.ChecksumAlgorithmCrc32c:
+		p.ChecksumCRC32C = aws.String(sum)
+	case types.ChecksumAlgorithmSha1:
+		p.ChecksumSHA1 = aws.String(sum)
+	case types.ChecksumAlgorithmSha256:
+		p.ChecksumSHA256 = aws.String(sum)
+	}
+	return p
+}
+
+// checksumOf picks the one checksum set among a response's Checksum<ALG>
+// fields, returning "" when there is none.
+func checksumOf(crc32, crc32c, sha1, sha256 *string) (types.ChecksumAlgorithm, string) {
+	for _, c := range []struct {
+		alg types.ChecksumAlgorithm
+		v   *string
+	}{
+		{types.ChecksumAlgorithmCrc32, crc32},
+		{types.ChecksumAlgorithmCrc32c, crc32c},
+		{types.ChecksumAlgorithmSha1, sha1},
+		{types.ChecksumAlgorithmSha256, sha256},
+	} {
+		if aws.ToString(c.v) != "" {
+			return c.alg, *c.v
+		}
+	}
+	return "", ""
+}
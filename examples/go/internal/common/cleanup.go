@@ -0,0 +1,212 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// maxDeleteBatch is the most keys one DeleteObjects request may name.
+const maxDeleteBatch = 1000
+
+// TeardownReport records what EmptyAndDeleteBucket removed and what it could not.
+type TeardownReport struct {
+	Bucket string
+	// Missing is set when the bucket did not exist, so there was nothing to do.
+	Missing bool
+	// Objects counts deleted objects and object versions.
+	Objects       int
+	DeleteMarkers int
+	// Uploads counts aborted in-progress multipart uploads.
+	Uploads       int
+	BucketDeleted bool
+	// Failures lists every operation that failed.
+	Failures []error
+}
+
+func (r TeardownReport) String() string {
+	if r.Missing {
+		return fmt.Sprintf("bucket %s: did not exist", r.Bucket)
+	}
+	outcome := "bucket deleted"
+	if !r.BucketDeleted {
+		outcome = fmt.Sprintf("bucket kept, %d failures", len(r.Failures))
+	}
+	return fmt.Sprintf("bucket %s: deleted %d objects and %d delete markers, aborted %d uploads; %s",
+		r.Bucket, r.Objects, r.DeleteMarkers, r.Uploads, outcome)
+}
+
+// EmptyAndDeleteBucket aborts every in-progress multipart upload in bucket,
+// deletes every object version and delete marker (or every object, where
+// ListObjectVersions is not implemented) in DeleteObjects batches, then deletes
+// the bucket. It keeps going past failures and returns them joined, alongside a
+// report of what was removed. A bucket that does not exist is not an error.
+func EmptyAndDeleteBucket(ctx context.Context, client *s3.Client, bucket string) (TeardownReport, error) {
+	r := TeardownReport{Bucket: bucket}
+	fail := func(err error) { r.Failures = append(r.Failures, err) }
+
+	uploads, err := listUploads(ctx, client, bucket)
+	switch {
+	case hasErrorCode(err, "NoSuchBucket"):
+		r.Missing = true
+		return r, nil
+	case err != nil && !isNotImplemented(err):
+		fail(err)
+	}
+	for _, u := range uploads {
+		if _, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: aws.String(bucket), Key: u.Key, UploadId: u.UploadId}); err != nil {
+			fail(fmt.Errorf("abort upload %s of %s error: %w", aws.ToString(u.UploadId), aws.ToString(u.Key), err))
+			continue
+		}
+		r.Uploads++
+	}
+
+	versions, markers, err := listVersions(ctx, client, bucket)
+	if err != nil {
+		if !isNotImplemented(err) {
+			fail(err)
+		}
+		versions, err = listObjects(ctx, client, bucket)
+		if err != nil {
+			fail(err)
+		}
+	}
+	n, errs := deleteObjects(ctx, client, bucket, versions)
+	r.Objects += n
+	r.Failures = append(r.Failures, errs...)
+	n, errs = deleteObjects(ctx, client, bucket, markers)
+	r.DeleteMarkers += n
+	r.Failures = append(r.Failures, errs...)
+
+	if _, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		fail(fmt.Errorf("delete bucket error: %w", err))
+	} else {
+		r.BucketDeleted = true
+	}
+	return r, errors.Join(r.Failures...)
+}
+
+// DeleteAllVersions permanently deletes every object version and delete marker in
+// bucket and returns how many it removed. In a versioned bucket a plain
+// DeleteObject only adds a delete marker, so DeleteBucket fails until this has run.
+func DeleteAllVersions(ctx context.Context, client *s3.Client, bucket string) (int, error) {
+	versions, markers, err := listVersions(ctx, client, bucket)
+	if err != nil {
+		return 0, err
+	}
+	n, errs := deleteObjects(ctx, client, bucket, append(versions, markers...))
+	return n, errors.Join(errs...)
+}
+
+// listUploads returns every in-progress multipart upload in bucket.
+func listUploads(ctx context.Context, client *s3.Client, bucket string) ([]types.MultipartUpload, error) {
+	var uploads []types.MultipartUpload
+	pager := s3.NewListMultipartUploadsPaginator(client, &s3.ListMultipartUploadsInput{Bucket: aws.String(bucket)})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return uploads, fmt.Errorf("list multipart uploads error: %w", err)
+		}
+		uploads = append(uploads, page.Uploads...)
+	}
+	return uploads, nil
+}
+
+// listVersions returns every object version and delete marker in bucket. Listing
+// completes before anything is deleted, so pagination markers stay valid.
+func listVersions(ctx context.Context, client *s3.Client, bucket string) (versions, markers []types.ObjectIdentifier, err error) {
+	pager := s3.NewListObjectVersionsPaginator(client, &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("list object versions error: %w", err)
+		}
+		for _, v := range page.Versions {
+			versions = append(versions, types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+		}
+		for _, m := range page.DeleteMarkers {
+			markers = append(markers, types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+		}
+	}
+	return versions, markers, nil
+}
+
+// listObjects returns every current object in bucket.
+func listObjects(ctx context.Context, client *s3.Client, bucket string) ([]types.ObjectIdentifier, error) {
+	var ids []types.ObjectIdentifier
+	pager := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return ids, fmt.Errorf("list objects v2 error: %w", err)
+		}
+		for _, o := range page.Contents {
+			ids = append(ids, types.ObjectIdentifier{Key: o.Key})
+		}
+	}
+	return ids, nil
+}
+
+// deleteObjects deletes ids in DeleteObjects batches, falling back to one
+// DeleteObject per key where DeleteObjects is not implemented. It returns how
+// many were deleted and an error per failure.
+func deleteObjects(ctx context.Context, client *s3.Client, bucket string, ids []types.ObjectIdentifier) (int, []error) {
+	deleted := 0
+	var errs []error
+	batch := true
+	for len(ids) > 0 {
+		chunk := ids[:min(len(ids), maxDeleteBatch)]
+		ids = ids[len(chunk):]
+
+		if batch {
+			out, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket),
+				Delete: &types.Delete{Objects: chunk, Quiet: aws.Bool(true)},
+			})
+			switch {
+			case err == nil:
+				deleted += len(chunk) - len(out.Errors)
+				for _, e := range out.Errors {
+					errs = append(errs, fmt.Errorf("delete %s error: %s: %s", describe(e.Key, e.VersionId), aws.ToString(e.Code), aws.ToString(e.Message)))
+				}
+				continue
+			case !isNotImplemented(err):
+				errs = append(errs, fmt.Errorf("delete objects error (%d keys): %w", len(chunk), err))
+				continue
+			}
+			batch = false
+		}
+
+		for _, id := range chunk {
+			if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: id.Key, VersionId: id.VersionId}); err != nil {
+				errs = append(errs, fmt.Errorf("delete %s error: %w", describe(id.Key, id.VersionId), err))
+				continue
+			}
+			deleted++
+		}
+	}
+	return deleted, errs
+}
+
+func describe(key, versionID *string) string {
+	if v := aws.ToString(versionID); v != "" {
+		return fmt.Sprintf("%s (version %s)", aws.ToString(key), v)
+	}
+	return aws.ToString(key)
+}
+
+func hasErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
+
+// isNotImplemented reports whether the endpoint rejected an operation as unsupported.
+func isNotImplemented(err error) bool {
+	var status interface{ HTTPStatusCode() int }
+	return hasErrorCode(err, "NotImplemented") || (errors.As(err, &status) && status.HTTPStatusCode() == 501)
+}
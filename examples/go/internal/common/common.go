@@ -4,10 +4,14 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"s3setup/acs"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
@@ -15,6 +19,27 @@ type ConfigValues struct {
 	Endpoint        string
 	Region          string
 	AddressingStyle string
+	// ChecksumAlgorithm is the default upload checksum, or "" for none.
+	ChecksumAlgorithm string
+	// PayloadSigning is how upload bodies are signed, or "" for the SDK default.
+	PayloadSigning string
+	IAMEndpoint    string
+	IAMRegion      string
+	// Profile is the ACS profile the values were loaded from, or "" when none was used.
+	Profile string
+	// Sources maps each setting name (e.g. "endpoint") to where its value came from.
+	Sources map[string]string
+
+	acs acs.Config
+}
+
+// StyleFor returns the addressing style ("virtual" or "path") actually used for requests to bucket.
+// For "auto" this is decided per bucket. The SDK always uses path style for IP endpoints.
+func (c ConfigValues) StyleFor(bucket string) string {
+	if c.acs.Endpoint == "" {
+		return c.AddressingStyle
+	}
+	return string(c.acs.StyleFor(bucket))
 }
 
 func Env(key, def string) string {
@@ -24,6 +49,29 @@ func Env(key, def string) string {
 	return def
 }
 
+// EnvInt returns the integer in the environment variable key, or def when it
+// is unset. Commands use it for flag defaults and exit with status 2 on an
+// error, as they would for a bad flag.
+func EnvInt(key string, def int64) (int64, error) {
+	v := Env(key, "")
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	return n, nil
+}
+
+// Rate returns n per second over d, or 0 when no time has passed.
+func Rate(n int64, d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(float64(n) / d.Seconds())
+}
+
 // RandomSuffix returns a lower-hex string of n bytes (2n runes).
 func RandomSuffix(n int) string {
 	b := make([]byte, n)
@@ -31,39 +79,73 @@ func RandomSuffix(n int) string {
 	return hex.EncodeToString(b)
 }
 
-// NewS3Client builds an S3 client using env vars and returns the client and the resolved config values.
+// Clients holds S3 and IAM clients built from one resolved acs.Config, so they
+// share region settings and credentials.
+type Clients struct {
+	S3  *s3.Client
+	IAM *iam.Client
+}
+
+// NewS3Client builds an S3 client from env vars and the selected ACS profile (see LoadSettings)
+// and returns the client and the resolved config values.
 func NewS3Client(ctx context.Context) (*s3.Client, ConfigValues, error) {
-	endpoint := Env("S3_ENDPOINT", "https://acceleratedprod.com")
-	// Prefer AWS standard region envs, then fall back to S3_REGION
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		region = os.Getenv("AWS_DEFAULT_REGION")
-	}
-	if region == "" {
-		region = Env("S3_REGION", "global")
-	}
-	addr := Env("S3_ADDRESSING_STYLE", "virtual")
-	if addr != "virtual" && addr != "path" && addr != "auto" {
-		addr = "virtual"
+	return NewS3ClientFor(ctx, "", "")
+}
+
+// NewS3ClientFor builds an S3 client from a second set of connection settings
+// (see LoadSettingsFor), so one program can talk to two endpoints with their
+// own credentials and addressing styles.
+func NewS3ClientFor(ctx context.Context, envPrefix, profile string) (*s3.Client, ConfigValues, error) {
+	cfg, values, err := loadConfig(ctx, envPrefix, profile)
+	if err != nil {
+		return nil, ConfigValues{}, err
 	}
+	return cfg.NewS3Client(), values, nil
+}
 
-	// Load base AWS config with region (credentials from default chain)
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(region),
-	)
+// NewIAMClient builds an IAM client the same way NewS3Client builds an S3 client.
+func NewIAMClient(ctx context.Context) (*iam.Client, ConfigValues, error) {
+	cfg, values, err := loadConfig(ctx, "", "")
 	if err != nil {
 		return nil, ConfigValues{}, err
 	}
+	return cfg.NewIAMClient(), values, nil
+}
+
+// NewClients builds S3 and IAM clients from a single resolved acs.Config.
+func NewClients(ctx context.Context) (Clients, ConfigValues, error) {
+	cfg, values, err := loadConfig(ctx, "", "")
+	if err != nil {
+		return Clients{}, ConfigValues{}, err
+	}
+	return Clients{S3: cfg.NewS3Client(), IAM: cfg.NewIAMClient()}, values, nil
+}
 
-	usePath := addr == "path"
+// loadConfig resolves the connection settings (see LoadSettingsFor) and loads the shared ACS config
+// (credentials from the default chain, or the profile's aws_profile). Any
+// misconfiguration is returned as a *ConfigError with remediation hints.
+func loadConfig(ctx context.Context, envPrefix, profile string) (acs.Config, ConfigValues, error) {
+	settings, err := LoadSettingsFor(envPrefix, profile)
+	if err != nil {
+		return acs.Config{}, ConfigValues{}, profileProblem(err)
+	}
 
-	// This is the only change needed to use ACS
-	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		// Set a custom base endpoint for S3-compatible services
-		o.BaseEndpoint = aws.String(endpoint)
-		o.UsePathStyle = usePath
-		o.Region = region
-	})
+	cfg, err := validateSettings(ctx, settings)
+	if err != nil {
+		return acs.Config{}, ConfigValues{}, err
+	}
 
-	return client, ConfigValues{Endpoint: endpoint, Region: region, AddressingStyle: addr}, nil
+	values := ConfigValues{
+		Endpoint:          cfg.Endpoint,
+		Region:            cfg.Region,
+		AddressingStyle:   string(cfg.AddressingStyle),
+		ChecksumAlgorithm: string(cfg.ChecksumAlgorithm),
+		PayloadSigning:    string(cfg.PayloadSigning),
+		IAMEndpoint:       cfg.IAMEndpoint,
+		IAMRegion:         cfg.IAMRegion,
+		Profile:           settings.Profile,
+		Sources:           settings.Sources,
+		acs:               cfg,
+	}
+	return cfg, values, nil
 }
@@ -0,0 +1,453 @@
+package common
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// MaxCopyObjectSize is the largest object one CopyObject call can copy, and
+	// the largest range one UploadPartCopy call can copy.
+	MaxCopyObjectSize = 5 * 1024 * 1024 * 1024
+	// DefaultCopyThreshold is the source size from which a Copier switches to
+	// multipart copy by default.
+	DefaultCopyThreshold = 256 * 1024 * 1024
+	// DefaultCopyPartSize is the range one UploadPartCopy copies by default.
+	DefaultCopyPartSize = 64 * 1024 * 1024
+)
+
+// CopySource returns the x-amz-copy-source value for an object: bucket and key
+// URL-encoded with the slashes kept, and "?versionId=" appended when versionID
+// is set. Unencoded keys with spaces, '+' or non-ASCII characters are rejected
+// or resolve to the wrong object.
+func CopySource(bucket, key, versionID string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(s), "+", "%2B")
+	}
+	src := bucket + "/" + strings.Join(segments, "/")
+	if versionID != "" {
+		src += "?versionId=" + url.QueryEscape(versionID)
+	}
+	return src
+}
+
+// Copier copies objects server-side, within or across buckets. Objects below
+// Threshold are copied with one CopyObject; larger ones with concurrent
+// UploadPartCopy calls. Create one with NewCopier.
+type Copier struct {
+	Client *s3.Client
+	// Threshold is the source size from which multipart copy is used. It is
+	// capped at MaxCopyObjectSize, the most CopyObject can copy.
+	Threshold int64
+	// PartSize is the range each UploadPartCopy copies. It is raised when the
+	// source would otherwise need more than 10,000 parts.
+	PartSize int64
+	// Concurrency bounds how many UploadPartCopy calls are in flight.
+	Concurrency int
+	// PartRetries is how many times one part is retried, on top of the SDK's
+	// own retries, before the copy is aborted.
+	PartRetries int
+	// Progress, when set, is called after each part with the bytes copied so
+	// far and the source size. Calls are serialized.
+	Progress func(done, total int64)
+	// ChecksumAlgorithm, when set, has the endpoint compute and store this
+	// flexible checksum for the copy (a composite one for multipart copies).
+	// When empty, the client's default applies (see acs.WithChecksumAlgorithm).
+	ChecksumAlgorithm types.ChecksumAlgorithm
+}
+
+// NewCopier returns a Copier with the default threshold, part size,
+// concurrency and retries, adjusted by optFns.
+func NewCopier(client *s3.Client, optFns ...func(*Copier)) *Copier {
+	c := &Copier{
+		Client:      client,
+		Threshold:   DefaultCopyThreshold,
+		PartSize:    DefaultCopyPartSize,
+		Concurrency: DefaultConcurrency,
+		PartRetries: DefaultPartRetries,
+	}
+	for _, fn := range optFns {
+		fn(c)
+	}
+	return c
+}
+
+// CopyInput describes one object to copy.
+type CopyInput struct {
+	SourceBucket    string
+	SourceKey       string
+	SourceVersionID string
+	Bucket          string
+	Key             string
+	// MetadataDirective is COPY (the default) to keep the source's Content-Type
+	// and user metadata, or REPLACE to set ContentType and Metadata instead.
+	MetadataDirective types.MetadataDirective
+	ContentType       string
+	Metadata          map[string]string
+}
+
+// CopyResult describes a copied object.
+type CopyResult struct {
+	ETag      string
+	VersionID string
+	// UploadID is empty when the object was copied with CopyObject.
+	UploadID string
+	Parts    int
+	Size     int64
+	// Verified says what the copy was checked against.
+	Verified string
+	// ChecksumAlgorithm and Checksum are the checksum the endpoint reported for
+	// the copy, if any; a composite checksum ends in "-<parts>".
+	ChecksumAlgorithm types.ChecksumAlgorithm
+	Checksum          string
+}
+
+// sourceObject is the state of the copy source as Copy saw it. Every request
+// reading it carries If-Match on ETag, so a source replaced mid-copy fails the
+// copy instead of mixing two versions.
+type sourceObject struct {
+	size        int64
+	etag        string
+	contentType string
+	metadata    map[string]string
+	encrypted   bool
+	// checksums maps each stored checksum's algorithm to its base64 value.
+	checksums map[types.ChecksumAlgorithm]string
+}
+
+// Copy copies in.SourceKey to in.Key and verifies the result: the destination
+// must have the source's size, the Content-Type and metadata MetadataDirective
+// asks for, and the expected ETag where one can be predicted. UploadPartCopy
+// never carries metadata, so for multipart copies it is set on the new upload.
+// A failed multipart copy is aborted. Verification failures wrap ErrIntegrity.
+func (c *Copier) Copy(ctx context.Context, in *CopyInput) (*CopyResult, error) {
+	directive := in.MetadataDirective
+	switch directive {
+	case "":
+		directive = types.MetadataDirectiveCopy
+	case types.MetadataDirectiveCopy, types.MetadataDirectiveReplace:
+	default:
+		return nil, fmt.Errorf("metadata directive must be COPY or REPLACE, got %q", directive)
+	}
+	if c.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1, got %d", c.Concurrency)
+	}
+
+	head := &s3.HeadObjectInput{Bucket: aws.String(in.SourceBucket), Key: aws.String(in.SourceKey), ChecksumMode: types.ChecksumModeEnabled}
+	if in.SourceVersionID != "" {
+		head.VersionId = aws.String(in.SourceVersionID)
+	}
+	out, err := c.Client.HeadObject(ctx, head)
+	if err != nil {
+		return nil, fmt.Errorf("head source object error: %w", err)
+	}
+	src := &sourceObject{
+		size:        aws.ToInt64(out.ContentLength),
+		etag:        aws.ToString(out.ETag),
+		contentType: aws.ToString(out.ContentType),
+		metadata:    out.Metadata,
+		encrypted:   out.ServerSideEncryption == types.ServerSideEncryptionAwsKms || out.SSECustomerAlgorithm != nil,
+		checksums:   map[types.ChecksumAlgorithm]string{},
+	}
+	if alg, sum := checksumOf(out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumSHA1, out.ChecksumSHA256); alg != "" {
+		src.checksums[alg] = sum
+	}
+
+	wantType, wantMeta := src.contentType, src.metadata
+	if directive == types.MetadataDirectiveReplace {
+		wantType, wantMeta = in.ContentType, in.Metadata
+	}
+
+	// wantETag is the destination ETag the copy should produce, if predictable:
+	// CopyObject keeps the MD5 of an unencrypted single-part source, and a
+	// multipart copy gets the MD5 of the part ETags it reported.
+	var (
+		res      *CopyResult
+		wantETag string
+	)
+	if src.size == 0 || src.size < min(c.Threshold, MaxCopyObjectSize) {
+		res, err = c.copyObject(ctx, in, src, directive)
+		if etag := strings.Trim(src.etag, `"`); !src.encrypted && !strings.Contains(etag, "-") {
+			wantETag = etag
+		}
+	} else {
+		res, wantETag, err = c.copyParts(ctx, in, src, wantType, wantMeta)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Verified, err = c.verify(ctx, in, src, res, wantType, wantMeta, wantETag)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Copier) copyObject(ctx context.Context, in *CopyInput, src *sourceObject, directive types.MetadataDirective) (*CopyResult, error) {
+	req := &s3.CopyObjectInput{
+		Bucket:            aws.String(in.Bucket),
+		Key:               aws.String(in.Key),
+		CopySource:        aws.String(CopySource(in.SourceBucket, in.SourceKey, in.SourceVersionID)),
+		CopySourceIfMatch: aws.String(src.etag),
+		MetadataDirective: directive,
+		ChecksumAlgorithm: c.ChecksumAlgorithm,
+	}
+	if directive == types.MetadataDirectiveReplace {
+		req.ContentType = contentType(in.ContentType)
+		req.Metadata = in.Metadata
+	}
+	out, err := c.Client.CopyObject(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("copy object error: %w", err)
+	}
+	if c.Progress != nil {
+		c.Progress(src.size, src.size)
+	}
+	res := &CopyResult{VersionID: aws.ToString(out.VersionId), Parts: 1, Size: src.size}
+	if r := out.CopyObjectResult; r != nil {
+		res.ETag = aws.ToString(r.ETag)
+		res.ChecksumAlgorithm, res.Checksum = checksumOf(r.ChecksumCRC32, r.ChecksumCRC32C, r.ChecksumSHA1, r.ChecksumSHA256)
+	}
+	return res, nil
+}
+
+// copyParts copies src as a multipart upload of PartSize ranges, with up to
+// Concurrency UploadPartCopy calls at once. It also returns the ETag the
+// completed object should have, or "" when a part ETag is not an MD5.
+func (c *Copier) copyParts(ctx context.Context, in *CopyInput, src *sourceObject, wantType string, wantMeta map[string]string) (*CopyResult, string, error) {
+	partSize, err := PartSizeFor(c.PartSize, src.size)
+	if err != nil {
+		return nil, "", err
+	}
+	if partSize > MaxCopyObjectSize {
+		return nil, "", fmt.Errorf("part size %d is above the %d-byte UploadPartCopy maximum", partSize, int64(MaxCopyObjectSize))
+	}
+	count := int32((src.size + partSize - 1) / partSize)
+
+	create, err := c.Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(in.Bucket),
+		Key:         aws.String(in.Key),
+		ContentType: contentType(wantType),
+		Metadata:    wantMeta,
+		// UploadPartCopy has no checksum parameter; the upload's algorithm applies.
+		ChecksumAlgorithm: c.ChecksumAlgorithm,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("create multipart upload error: %w", err)
+	}
+	uploadID := aws.ToString(create.UploadId)
+	abort := func(cause error) error {
+		_, err := c.Client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(in.Bucket),
+			Key:      aws.String(in.Key),
+			UploadId: aws.String(uploadID),
+		})
+		if err != nil {
+			return errors.Join(cause, fmt.Errorf("abort multipart upload %s error: %w", uploadID, err))
+		}
+		return cause
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu        sync.Mutex
+		completed []types.CompletedPart
+		copied    int64
+		firstErr  error
+	)
+	queue := make(chan int32)
+	var wg sync.WaitGroup
+	for range c.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range queue {
+				start := int64(n-1) * partSize
+				end := min(start+partSize, src.size) - 1
+				part, err := c.copyPart(ctx, in, src, uploadID, n, start, end)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					completed = append(completed, part)
+					copied += end - start + 1
+					if c.Progress != nil {
+						c.Progress(copied, src.size)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for n := int32(1); n <= count; n++ {
+		select {
+		case queue <- n:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, "", abort(firstErr)
+	}
+
+	sort.Slice(completed, func(i, j int) bool {
+		return aws.ToInt32(completed[i].PartNumber) < aws.ToInt32(completed[j].PartNumber)
+	})
+	done, err := c.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(in.Bucket),
+		Key:             aws.String(in.Key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return nil, "", abort(fmt.Errorf("complete multipart upload error: %w", err))
+	}
+	res := &CopyResult{
+		ETag:      aws.ToString(done.ETag),
+		VersionID: aws.ToString(done.VersionId),
+		UploadID:  uploadID,
+		Parts:     len(completed),
+		Size:      src.size,
+	}
+	res.ChecksumAlgorithm, res.Checksum = checksumOf(done.ChecksumCRC32, done.ChecksumCRC32C, done.ChecksumSHA1, done.ChecksumSHA256)
+	return res, multipartETag(completed), nil
+}
+
+// copyPart copies bytes start..end of the source into part n, retrying up to
+// PartRetries times. A changed source (If-Match failed) is not retried.
+func (c *Copier) copyPart(ctx context.Context, in *CopyInput, src *sourceObject, uploadID string, n int32, start, end int64) (types.CompletedPart, error) {
+	var err error
+	for attempt := 0; attempt <= c.PartRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
+			case <-ctx.Done():
+				return types.CompletedPart{}, ctx.Err()
+			}
+		}
+		var out *s3.UploadPartCopyOutput
+		out, err = c.Client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:            aws.String(in.Bucket),
+			Key:               aws.String(in.Key),
+			UploadId:          aws.String(uploadID),
+			PartNumber:        aws.Int32(n),
+			CopySource:        aws.String(CopySource(in.SourceBucket, in.SourceKey, in.SourceVersionID)),
+			CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+			CopySourceIfMatch: aws.String(src.etag),
+		})
+		if err == nil {
+			if out.CopyPartResult == nil || out.CopyPartResult.ETag == nil {
+				return types.CompletedPart{}, fmt.Errorf("upload part copy %d error: no ETag in the response", n)
+			}
+			return CopiedPart(n, out.CopyPartResult), nil
+		}
+		if ctx.Err() != nil {
+			return types.CompletedPart{}, err
+		}
+		if hasErrorCode(err, "PreconditionFailed") {
+			return types.CompletedPart{}, fmt.Errorf("source %s changed during the copy (ETag is no longer %s): %w", in.SourceKey, src.etag, err)
+		}
+	}
+	return types.CompletedPart{}, fmt.Errorf("upload part copy %d error (%d attempts): %w", n, c.PartRetries+1, err)
+}
+
+// verify checks the destination's size, Content-Type and metadata, its ETag
+// when wantETag is set, and its checksum when source and copy both have a
+// full-object checksum of the same algorithm. It returns what it checked.
+func (c *Copier) verify(ctx context.Context, in *CopyInput, src *sourceObject, res *CopyResult, wantType string, wantMeta map[string]string, wantETag string) (string, error) {
+	size := src.size
+	head := &s3.HeadObjectInput{Bucket: aws.String(in.Bucket), Key: aws.String(in.Key), ChecksumMode: types.ChecksumModeEnabled}
+	if res.VersionID != "" && res.VersionID != "null" {
+		head.VersionId = aws.String(res.VersionID)
+	}
+	out, err := c.Client.HeadObject(ctx, head)
+	if err != nil {
+		return "", fmt.Errorf("head copied object error: %w", err)
+	}
+	if got := aws.ToInt64(out.ContentLength); got != size {
+		return "", fmt.Errorf("%w: copy is %d bytes, source is %d", ErrIntegrity, got, size)
+	}
+	if got := aws.ToString(out.ContentType); wantType != "" && got != wantType {
+		return "", fmt.Errorf("%w: copy has Content-Type %q, want %q", ErrIntegrity, got, wantType)
+	}
+	if !sameMetadata(out.Metadata, wantMeta) {
+		return "", fmt.Errorf("%w: copy has metadata %v, want %v", ErrIntegrity, out.Metadata, wantMeta)
+	}
+	checked := []string{"size", "metadata"}
+	if wantETag != "" {
+		if got := strings.Trim(aws.ToString(out.ETag), `"`); got != wantETag {
+			return "", fmt.Errorf("%w: copy has ETag %s, want %s", ErrIntegrity, got, wantETag)
+		}
+		checked = append(checked, "ETag")
+	}
+	alg, sum := checksumOf(out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumSHA1, out.ChecksumSHA256)
+	if want, ok := src.checksums[alg]; ok && !strings.Contains(want, "-") && !strings.Contains(sum, "-") {
+		if sum != want {
+			return "", fmt.Errorf("%w: copy has %s checksum %s, source has %s", ErrIntegrity, alg, sum, want)
+		}
+		checked = append(checked, string(alg)+" checksum")
+	}
+	return strings.Join(checked[:len(checked)-1], ", ") + " and " + checked[len(checked)-1], nil
+}
+
+// sameMetadata compares user metadata; S3 returns the names in lower case.
+func sameMetadata(got, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for k, v := range want {
+		if got[strings.ToLower(k)] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// multipartETag returns the ETag S3 gives an object completed from parts: the
+// MD5 of the part MD5s and the part count. It returns "" when a part ETag is
+// not an MD5.
+func multipartETag(parts []types.CompletedPart) string {
+	h := md5.New()
+	for _, p := range parts {
+		sum, err := hex.DecodeString(strings.Trim(aws.ToString(p.ETag), `"`))
+		if err != nil || len(sum) != md5.Size {
+			return ""
+		}
+		h.Write(sum)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(parts))
+}
+
+// IsMD5 reports whether etag, without its quotes, is a plain hex MD5, as
+// PutObject gives unencrypted objects, rather than a multipart ETag ("...-N")
+// or an opaque one.
+func IsMD5(etag string) bool {
+	if len(etag) != 2*md5.Size {
+		return false
+	}
+	_, err := hex.DecodeString(etag)
+	return err == nil
+}
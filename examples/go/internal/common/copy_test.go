@@ -0,0 +1,126 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestCopySource(t *testing.T) {
+	for _, tc := range []struct{ bucket, key, version, want string }{
+		{"b", "plain.txt", "", "b/plain.txt"},
+		{"b", "dir/sub/file.txt", "", "b/dir/sub/file.txt"},
+		{"b", "a b+c.txt", "", "b/a%20b%2Bc.txt"},
+		{"b", "ünï/çødé?.txt", "", "b/%C3%BCn%C3%AF/%C3%A7%C3%B8d%C3%A9%3F.txt"},
+		{"b", "k", "v1+/=", "b/k?versionId=v1%2B%2F%3D"},
+	} {
+		if got := CopySource(tc.bucket, tc.key, tc.version); got != tc.want {
+			t.Errorf("CopySource(%q, %q, %q) = %q, want %q", tc.bucket, tc.key, tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	meta := map[string]string{"owner": "data-team"}
+	for _, tc := range []struct {
+		name      string
+		size      int
+		directive types.MetadataDirective
+		checksum  types.ChecksumAlgorithm
+		parts     int
+		verified  string
+	}{
+		{name: "copy object", size: 1 << 20, parts: 1, verified: "size, metadata and ETag"},
+		{name: "replace metadata", size: 1 << 20, directive: types.MetadataDirectiveReplace, parts: 1, verified: "size, metadata and ETag"},
+		{name: "checksum", size: 1 << 20, checksum: types.ChecksumAlgorithmSha256, parts: 1, verified: "size, metadata, ETag and SHA256 checksum"},
+		{name: "multipart", size: 2*MinPartSize + 10, parts: 3, verified: "size, metadata and ETag"},
+		{name: "multipart replace metadata", size: 2*MinPartSize + 10, directive: types.MetadataDirectiveReplace, parts: 3, verified: "size, metadata and ETag"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, client, bucket := newTestBucket(t)
+			ctx := context.Background()
+			data := testData(tc.size)
+			srcKey := "dir/a b+c ü.txt"
+			if _, err := client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket: &bucket, Key: &srcKey, Body: bytes.NewReader(data),
+				ContentType: aws.String("text/plain"), Metadata: meta, ChecksumAlgorithm: tc.checksum,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			fake.reset()
+			copier := NewCopier(client, func(c *Copier) {
+				c.Threshold = MinPartSize
+				c.PartSize = MinPartSize
+				c.ChecksumAlgorithm = tc.checksum
+			})
+			in := &CopyInput{SourceBucket: bucket, SourceKey: srcKey, Bucket: bucket, Key: "copy/" + srcKey, MetadataDirective: tc.directive}
+			wantType, wantMeta := "text/plain", meta
+			if tc.directive == types.MetadataDirectiveReplace {
+				in.ContentType, in.Metadata = "application/json", map[string]string{"stage": "copied"}
+				wantType, wantMeta = in.ContentType, in.Metadata
+			}
+			res, err := copier.Copy(ctx, in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Parts != tc.parts || res.Size != int64(len(data)) || (tc.parts > 1) != (res.UploadID != "") {
+				t.Errorf("copied %d bytes in %d parts (upload %q), want %d bytes in %d", res.Size, res.Parts, res.UploadID, len(data), tc.parts)
+			}
+			if res.Verified != tc.verified {
+				t.Errorf("verified %q, want %q", res.Verified, tc.verified)
+			}
+
+			if tc.parts == 1 {
+				if n := len(fake.requests("CopyObject")); n != 1 {
+					t.Errorf("sent %d CopyObject, want 1", n)
+				}
+			} else {
+				var ranges, want []string
+				for _, r := range fake.requests("UploadPartCopy") {
+					if r.Header.Get("X-Amz-Copy-Source") != CopySource(bucket, srcKey, "") {
+						t.Errorf("copy source %q, want %q", r.Header.Get("X-Amz-Copy-Source"), CopySource(bucket, srcKey, ""))
+					}
+					if r.Header.Get("X-Amz-Copy-Source-If-Match") == "" {
+						t.Error("UploadPartCopy sent without x-amz-copy-source-if-match")
+					}
+					ranges = append(ranges, r.Header.Get("X-Amz-Copy-Source-Range"))
+				}
+				for off := 0; off < len(data); off += MinPartSize {
+					want = append(want, fmt.Sprintf("bytes=%d-%d", off, min(off+MinPartSize, len(data))-1))
+				}
+				sort.Strings(ranges)
+				sort.Strings(want)
+				if strings.Join(ranges, " ") != strings.Join(want, " ") {
+					t.Errorf("copied ranges %q, want %q", ranges, want)
+				}
+			}
+
+			if got := getObject(t, client, bucket, in.Key); !bytes.Equal(got, data) {
+				t.Errorf("copy has %d bytes that differ from the source", len(got))
+			}
+			head, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: &in.Key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aws.ToString(head.ContentType) != wantType || !sameMetadata(head.Metadata, wantMeta) {
+				t.Errorf("copy has Content-Type %q and metadata %v, want %q and %v", aws.ToString(head.ContentType), head.Metadata, wantType, wantMeta)
+			}
+		})
+	}
+}
+
+func TestCopyRejectsBadDirective(t *testing.T) {
+	_, client, bucket := newTestBucket(t)
+	_, err := NewCopier(client).Copy(context.Background(), &CopyInput{SourceBucket: bucket, SourceKey: "a", Bucket: bucket, Key: "b", MetadataDirective: "MERGE"})
+	if err == nil || !strings.Contains(err.Error(), "COPY or REPLACE") {
+		t.Fatalf("copy: %v, want a metadata directive error", err)
+	}
+}
@@ -0,0 +1,431 @@
+package common
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"s3setup/acs"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrIntegrity is wrapped by errors reporting that downloaded or copied content
+// does not match the object's checksum, ETag or size.
+var ErrIntegrity = errors.New("content does not match the object")
+
+// Downloader fetches objects with concurrent ranged GETs, writing each range at
+// its offset in the destination. Create one with NewDownloader.
+type Downloader struct {
+	Client *s3.Client
+	// PartSize is the length of each range.
+	PartSize int64
+	// Concurrency bounds how many ranges are in flight.
+	Concurrency int
+	// PartRetries is how many times one range is retried, on top of the SDK's
+	// own retries, before the download fails.
+	PartRetries int
+	// Progress, when set, is called after each range with the bytes written so
+	// far, including skipped ranges, and the object size. Calls are serialized.
+	Progress func(done, total int64)
+	// RangeDone, when set, is called with the index of each range once it is
+	// written, so callers can record progress for a later resume. Calls are
+	// serialized.
+	RangeDone func(i int)
+}
+
+// NewDownloader returns a Downloader with the same defaults as NewUploader,
+// adjusted by optFns.
+func NewDownloader(client *s3.Client, optFns ...func(*Downloader)) *Downloader {
+	d := &Downloader{
+		Client:      client,
+		PartSize:    DefaultPartSize,
+		Concurrency: DefaultConcurrency,
+		PartRetries: DefaultPartRetries,
+	}
+	for _, fn := range optFns {
+		fn(d)
+	}
+	return d
+}
+
+// ObjectInfo is the state of an object as Stat saw it. Download fetches exactly
+// this version: every range is requested with If-Match on ETag.
+type ObjectInfo struct {
+	Bucket    string
+	Key       string
+	Size      int64
+	ETag      string
+	VersionID string
+	// Checksums maps each stored checksum's algorithm (CRC32, CRC32C, SHA1,
+	// SHA256) to its base64 value.
+	Checksums map[string]string
+	// Encrypted is set for SSE-KMS and SSE-C objects, whose ETag is not an MD5
+	// of the content.
+	Encrypted bool
+}
+
+// DownloadTarget is where a Downloader writes. It is read back to verify the
+// content. *os.File satisfies it.
+type DownloadTarget interface {
+	io.WriterAt
+	io.ReaderAt
+}
+
+// Stat looks up an object's size, ETag and stored checksums.
+func (d *Downloader) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	out, err := d.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("head object error: %w", err)
+	}
+	info := &ObjectInfo{
+		Bucket:    bucket,
+		Key:       key,
+		Size:      aws.ToInt64(out.ContentLength),
+		ETag:      aws.ToString(out.ETag),
+		VersionID: aws.ToString(out.VersionId),
+		Checksums: map[string]string{},
+		Encrypted: out.ServerSideEncryption == types.ServerSideEncryptionAwsKms || out.SSECustomerAlgorithm != nil,
+	}
+	for alg, v := range map[string]*string{
+		"CRC32":  out.ChecksumCRC32,
+		"CRC32C": out.ChecksumCRC32C,
+		"SHA1":   out.ChecksumSHA1,
+		"SHA256": out.ChecksumSHA256,
+	} {
+		if v != nil {
+			info.Checksums[alg] = *v
+		}
+	}
+	return info, nil
+}
+
+// Ranges returns how many ranges of PartSize an object of size bytes is fetched in.
+func (d *Downloader) Ranges(size int64) int {
+	return int((size + d.PartSize - 1) / d.PartSize)
+}
+
+// DownloadFile downloads bucket/key to a new file at path, replacing any file
+// there, and returns what it downloaded and how it was verified.
+func (d *Downloader) DownloadFile(ctx context.Context, bucket, key, path string) (*ObjectInfo, string, error) {
+	obj, err := d.Stat(ctx, bucket, key)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	verified, err := d.Download(ctx, f, obj, nil)
+	return obj, verified, err
+}
+
+// Download fetches obj into dst with up to Concurrency ranged GETs, skipping the
+// range indexes in skip, which are already in dst. Each failed range is retried
+// up to PartRetries times. Then it reads dst back and verifies it against a
+// stored checksum or, failing that, the ETag. It returns which one it checked
+// (e.g. "SHA256" or "ETag (MD5 of 3 parts)"), or "" when neither could be.
+func (d *Downloader) Download(ctx context.Context, dst DownloadTarget, obj *ObjectInfo, skip map[int]bool) (string, error) {
+	if d.Concurrency < 1 {
+		return "", fmt.Errorf("concurrency must be at least 1, got %d", d.Concurrency)
+	}
+	if d.PartSize < 1 {
+		return "", fmt.Errorf("part size must be positive, got %d", d.PartSize)
+	}
+	if err := d.fetchRanges(ctx, dst, obj, skip); err != nil {
+		return "", err
+	}
+	return d.Verify(ctx, dst, obj)
+}
+
+func (d *Downloader) fetchRanges(ctx context.Context, dst io.WriterAt, obj *ObjectInfo, skip map[int]bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	n := d.Ranges(obj.Size)
+	var (
+		mu       sync.Mutex
+		written  int64
+		firstErr error
+	)
+	for i := range n {
+		if skip[i] {
+			written += d.rangeLen(obj.Size, i)
+		}
+	}
+	if d.Progress != nil && written > 0 {
+		d.Progress(written, obj.Size)
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range d.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				err := d.fetchRange(ctx, dst, obj, i)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					written += d.rangeLen(obj.Size, i)
+					if d.Progress != nil {
+						d.Progress(written, obj.Size)
+					}
+					if d.RangeDone != nil {
+						d.RangeDone(i)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for i := range n {
+		if skip[i] {
+			continue
+		}
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+func (d *Downloader) rangeLen(size int64, i int) int64 {
+	return min(size, int64(i+1)*d.PartSize) - int64(i)*d.PartSize
+}
+
+// fetchRange GETs range i and writes it at its offset, retrying up to
+// PartRetries times. A changed object (If-Match failed) is not retried.
+func (d *Downloader) fetchRange(ctx context.Context, dst io.WriterAt, obj *ObjectInfo, i int) error {
+	start := int64(i) * d.PartSize
+	length := d.rangeLen(obj.Size, i)
+	var err error
+	for attempt := 0; attempt <= d.PartRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err = d.getRange(ctx, dst, obj, start, length)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if hasErrorCode(err, "PreconditionFailed") {
+			return fmt.Errorf("object %s changed during the download (ETag is no longer %s): %w", obj.Key, obj.ETag, err)
+		}
+	}
+	return fmt.Errorf("get range %d error (%d attempts): %w", i, d.PartRetries+1, err)
+}
+
+func (d *Downloader) getRange(ctx context.Context, dst io.WriterAt, obj *ObjectInfo, start, length int64) error {
+	in := &s3.GetObjectInput{
+		Bucket:  aws.String(obj.Bucket),
+		Key:     aws.String(obj.Key),
+		Range:   aws.String(fmt.Sprintf("bytes=%d-%d", start, start+length-1)),
+		IfMatch: aws.String(obj.ETag),
+	}
+	if obj.VersionID != "" && obj.VersionID != "null" {
+		in.VersionId = aws.String(obj.VersionID)
+	}
+	out, err := d.Client.GetObject(ctx, in)
+	if err != nil {
+		return fmt.Errorf("get object error: %w", err)
+	}
+	defer out.Body.Close()
+	n, err := io.Copy(io.NewOffsetWriter(dst, start), io.LimitReader(out.Body, length))
+	if err != nil {
+		return fmt.Errorf("read body error: %w", err)
+	}
+	if n != length {
+		return fmt.Errorf("read body error: got %d of %d bytes at offset %d", n, length, start)
+	}
+	return nil
+}
+
+// Verify reads obj's content back from src and compares it with a stored
+// checksum, or else with the ETag: the MD5 of the content, or for a multipart
+// upload the MD5 of the part MD5s. A composite checksum ("...-N", from a
+// multipart upload with a ChecksumAlgorithm) is likewise the checksum of the
+// part checksums. Part boundaries are learned from HeadObject with PartNumber.
+// It returns what it checked, or "" when nothing could be checked (an
+// encrypted object, or part boundaries the endpoint does not report). A
+// mismatch wraps ErrIntegrity.
+func (d *Downloader) Verify(ctx context.Context, src io.ReaderAt, obj *ObjectInfo) (string, error) {
+	for _, alg := range []string{"SHA256", "SHA1", "CRC32C", "CRC32"} {
+		want, ok := obj.Checksums[alg]
+		if !ok {
+			continue
+		}
+		if sum, parts, composite := strings.Cut(want, "-"); composite {
+			how, err := d.verifyComposite(ctx, src, obj, alg, sum, parts)
+			if how == "" && err == nil {
+				continue
+			}
+			return how, err
+		}
+		h, err := acs.NewChecksum(types.ChecksumAlgorithm(alg))
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(h, io.NewSectionReader(src, 0, obj.Size)); err != nil {
+			return "", fmt.Errorf("read back error: %w", err)
+		}
+		if got := base64.StdEncoding.EncodeToString(h.Sum(nil)); got != want {
+			return "", fmt.Errorf("%w: %s is %s, stored checksum is %s", ErrIntegrity, alg, got, want)
+		}
+		return alg, nil
+	}
+
+	etag := strings.Trim(obj.ETag, `"`)
+	if obj.Encrypted || etag == "" {
+		return "", nil
+	}
+	hexSum, parts, multipart := strings.Cut(etag, "-")
+	if !multipart {
+		h := md5.New()
+		if _, err := io.Copy(h, io.NewSectionReader(src, 0, obj.Size)); err != nil {
+			return "", fmt.Errorf("read back error: %w", err)
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != hexSum {
+			return "", fmt.Errorf("%w: MD5 is %s, ETag is %s", ErrIntegrity, got, etag)
+		}
+		return "ETag (MD5)", nil
+	}
+
+	count, err := strconv.Atoi(parts)
+	if err != nil {
+		return "", nil
+	}
+	sizes, ok := d.partSizes(ctx, obj, count)
+	if !ok {
+		return "", nil
+	}
+	sums, err := sumOfParts(src, sizes, md5.New(), md5.New())
+	if err != nil {
+		return "", err
+	}
+	if got := fmt.Sprintf("%s-%d", hex.EncodeToString(sums), count); got != etag {
+		return "", fmt.Errorf("%w: multipart MD5 is %s, ETag is %s", ErrIntegrity, got, etag)
+	}
+	return fmt.Sprintf("ETag (MD5 of %d parts)", count), nil
+}
+
+// verifyComposite checks a composite checksum, sum over parts parts. It
+// returns "" without an error when the part boundaries are unknown.
+func (d *Downloader) verifyComposite(ctx context.Context, src io.ReaderAt, obj *ObjectInfo, alg, sum, parts string) (string, error) {
+	count, err := strconv.Atoi(parts)
+	if err != nil {
+		return "", nil
+	}
+	sizes, ok := d.partSizes(ctx, obj, count)
+	if !ok {
+		return "", nil
+	}
+	var hs [2]hash.Hash
+	for i := range hs {
+		if hs[i], err = acs.NewChecksum(types.ChecksumAlgorithm(alg)); err != nil {
+			return "", err
+		}
+	}
+	sums, err := sumOfParts(src, sizes, hs[0], hs[1])
+	if err != nil {
+		return "", err
+	}
+	if got := base64.StdEncoding.EncodeToString(sums); got != sum {
+		return "", fmt.Errorf("%w: composite %s is %s-%d, stored checksum is %s-%s", ErrIntegrity, alg, got, count, sum, parts)
+	}
+	return fmt.Sprintf("%s (composite of %d parts)", alg, count), nil
+}
+
+// partSizes asks for the length of every part with HeadObject and PartNumber,
+// up to Concurrency at once: parts need not share a size, so part 1 alone does
+// not give the boundaries. It reports false when a part does not answer, or
+// the part count or sizes do not add up to the object.
+func (d *Downloader) partSizes(ctx context.Context, obj *ObjectInfo, count int) ([]int64, bool) {
+	if count < 1 || count > maxParts {
+		return nil, false
+	}
+	sizes := make([]int64, count)
+	var (
+		mu sync.Mutex
+		ok = true
+		wg sync.WaitGroup
+	)
+	sem := make(chan struct{}, max(d.Concurrency, 1))
+	for i := range count {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			in := &s3.HeadObjectInput{Bucket: aws.String(obj.Bucket), Key: aws.String(obj.Key), PartNumber: aws.Int32(int32(i + 1)), IfMatch: aws.String(obj.ETag)}
+			if obj.VersionID != "" && obj.VersionID != "null" {
+				in.VersionId = aws.String(obj.VersionID)
+			}
+			out, err := d.Client.HeadObject(ctx, in)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || aws.ToInt32(out.PartsCount) != int32(count) || aws.ToInt64(out.ContentLength) <= 0 {
+				ok = false
+				return
+			}
+			sizes[i] = aws.ToInt64(out.ContentLength)
+		}()
+	}
+	wg.Wait()
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	return sizes, ok && total == obj.Size
+}
+
+// sumOfParts hashes each part of src, of the given sizes, with h and returns
+// the hash of the part hashes, taken with sums, as S3 builds multipart ETags
+// and composite checksums. h and sums must be the same algorithm.
+func sumOfParts(src io.ReaderAt, sizes []int64, sums, h hash.Hash) ([]byte, error) {
+	var off int64
+	for _, size := range sizes {
+		h.Reset()
+		if _, err := io.Copy(h, io.NewSectionReader(src, off, size)); err != nil {
+			return nil, fmt.Errorf("read back error: %w", err)
+		}
+		sums.Write(h.Sum(nil))
+		off += size
+	}
+	return sums.Sum(nil), nil
+}
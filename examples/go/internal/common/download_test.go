@@ -0,0 +1,288 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// testObjects are uploaded by putTestObjects: one of each kind Verify checks
+// differently, with what it should report.
+var testObjects = []struct {
+	key       string
+	multipart bool
+	checksum  types.ChecksumAlgorithm
+	verified  string
+}{
+	{key: "single.bin", verified: "ETag (MD5)"},
+	{key: "multipart.bin", multipart: true, verified: "ETag (MD5 of 3 parts)"},
+	{key: "sha256.bin", checksum: types.ChecksumAlgorithmSha256, verified: "SHA256"},
+	{key: "composite.bin", multipart: true, checksum: types.ChecksumAlgorithmCrc32c, verified: "CRC32C (composite of 3 parts)"},
+}
+
+func putTestObjects(t *testing.T, client *s3.Client, bucket string, data []byte) {
+	t.Helper()
+	for _, o := range testObjects {
+		// Twice the size keeps the whole object in one PutObject.
+		partSize := 2 * int64(len(data))
+		if o.multipart {
+			partSize = MinPartSize
+		}
+		uploader := NewUploader(client, func(u *Uploader) {
+			u.PartSize = partSize
+			u.ChecksumAlgorithm = o.checksum
+		})
+		if _, err := uploader.Upload(context.Background(), &UploadInput{Bucket: bucket, Key: o.key, Body: bytes.NewReader(data), Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	fake, client, bucket := newTestBucket(t)
+	data := testData(2*MinPartSize + 1)
+	putTestObjects(t, client, bucket, data)
+
+	downloader := NewDownloader(client, func(d *Downloader) {
+		d.PartSize = 1 << 20
+		d.Concurrency = 4
+	})
+	for _, o := range testObjects {
+		t.Run(o.key, func(t *testing.T) {
+			fake.reset()
+			path := filepath.Join(t.TempDir(), o.key)
+			obj, verified, err := downloader.DownloadFile(context.Background(), bucket, o.key, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verified != o.verified {
+				t.Errorf("verified by %q, want %q", verified, o.verified)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("downloaded %d bytes that differ from the %d uploaded", len(got), len(data))
+			}
+
+			gets := fake.requests("GetObject")
+			if len(gets) != downloader.Ranges(obj.Size) {
+				t.Fatalf("sent %d GETs, want one per range (%d)", len(gets), downloader.Ranges(obj.Size))
+			}
+			var ranges, want []string
+			for _, r := range gets {
+				if r.Header.Get("If-Match") != obj.ETag {
+					t.Errorf("range %s sent with If-Match %q, want %s", r.Header.Get("Range"), r.Header.Get("If-Match"), obj.ETag)
+				}
+				ranges = append(ranges, r.Header.Get("Range"))
+			}
+			for off := 0; off < len(data); off += 1 << 20 {
+				want = append(want, fmt.Sprintf("bytes=%d-%d", off, min(off+1<<20, len(data))-1))
+			}
+			sort.Strings(ranges)
+			sort.Strings(want)
+			if strings.Join(ranges, " ") != strings.Join(want, " ") {
+				t.Errorf("requested ranges %q, want %q", ranges, want)
+			}
+		})
+	}
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	_, client, bucket := newTestBucket(t)
+	data := testData(2*MinPartSize + 1)
+	putTestObjects(t, client, bucket, data)
+	corrupt := bytes.Clone(data)
+	corrupt[MinPartSize+7]++
+
+	downloader := NewDownloader(client)
+	for _, o := range testObjects {
+		t.Run(o.key, func(t *testing.T) {
+			obj, err := downloader.Stat(context.Background(), bucket, o.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := downloader.Verify(context.Background(), bytes.NewReader(data), obj); err != nil {
+				t.Fatalf("verify intact content: %v", err)
+			}
+			if _, err := downloader.Verify(context.Background(), bytes.NewReader(corrupt), obj); !errors.Is(err, ErrIntegrity) {
+				t.Errorf("verify corrupted content: %v, want ErrIntegrity", err)
+			}
+		})
+	}
+}
+
+// TestDownloadSkipsRanges resumes a download with its first range already in
+// place, as cmd/download does from its saved state.
+func TestDownloadSkipsRanges(t *testing.T) {
+	fake, client, bucket := newTestBucket(t)
+	data := testData(3<<20 + 5)
+	if _, err := client.PutObject(context.Background(), &s3.PutObjectInput{Bucket: &bucket, Key: aws.String("k"), Body: bytes.NewReader(data)}); err != nil {
+		t.Fatal(err)
+	}
+	var done []int
+	var first int64 = -1
+	downloader := NewDownloader(client, func(d *Downloader) {
+		d.PartSize = 1 << 20
+		d.RangeDone = func(i int) { done = append(done, i) }
+		d.Progress = func(n, _ int64) {
+			if first < 0 {
+				first = n
+			}
+		}
+	})
+	obj, err := downloader.Stat(context.Background(), bucket, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(t.TempDir(), "k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(data[:1<<20], 0); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.reset()
+	if _, err := downloader.Download(context.Background(), f, obj, map[int]bool{0: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fake.requests("GetObject")); n != 3 {
+		t.Errorf("sent %d GETs, want 3 for the ranges not skipped", n)
+	}
+	sort.Ints(done)
+	if fmt.Sprint(done) != "[1 2 3]" {
+		t.Errorf("RangeDone called for %v, want [1 2 3]", done)
+	}
+	if first != 1<<20 {
+		t.Errorf("first progress %d, want the skipped range (%d)", first, 1<<20)
+	}
+}
+
+// TestDownloadObjectChanged replaces the object between Stat and Download: the
+// If-Match on every range must stop the download instead of mixing versions.
+func TestDownloadObjectChanged(t *testing.T) {
+	fake, client, bucket := newTestBucket(t)
+	ctx := context.Background()
+	put := func(data []byte) {
+		if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: aws.String("k"), Body: bytes.NewReader(data)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := testData(2 << 20)
+	put(data)
+	downloader := NewDownloader(client, func(d *Downloader) {
+		d.PartSize = 1 << 20
+		d.Concurrency = 1
+	})
+	obj, err := downloader.Stat(ctx, bucket, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[0]++
+	put(data)
+
+	fake.reset()
+	f, err := os.Create(filepath.Join(t.TempDir(), "k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = downloader.Download(ctx, f, obj, nil)
+	if err == nil || !strings.Contains(err.Error(), "changed during the download") {
+		t.Fatalf("download: %v, want a changed object error", err)
+	}
+	if n := len(fake.requests("GetObject")); n != 1 {
+		t.Errorf("sent %d GETs, want 1: a changed object is not retried", n)
+	}
+}
+
+// TestVerifyUnevenParts verifies an object whose parts differ in size: 6 MiB,
+// 5 MiB and 5 MiB. Assuming every part is as long as part 1 would hash the
+// wrong ranges and report corruption.
+func TestVerifyUnevenParts(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		checksum types.ChecksumAlgorithm
+		verified string
+	}{
+		{"etag", "", "ETag (MD5 of 3 parts)"},
+		{"composite", types.ChecksumAlgorithmCrc32c, "CRC32C (composite of 3 parts)"},
+		{"composite sha256", types.ChecksumAlgorithmSha256, "SHA256 (composite of 3 parts)"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, client, bucket := newTestBucket(t)
+			ctx := context.Background()
+			data := putParts(t, client, bucket, "k", tc.checksum, 6<<20, 5<<20, 5<<20)
+			downloader := NewDownloader(client)
+			obj, err := downloader.Stat(ctx, bucket, "k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			verified, err := downloader.Verify(ctx, bytes.NewReader(data), obj)
+			if err != nil || verified != tc.verified {
+				t.Fatalf("verify intact content: %q, %v, want %q", verified, err, tc.verified)
+			}
+			corrupt := bytes.Clone(data)
+			corrupt[len(corrupt)-1]++
+			if _, err := downloader.Verify(ctx, bytes.NewReader(corrupt), obj); !errors.Is(err, ErrIntegrity) {
+				t.Errorf("verify corrupted content: %v, want ErrIntegrity", err)
+			}
+
+			// Without the size of every part the boundaries are unknown, which
+			// leaves the content unverified rather than corrupt.
+			fake.setFail(func(r *http.Request) bool {
+				return r.Method == http.MethodHead && r.URL.Query().Get("partNumber") == "3"
+			})
+			verified, err = downloader.Verify(ctx, bytes.NewReader(data), obj)
+			if err != nil || verified != "" {
+				t.Errorf("verify without part 3's size: %q, %v, want unverified", verified, err)
+			}
+		})
+	}
+}
+
+// putParts uploads key as a multipart upload with parts of the given sizes,
+// sent with checksum when set, and returns the content.
+func putParts(t *testing.T, client *s3.Client, bucket, key string, checksum types.ChecksumAlgorithm, sizes ...int) []byte {
+	t.Helper()
+	ctx := context.Background()
+	create, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: &bucket, Key: &key, ChecksumAlgorithm: checksum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	var parts []types.CompletedPart
+	for i, size := range sizes {
+		part := bytes.Repeat([]byte{byte('a' + i)}, size)
+		out, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket: &bucket, Key: &key, UploadId: create.UploadId, PartNumber: aws.Int32(int32(i + 1)),
+			Body: bytes.NewReader(part), ChecksumAlgorithm: checksum,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, CompletedPart(int32(i+1), out))
+		data = append(data, part...)
+	}
+	if _, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket: &bucket, Key: &key, UploadId: create.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
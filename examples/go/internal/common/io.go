@@ -2,7 +2,9 @@ package common
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"os"
 )
 
 func BytesReader(b []byte) *bytes.Reader { return bytes.NewReader(b) }
@@ -11,3 +13,27 @@ func ReadAll(rc io.ReadCloser) ([]byte, error) {
 	defer rc.Close()
 	return io.ReadAll(rc)
 }
+
+// WriteFileAtomic writes data to path through a temporary file that is renamed
+// into place, so a crash mid-write keeps the previous copy.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// FormatBytes formats n with a binary unit, e.g. "12.5 MiB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"s3setup/acs"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	// DefaultPresignExpires is how long presigned URLs stay valid by default.
+	DefaultPresignExpires = 15 * time.Minute
+	// MaxPresignExpires is the longest validity S3 accepts for a presigned URL.
+	MaxPresignExpires = 7 * 24 * time.Hour
+)
+
+// PresignedRequest is a presigned URL and what a client must send with it.
+type PresignedRequest struct {
+	Method string
+	URL    string
+	// SignedHeader holds the headers, besides Host, the request must carry
+	// exactly as presigned. Other headers, such as Content-Type, are not signed
+	// and may be sent freely.
+	SignedHeader http.Header
+	// Expires is when the URL stops working.
+	Expires time.Time
+}
+
+// NewRequest builds a plain net/http request for the URL, with the signed
+// headers set. body may be nil.
+func (r *PresignedRequest) NewRequest(ctx context.Context, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range r.SignedHeader {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+	return req, nil
+}
+
+// Presigner creates presigned URLs that let a holder without credentials get,
+// head, put or delete one object, or upload one part of a multipart upload.
+// The URLs use the client's endpoint, addressing style and credentials; the
+// checksum and payload signing defaults do not apply, since the body is not
+// known when presigning. Create one with NewPresigner.
+type Presigner struct {
+	Client *s3.Client
+	// Expires is how long each URL stays valid, at most MaxPresignExpires.
+	// URLs signed with temporary credentials stop working when those expire.
+	Expires time.Duration
+}
+
+// NewPresigner returns a Presigner with DefaultPresignExpires, adjusted by optFns.
+func NewPresigner(client *s3.Client, optFns ...func(*Presigner)) *Presigner {
+	p := &Presigner{Client: client, Expires: DefaultPresignExpires}
+	for _, fn := range optFns {
+		fn(p)
+	}
+	return p
+}
+
+// GetObject presigns a GET of bucket/key.
+func (p *Presigner) GetObject(ctx context.Context, bucket, key string) (*PresignedRequest, error) {
+	return p.presign(func(c *s3.PresignClient, opt func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		return c.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key}, opt)
+	})
+}
+
+// HeadObject presigns a HEAD of bucket/key.
+func (p *Presigner) HeadObject(ctx context.Context, bucket, key string) (*PresignedRequest, error) {
+	return p.presign(func(c *s3.PresignClient, opt func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		return c.PresignHeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: &key}, opt)
+	})
+}
+
+// PutObject presigns a PUT of bucket/key. The Content-Type the upload sends
+// is stored with the object.
+func (p *Presigner) PutObject(ctx context.Context, bucket, key string) (*PresignedRequest, error) {
+	return p.presign(func(c *s3.PresignClient, opt func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		return c.PresignPutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: &key}, opt)
+	})
+}
+
+// DeleteObject presigns a DELETE of bucket/key.
+func (p *Presigner) DeleteObject(ctx context.Context, bucket, key string) (*PresignedRequest, error) {
+	return p.presign(func(c *s3.PresignClient, opt func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		return c.PresignDeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &bucket, Key: &key}, opt)
+	})
+}
+
+// UploadPart presigns a PUT of one part of the multipart upload uploadID. The
+// response's ETag header is what CompleteMultipartUpload needs for the part.
+func (p *Presigner) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32) (*PresignedRequest, error) {
+	return p.presign(func(c *s3.PresignClient, opt func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		return c.PresignUploadPart(ctx, &s3.UploadPartInput{Bucket: &bucket, Key: &key, UploadId: &uploadID, PartNumber: &partNumber}, opt)
+	})
+}
+
+// CreateMultipartUpload starts a multipart upload of bucket/key whose parts
+// can be uploaded with UploadPart URLs, returning its upload ID. The upload
+// has no checksum algorithm, even when the client has a default one, because
+// presigned parts carry no checksum. Complete it with the ETag each part
+// upload returned.
+func (p *Presigner) CreateMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	out, err := p.Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: &bucket, Key: &key}, acs.UseChecksumAlgorithm(""))
+	if err != nil {
+		return "", fmt.Errorf("create multipart upload error: %w", err)
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+// presign validates Expires and runs one Presign call with it.
+func (p *Presigner) presign(fn func(*s3.PresignClient, func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)) (*PresignedRequest, error) {
+	if p.Expires < time.Second || p.Expires > MaxPresignExpires {
+		return nil, fmt.Errorf("presign error: expiry %s is outside 1s to %s", p.Expires, MaxPresignExpires)
+	}
+	signed := time.Now()
+	req, err := fn(s3.NewPresignClient(p.Client), s3.WithPresignExpires(p.Expires))
+	if err != nil {
+		return nil, fmt.Errorf("presign error: %w", err)
+	}
+	header := req.SignedHeader.Clone()
+	header.Del("Host")
+	return &PresignedRequest{Method: req.Method, URL: req.URL, SignedHeader: header, Expires: signed.Truncate(time.Second).Add(p.Expires)}, nil
+}
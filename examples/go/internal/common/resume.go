@@ -0,0 +1,312 @@
+package common
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// UploadJournal is the saved state of a resumable multipart upload. It pins the
+// local file by size and modification time, so a changed file is never
+// completed with parts of its earlier content.
+type UploadJournal struct {
+	Bucket   string    `json:"bucket"`
+	Key      string    `json:"key"`
+	UploadID string    `json:"upload_id"`
+	File     string    `json:"file"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	PartSize int64     `json:"part_size"`
+	// ChecksumAlgorithm is the upload's checksum algorithm, if any; every part
+	// must be sent with it.
+	ChecksumAlgorithm types.ChecksumAlgorithm `json:"checksum_algorithm,omitempty"`
+	// Parts lists the parts known to be uploaded, in part-number order.
+	Parts []JournalPart `json:"parts"`
+}
+
+// JournalPart is one uploaded part.
+type JournalPart struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+	// Checksum is the part's checksum under the upload's ChecksumAlgorithm.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// LoadUploadJournal reads the journal at path. A missing journal returns nil
+// and no error.
+func LoadUploadJournal(path string) (*UploadJournal, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var j UploadJournal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("upload journal %s: %w", path, err)
+	}
+	return &j, nil
+}
+
+// Save writes the journal atomically with WriteFileAtomic.
+func (j *UploadJournal) Save(path string) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data)
+}
+
+func (j *UploadJournal) add(p JournalPart) {
+	j.Parts = append(j.Parts, p)
+	sort.Slice(j.Parts, func(a, b int) bool { return j.Parts[a].Number < j.Parts[b].Number })
+}
+
+// ResumeUploadFile uploads the file at path like UploadFile, recording the
+// upload ID and every finished part in the journal at journalPath. When the
+// journal describes an unfinished upload of the same, unchanged file to the
+// same bucket and key, the upload continues: ListParts says which parts ACS
+// still has, and only the missing ones are sent. A failed or cancelled upload
+// is left in place, not aborted, for the next call to resume; the journal is
+// removed once the upload completes. Files smaller than one part are sent with
+// PutObject and need no journal.
+func (u *Uploader) ResumeUploadFile(ctx context.Context, path string, in UploadInput, journalPath string) (*UploadResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	in.Body, in.Size = f, info.Size()
+
+	j, err := LoadUploadJournal(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	if j != nil && !j.matches(&in, info, u.ChecksumAlgorithm) {
+		// A different upload, or the file changed: the old parts are useless.
+		_, _ = u.Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: aws.String(j.Bucket), Key: aws.String(j.Key), UploadId: aws.String(j.UploadID)})
+		j = nil
+	}
+	if j != nil {
+		switch err := u.reconcile(ctx, f, j); {
+		case errors.Is(err, errNoSuchUpload):
+			j = nil
+		case err != nil:
+			return nil, err
+		}
+	}
+	if j == nil {
+		partSize, err := u.partSize(in.Size)
+		if err != nil {
+			return nil, err
+		}
+		if in.Size < partSize {
+			return u.Upload(ctx, &in)
+		}
+		create, err := u.Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket:            aws.String(in.Bucket),
+			Key:               aws.String(in.Key),
+			ContentType:       contentType(in.ContentType),
+			Metadata:          in.Metadata,
+			Tagging:           tagging(in.Tagging),
+			ChecksumAlgorithm: u.ChecksumAlgorithm,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create multipart upload error: %w", err)
+		}
+		j = &UploadJournal{
+			Bucket:            in.Bucket,
+			Key:               in.Key,
+			UploadID:          aws.ToString(create.UploadId),
+			File:              path,
+			Size:              info.Size(),
+			ModTime:           info.ModTime(),
+			PartSize:          partSize,
+			Parts:             []JournalPart{},
+			ChecksumAlgorithm: create.ChecksumAlgorithm,
+		}
+		if j.ChecksumAlgorithm == "" {
+			j.ChecksumAlgorithm = u.ChecksumAlgorithm
+		}
+	}
+	if j.ChecksumAlgorithm != u.ChecksumAlgorithm {
+		// Resume with the algorithm the upload was created with.
+		resume := *u
+		resume.ChecksumAlgorithm = j.ChecksumAlgorithm
+		u = &resume
+	}
+	if err := j.Save(journalPath); err != nil {
+		return nil, fmt.Errorf("upload journal error: %w", err)
+	}
+
+	resumed := len(j.Parts)
+	skip := map[int32]bool{}
+	var done int64
+	for _, p := range j.Parts {
+		skip[p.Number] = true
+		done += p.Size
+	}
+	var saveErr error
+	_, _, err = u.uploadParts(ctx, &in, j.UploadID, j.PartSize, done, readFileParts(f, in.Size, j.PartSize, skip),
+		func(p types.CompletedPart, size int64) {
+			alg, sum := partChecksum(p)
+			if j.ChecksumAlgorithm == "" {
+				// Set by the client's default rather than the Uploader.
+				j.ChecksumAlgorithm = alg
+			}
+			j.add(JournalPart{Number: aws.ToInt32(p.PartNumber), ETag: aws.ToString(p.ETag), Size: size, Checksum: sum})
+			if err := j.Save(journalPath); err != nil && saveErr == nil {
+				saveErr = err
+			}
+		})
+	if err == nil && saveErr != nil {
+		err = fmt.Errorf("upload journal error: %w", saveErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w (upload %s kept for resume; journal %s)", err, j.UploadID, journalPath)
+	}
+
+	parts := make([]types.CompletedPart, len(j.Parts))
+	for i, p := range j.Parts {
+		parts[i] = withChecksum(types.CompletedPart{PartNumber: aws.Int32(p.Number), ETag: aws.String(p.ETag)}, j.ChecksumAlgorithm, p.Checksum)
+	}
+	out, err := u.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(in.Bucket),
+		Key:             aws.String(in.Key),
+		UploadId:        aws.String(j.UploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("complete multipart upload error: %w (upload %s kept for resume; journal %s)", err, j.UploadID, journalPath)
+	}
+	_ = os.Remove(journalPath)
+	res := &UploadResult{
+		ETag:      aws.ToString(out.ETag),
+		VersionID: aws.ToString(out.VersionId),
+		UploadID:  j.UploadID,
+		Parts:     len(parts),
+		Resumed:   resumed,
+		Size:      in.Size,
+	}
+	res.ChecksumAlgorithm, res.Checksum = checksumOf(out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumSHA1, out.ChecksumSHA256)
+	return res, nil
+}
+
+// matches reports whether j records an upload of the file described by in and
+// info, with the checksum algorithm alg when one is asked for.
+func (j *UploadJournal) matches(in *UploadInput, info os.FileInfo, alg types.ChecksumAlgorithm) bool {
+	return j.Bucket == in.Bucket && j.Key == in.Key && j.UploadID != "" && j.PartSize > 0 &&
+		j.Size == info.Size() && j.ModTime.Equal(info.ModTime()) &&
+		(alg == "" || alg == j.ChecksumAlgorithm)
+}
+
+// reconcile replaces j.Parts with the parts ACS lists for the upload. A listed
+// part is kept when its ETag matches the journal, or, for parts uploaded after
+// the journal was last saved, when it has the expected size and the MD5 of the
+// local part. It returns errNoSuchUpload when the upload no longer exists.
+func (u *Uploader) reconcile(ctx context.Context, f io.ReaderAt, j *UploadJournal) error {
+	journaled := map[int32]string{}
+	for _, p := range j.Parts {
+		journaled[p.Number] = p.ETag
+	}
+
+	var listed []types.Part
+	pager := s3.NewListPartsPaginator(u.Client, &s3.ListPartsInput{Bucket: aws.String(j.Bucket), Key: aws.String(j.Key), UploadId: aws.String(j.UploadID)})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if hasErrorCode(err, "NoSuchUpload") {
+			return errNoSuchUpload
+		}
+		if err != nil {
+			return fmt.Errorf("list parts error: %w", err)
+		}
+		listed = append(listed, page.Parts...)
+	}
+
+	j.Parts = []JournalPart{}
+	for _, p := range listed {
+		n, etag, size := aws.ToInt32(p.PartNumber), aws.ToString(p.ETag), aws.ToInt64(p.Size)
+		start := int64(n-1) * j.PartSize
+		if n < 1 || start >= j.Size || size != min(j.PartSize, j.Size-start) {
+			continue
+		}
+		if want, ok := journaled[n]; !ok || strings.Trim(want, `"`) != strings.Trim(etag, `"`) {
+			sum, err := partMD5(f, start, size)
+			if err != nil {
+				return err
+			}
+			if sum != strings.Trim(etag, `"`) {
+				continue
+			}
+		}
+		alg, sum := checksumOf(p.ChecksumCRC32, p.ChecksumCRC32C, p.ChecksumSHA1, p.ChecksumSHA256)
+		if j.ChecksumAlgorithm == "" {
+			j.ChecksumAlgorithm = alg
+		}
+		j.add(JournalPart{Number: n, ETag: etag, Size: size, Checksum: sum})
+	}
+	return nil
+}
+
+// errNoSuchUpload reports that the journaled upload is gone (completed or
+// aborted elsewhere).
+var errNoSuchUpload = errors.New("the journaled multipart upload no longer exists")
+
+func partMD5(f io.ReaderAt, start, size int64) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, start, size)); err != nil {
+		return "", fmt.Errorf("read error: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readFileParts returns a partSource that reads the parts of a size-byte file
+// by offset, leaving out the part numbers in skip.
+func readFileParts(f io.ReaderAt, size, partSize int64, skip map[int32]bool) partSource {
+	return func(ctx context.Context, free chan []byte, queue chan<- filePart) (int64, error) {
+		count := (size + partSize - 1) / partSize
+		if count > maxParts {
+			return size, fmt.Errorf("file needs more than %d parts of %d bytes; raise the part size", maxParts, partSize)
+		}
+		for i := range count {
+			number := int32(i + 1)
+			if skip[number] {
+				continue
+			}
+			var buf []byte
+			select {
+			case buf = <-free:
+			case <-ctx.Done():
+				return size, ctx.Err()
+			}
+			n := min(partSize, size-i*partSize)
+			if m, err := f.ReadAt(buf[:n], i*partSize); int64(m) < n {
+				return size, fmt.Errorf("read error: part %d: %w", number, err)
+			}
+			select {
+			case queue <- filePart{number: number, data: buf[:n]}:
+			case <-ctx.Done():
+				return size, ctx.Err()
+			}
+		}
+		return size, nil
+	}
+}
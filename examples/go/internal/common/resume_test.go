@@ -0,0 +1,158 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// TestResumeUploadFile fails part 3 of a four-part upload, then resumes it.
+// Before resuming, part 3 is uploaded behind the journal's back and part 4 with
+// the wrong content: ListParts and the local MD5 must keep the first and
+// replace the second.
+func TestResumeUploadFile(t *testing.T) {
+	fake, client, bucket := newTestBucket(t)
+	ctx := context.Background()
+	data := testData(3*MinPartSize + 100)
+	path, journal := writeTestFile(t, data)
+	uploader := NewUploader(client, func(u *Uploader) {
+		u.PartSize = MinPartSize
+		u.Concurrency = 1
+		u.PartRetries = 0
+	})
+	in := UploadInput{Bucket: bucket, Key: "k"}
+
+	fake.setFail(func(r *http.Request) bool {
+		return r.URL.Query().Get("x-id") == "UploadPart" && r.URL.Query().Get("partNumber") == "3"
+	})
+	if _, err := uploader.ResumeUploadFile(ctx, path, in, journal); err == nil || !strings.Contains(err.Error(), "kept for resume") {
+		t.Fatalf("first attempt: %v, want an error keeping the upload for resume", err)
+	}
+	if n := len(fake.requests("AbortMultipartUpload")); n != 0 {
+		t.Errorf("sent %d AbortMultipartUpload, want the upload kept", n)
+	}
+	j, err := LoadUploadJournal(journal)
+	if err != nil || j == nil {
+		t.Fatalf("journal after the failure: %v, %v", j, err)
+	}
+	if got := journalParts(j); got != "1,2" {
+		t.Fatalf("journal has parts %s, want 1,2", got)
+	}
+
+	fake.setFail(nil)
+	for n, body := range map[int32][]byte{3: data[2*MinPartSize : 3*MinPartSize], 4: testData(100)} {
+		if _, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket: &bucket, Key: aws.String("k"), UploadId: &j.UploadID, PartNumber: aws.Int32(n), Body: bytes.NewReader(body),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fake.reset()
+	res, err := uploader.ResumeUploadFile(ctx, path, in, journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UploadID != j.UploadID || res.Resumed != 3 || res.Parts != 4 {
+		t.Errorf("resumed upload %s with %d of %d parts done, want %s with 3 of 4", res.UploadID, res.Resumed, res.Parts, j.UploadID)
+	}
+	if got := partNumbers(fake.requests("UploadPart")); got != "4" {
+		t.Errorf("resume sent parts %s, want only 4", got)
+	}
+	if n := len(fake.requests("ListParts")); n != 1 {
+		t.Errorf("sent %d ListParts, want 1", n)
+	}
+	if want := `"` + partsETag(data, MinPartSize) + `"`; res.ETag != want {
+		t.Errorf("ETag %s, want %s", res.ETag, want)
+	}
+	if got := getObject(t, client, bucket, "k"); !bytes.Equal(got, data) {
+		t.Errorf("stored %d bytes that differ from the %d uploaded", len(got), len(data))
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal left after the upload completed: %v", err)
+	}
+}
+
+// TestResumeUploadFileChanged checks a journal for a file that changed since is
+// not resumed: its upload is aborted and a new one started.
+func TestResumeUploadFileChanged(t *testing.T) {
+	fake, client, bucket := newTestBucket(t)
+	ctx := context.Background()
+	data := testData(2 * MinPartSize)
+	path, journal := writeTestFile(t, data)
+	uploader := NewUploader(client, func(u *Uploader) {
+		u.PartSize = MinPartSize
+		u.Concurrency = 1
+		u.PartRetries = 0
+	})
+	in := UploadInput{Bucket: bucket, Key: "k"}
+
+	fake.setFail(func(r *http.Request) bool {
+		return r.URL.Query().Get("x-id") == "UploadPart" && r.URL.Query().Get("partNumber") == "2"
+	})
+	if _, err := uploader.ResumeUploadFile(ctx, path, in, journal); err == nil {
+		t.Fatal("first attempt succeeded, want part 2 to fail")
+	}
+	old, err := LoadUploadJournal(journal)
+	if err != nil || old == nil {
+		t.Fatalf("journal after the failure: %v, %v", old, err)
+	}
+
+	data[0]++
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Time{}, old.ModTime.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	fake.setFail(nil)
+	fake.reset()
+	res, err := uploader.ResumeUploadFile(ctx, path, in, journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UploadID == old.UploadID || res.Resumed != 0 {
+		t.Errorf("upload %s resumed %d parts, want a new upload with none resumed", res.UploadID, res.Resumed)
+	}
+	if abort := fake.requests("AbortMultipartUpload"); len(abort) != 1 || abort[0].URL.Query().Get("uploadId") != old.UploadID {
+		t.Errorf("sent %d AbortMultipartUpload, want the old upload %s aborted", len(abort), old.UploadID)
+	}
+	if got := getObject(t, client, bucket, "k"); !bytes.Equal(got, data) {
+		t.Errorf("stored %d bytes that differ from the changed file", len(got))
+	}
+}
+
+func writeTestFile(t *testing.T, data []byte) (path, journal string) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, path + ".acsupload"
+}
+
+func journalParts(j *UploadJournal) string {
+	var ns []string
+	for _, p := range j.Parts {
+		ns = append(ns, strconv.Itoa(int(p.Number)))
+	}
+	return strings.Join(ns, ",")
+}
+
+// partNumbers lists the part numbers of UploadPart requests, in the order sent.
+func partNumbers(rs []*http.Request) string {
+	var ns []string
+	for _, r := range rs {
+		ns = append(ns, r.URL.Query().Get("partNumber"))
+	}
+	return strings.Join(ns, ",")
+}
@@ -0,0 +1,232 @@
+package common
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"s3setup/acs"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Settings are the resolved ACS connection settings. Environment variables
+// override the selected profile, which overrides the built-in defaults.
+type Settings struct {
+	// Profile is the ACS profile in use, or "" when none was loaded.
+	Profile         string
+	Endpoint        string
+	IAMEndpoint     string
+	Region          string
+	IAMRegion       string
+	AddressingStyle string
+	// ChecksumAlgorithm is the default upload checksum (see acs.WithChecksumAlgorithm), or "" for none.
+	ChecksumAlgorithm string
+	// PayloadSigning is how upload bodies are signed (see acs.WithPayloadSigning), or "" for the SDK default.
+	PayloadSigning string
+	// AWSProfile selects a shared-credentials profile for this ACS account, if set.
+	AWSProfile string
+	// EnvPrefix is prepended to every environment variable read, e.g. "SOURCE_"
+	// reads SOURCE_S3_ENDPOINT. It is "" for the usual names.
+	EnvPrefix string
+	// AccessKeyID, SecretAccessKey and SessionToken are static credentials from
+	// <EnvPrefix>AWS_ACCESS_KEY_ID and friends. They are only read with a
+	// prefix; without one the SDK's default chain reads the same variables.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Sources maps each setting name (e.g. "endpoint") to where its value came from.
+	Sources map[string]string
+}
+
+// ConfigFilePath returns the ACS config file location: $ACS_CONFIG_FILE or ~/.acs/config.toml.
+func ConfigFilePath() string {
+	if p := os.Getenv("ACS_CONFIG_FILE"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".acs", "config.toml")
+}
+
+// LoadSettings resolves connection settings from the environment and the profile
+// named by ACS_PROFILE (default "default") in the ACS config file.
+//
+// The config file holds one table per profile, as [name] or [profiles.name]:
+//
+//	[profiles.staging]
+//	endpoint         = "https://staging.example.com"
+//	iam_endpoint     = "https://iam.staging.example.com"
+//	region           = "global"
+//	addressing_style = "path"
+//	aws_profile      = "acs-staging"
+//
+// checksum_algorithm (or S3_CHECKSUM_ALGORITHM) sets the default upload
+// checksum: CRC32, CRC32C, SHA1 or SHA256. payload_signing (or
+// S3_PAYLOAD_SIGNING) sets how upload bodies are signed: signed, unsigned or
+// streaming.
+func LoadSettings() (Settings, error) {
+	return LoadSettingsFor("", "")
+}
+
+// LoadSettingsFor resolves settings like LoadSettings for a second set of
+// connection settings, e.g. the source of a migration. Every environment
+// variable is read with envPrefix prepended (SOURCE_S3_ENDPOINT,
+// SOURCE_ACS_PROFILE, ...). With a prefix, <envPrefix>AWS_ACCESS_KEY_ID,
+// <envPrefix>AWS_SECRET_ACCESS_KEY and <envPrefix>AWS_SESSION_TOKEN give static
+// credentials and <envPrefix>AWS_PROFILE a shared-credentials profile. A
+// non-empty profile overrides <envPrefix>ACS_PROFILE.
+func LoadSettingsFor(envPrefix, profile string) (Settings, error) {
+	path := ConfigFilePath()
+	name, explicit := profile, profile != ""
+	if name == "" {
+		name = Env(envPrefix+"ACS_PROFILE", "default")
+		explicit = os.Getenv(envPrefix+"ACS_PROFILE") != ""
+	}
+	profiles, err := readProfiles(path)
+	if err != nil {
+		return Settings{}, err
+	}
+	values, ok := profiles[name]
+	if !ok && explicit {
+		return Settings{}, fmt.Errorf("ACS profile %q not found in %s", name, path)
+	}
+
+	s := Settings{Sources: map[string]string{}, EnvPrefix: envPrefix}
+	if ok {
+		s.Profile = name
+	}
+	from := func(setting string, envKeys []string, profileKey, def string) string {
+		for _, k := range envKeys {
+			if v := os.Getenv(envPrefix + k); v != "" {
+				s.Sources[setting] = "env " + envPrefix + k
+				return v
+			}
+		}
+		if v := values[profileKey]; v != "" {
+			s.Sources[setting] = fmt.Sprintf("profile %s (%s)", name, path)
+			return v
+		}
+		if def != "" {
+			s.Sources[setting] = "default"
+		}
+		return def
+	}
+
+	s.Endpoint = from("endpoint", []string{"S3_ENDPOINT"}, "endpoint", acs.DefaultEndpoint)
+	s.Region = from("region", []string{"AWS_REGION", "AWS_DEFAULT_REGION", "S3_REGION"}, "region", acs.DefaultRegion)
+	s.AddressingStyle = from("addressing_style", []string{"S3_ADDRESSING_STYLE"}, "addressing_style", string(acs.Virtual))
+	s.ChecksumAlgorithm = from("checksum_algorithm", []string{"S3_CHECKSUM_ALGORITHM"}, "checksum_algorithm", "")
+	s.PayloadSigning = from("payload_signing", []string{"S3_PAYLOAD_SIGNING"}, "payload_signing", "")
+	s.IAMEndpoint = from("iam_endpoint", []string{"IAM_ENDPOINT"}, "iam_endpoint", "")
+	if s.IAMEndpoint == "" {
+		s.IAMEndpoint = s.Endpoint
+		s.Sources["iam_endpoint"] = s.Sources["endpoint"]
+	}
+	s.IAMRegion = from("iam_region", []string{"IAM_REGION"}, "iam_region", "")
+	if s.IAMRegion == "" {
+		s.IAMRegion = s.Region
+		s.Sources["iam_region"] = s.Sources["region"]
+	}
+	if envPrefix == "" {
+		s.AWSProfile = from("aws_profile", nil, "aws_profile", "")
+		return s, nil
+	}
+	s.AWSProfile = from("aws_profile", []string{"AWS_PROFILE"}, "aws_profile", "")
+	s.AccessKeyID = os.Getenv(envPrefix + "AWS_ACCESS_KEY_ID")
+	s.SecretAccessKey = os.Getenv(envPrefix + "AWS_SECRET_ACCESS_KEY")
+	s.SessionToken = os.Getenv(envPrefix + "AWS_SESSION_TOKEN")
+	return s, nil
+}
+
+// Options converts the settings into acs.LoadConfig options.
+func (s Settings) Options() []acs.Option {
+	opts := []acs.Option{
+		acs.WithEndpoint(s.Endpoint),
+		acs.WithIAMEndpoint(s.IAMEndpoint),
+		acs.WithRegion(s.Region),
+		acs.WithIAMRegion(s.IAMRegion),
+		acs.WithAddressingStyle(acs.AddressingStyle(s.AddressingStyle)),
+	}
+	if s.ChecksumAlgorithm != "" {
+		opts = append(opts, acs.WithChecksumAlgorithm(types.ChecksumAlgorithm(strings.ToUpper(s.ChecksumAlgorithm))))
+	}
+	if s.PayloadSigning != "" {
+		opts = append(opts, acs.WithPayloadSigning(acs.PayloadSigning(strings.ToLower(s.PayloadSigning))))
+	}
+	if s.AWSProfile != "" {
+		opts = append(opts, acs.WithSharedConfigProfile(s.AWSProfile))
+	}
+	if s.AccessKeyID != "" {
+		opts = append(opts, acs.WithCredentials(credentials.NewStaticCredentialsProvider(s.AccessKeyID, s.SecretAccessKey, s.SessionToken)))
+	}
+	return opts
+}
+
+// readProfiles parses the subset of TOML the ACS config file uses: tables and
+// string (or bare) values. A missing file yields no profiles.
+func readProfiles(path string) (map[string]map[string]string, error) {
+	profiles := map[string]map[string]string{}
+	if path == "" {
+		return profiles, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var current map[string]string
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name, ok := strings.CutSuffix(line, "]")
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: malformed table header", path, n)
+			}
+			name = strings.TrimSpace(strings.TrimPrefix(name, "["))
+			name = strings.Trim(strings.TrimPrefix(name, "profiles."), `"`)
+			if profiles[name] == nil {
+				profiles[name] = map[string]string{}
+			}
+			current = profiles[name]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			return nil, fmt.Errorf("%s:%d: expected key = value inside a profile table", path, n)
+		}
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			end := strings.LastIndex(value, `"`)
+			if end == 0 {
+				return nil, fmt.Errorf("%s:%d: unterminated string", path, n)
+			}
+			unq, err := strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			value = unq
+		} else if i := strings.Index(value, "#"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		current[strings.TrimSpace(key)] = value
+	}
+	return profiles, sc.Err()
+}
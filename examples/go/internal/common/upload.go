@@ -0,0 +1,401 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// MinPartSize is the smallest part S3 accepts, except for the last part.
+	MinPartSize = 5 * 1024 * 1024
+	// DefaultPartSize is the part size an Uploader uses unless told otherwise.
+	DefaultPartSize = 8 * 1024 * 1024
+	// DefaultConcurrency is how many parts an Uploader sends at once by default.
+	DefaultConcurrency = 4
+	// DefaultPartRetries is how many times a failed part is retried by default.
+	DefaultPartRetries = 3
+	// maxParts is the most parts one multipart upload may have.
+	maxParts = 10000
+)
+
+// Uploader uploads large objects as multipart uploads, sending parts
+// concurrently. Create one with NewUploader.
+type Uploader struct {
+	Client *s3.Client
+	// PartSize is the size of every part but the last. It is raised when a
+	// known size would otherwise need more than 10,000 parts.
+	PartSize int64
+	// Concurrency bounds how many parts are in flight. Memory use is at most
+	// (Concurrency+2) * PartSize.
+	Concurrency int
+	// PartRetries is how many times one part is retried, on top of the SDK's own
+	// retries, before the upload is aborted.
+	PartRetries int
+	// Progress, when set, is called after each part with the bytes uploaded so
+	// far and the total (-1 when unknown). Calls are serialized.
+	Progress func(done, total int64)
+	// ChecksumAlgorithm, when set, has the SDK send a flexible checksum with
+	// every PutObject and part, and the endpoint store it: a full-object
+	// checksum, or for multipart uploads a composite of the part checksums.
+	// When empty, the client's default applies (see acs.WithChecksumAlgorithm).
+	ChecksumAlgorithm types.ChecksumAlgorithm
+}
+
+// NewUploader returns an Uploader with the default part size, concurrency and
+// retries, adjusted by optFns.
+func NewUploader(client *s3.Client, optFns ...func(*Uploader)) *Uploader {
+	u := &Uploader{
+		Client:      client,
+		PartSize:    DefaultPartSize,
+		Concurrency: DefaultConcurrency,
+		PartRetries: DefaultPartRetries,
+	}
+	for _, fn := range optFns {
+		fn(u)
+	}
+	return u
+}
+
+// UploadInput describes one object to upload.
+type UploadInput struct {
+	Bucket string
+	Key    string
+	Body   io.Reader
+	// Size is the length of Body, or -1 when unknown.
+	Size        int64
+	ContentType string
+	Metadata    map[string]string
+	// Tagging is the object's tag set as a URL-encoded query, e.g. "team=data&tier=hot".
+	Tagging string
+}
+
+// UploadResult describes an uploaded object.
+type UploadResult struct {
+	ETag      string
+	VersionID string
+	// UploadID is empty when the object fit in one part and was sent with PutObject.
+	UploadID string
+	Parts    int
+	// Resumed counts the parts an earlier attempt had uploaded (see ResumeUploadFile).
+	Resumed int
+	Size    int64
+	// ChecksumAlgorithm and Checksum are the checksum the endpoint reported for
+	// the object, if any; a composite checksum ends in "-<parts>".
+	ChecksumAlgorithm types.ChecksumAlgorithm
+	Checksum          string
+}
+
+// UploadFile uploads the file at path as described by in, whose Body and Size
+// are taken from the file.
+func (u *Uploader) UploadFile(ctx context.Context, path string, in UploadInput) (*UploadResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	in.Body, in.Size = f, info.Size()
+	return u.Upload(ctx, &in)
+}
+
+// Upload reads in.Body in parts of PartSize and uploads them with up to
+// Concurrency workers, retrying each failed part up to PartRetries times. Parts
+// are completed in part-number order. A body that fits in one part is sent with
+// PutObject instead. When a part fails for good or ctx is cancelled, the
+// multipart upload is aborted and the error returned.
+func (u *Uploader) Upload(ctx context.Context, in *UploadInput) (*UploadResult, error) {
+	if u.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1, got %d", u.Concurrency)
+	}
+	partSize, err := u.partSize(in.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	first := make([]byte, partSize)
+	n, err := io.ReadFull(in.Body, first)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return u.putObject(ctx, in, first[:n])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read error: %w", err)
+	}
+
+	create, err := u.Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(in.Bucket),
+		Key:         aws.String(in.Key),
+		ContentType: contentType(in.ContentType),
+		Metadata:    in.Metadata,
+		Tagging:     tagging(in.Tagging),
+		// The parts must carry the algorithm the upload is created with.
+		ChecksumAlgorithm: u.ChecksumAlgorithm,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create multipart upload error: %w", err)
+	}
+	uploadID := aws.ToString(create.UploadId)
+
+	parts, size, err := u.uploadParts(ctx, in, uploadID, partSize, 0, readParts(in.Body, first), nil)
+	if err != nil {
+		return nil, u.abort(ctx, in, uploadID, err)
+	}
+	done, err := u.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(in.Bucket),
+		Key:             aws.String(in.Key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return nil, u.abort(ctx, in, uploadID, fmt.Errorf("complete multipart upload error: %w", err))
+	}
+	res := &UploadResult{
+		ETag:      aws.ToString(done.ETag),
+		VersionID: aws.ToString(done.VersionId),
+		UploadID:  uploadID,
+		Parts:     len(parts),
+		Size:      size,
+	}
+	res.ChecksumAlgorithm, res.Checksum = checksumOf(done.ChecksumCRC32, done.ChecksumCRC32C, done.ChecksumSHA1, done.ChecksumSHA256)
+	return res, nil
+}
+
+// partSize returns the part size to use for an object of size bytes.
+func (u *Uploader) partSize(size int64) (int64, error) {
+	return PartSizeFor(u.PartSize, size)
+}
+
+// PartSizeFor checks the requested part size ps and returns the part size an
+// Uploader or Copier uses for an object of size bytes: ps, raised to a whole
+// MiB when the object would otherwise need more than 10,000 parts.
+func PartSizeFor(ps, size int64) (int64, error) {
+	if ps < MinPartSize {
+		return 0, fmt.Errorf("part size %d is below the %d-byte minimum", ps, MinPartSize)
+	}
+	if size > 0 && (size+ps-1)/ps > maxParts {
+		// Round up to a whole MiB so the parts stay easy to reason about.
+		ps = ((size+maxParts-1)/maxParts + 1<<20 - 1) &^ (1<<20 - 1)
+	}
+	return ps, nil
+}
+
+func (u *Uploader) putObject(ctx context.Context, in *UploadInput, data []byte) (*UploadResult, error) {
+	out, err := u.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:            aws.String(in.Bucket),
+		Key:               aws.String(in.Key),
+		Body:              bytes.NewReader(data),
+		ContentType:       contentType(in.ContentType),
+		Metadata:          in.Metadata,
+		Tagging:           tagging(in.Tagging),
+		ChecksumAlgorithm: u.ChecksumAlgorithm,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("put object error: %w", err)
+	}
+	if u.Progress != nil {
+		u.Progress(int64(len(data)), int64(len(data)))
+	}
+	res := &UploadResult{
+		ETag:      aws.ToString(out.ETag),
+		VersionID: aws.ToString(out.VersionId),
+		Parts:     1,
+		Size:      int64(len(data)),
+	}
+	res.ChecksumAlgorithm, res.Checksum = checksumOf(out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumSHA1, out.ChecksumSHA256)
+	return res, nil
+}
+
+// filePart is one part read from the body, waiting to be uploaded.
+type filePart struct {
+	number int32
+	data   []byte
+}
+
+// partSource queues the parts to upload, taking a buffer from free before each
+// read, and returns the size of the whole object.
+type partSource func(ctx context.Context, free chan []byte, queue chan<- filePart) (int64, error)
+
+// uploadParts uploads the parts src produces with up to Concurrency workers.
+// done is how many bytes earlier attempts already uploaded, counted in
+// Progress. onPart, when set, is called after each part, serialized with
+// Progress. It returns the parts it uploaded in order and the object size.
+func (u *Uploader) uploadParts(ctx context.Context, in *UploadInput, uploadID string, partSize, done int64, src partSource, onPart func(types.CompletedPart, int64)) ([]types.CompletedPart, int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// free holds the part buffers; taking one before each read bounds memory.
+	// It has room for one more buffer than it starts with: the first part
+	// Upload reads before it knows whether to use multipart.
+	free := make(chan []byte, u.Concurrency+2)
+	for range u.Concurrency + 1 {
+		free <- make([]byte, partSize)
+	}
+	queue := make(chan filePart)
+
+	var (
+		mu        sync.Mutex
+		completed []types.CompletedPart
+		uploaded  = done
+		firstErr  error
+	)
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range u.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				part, err := u.uploadPart(ctx, in, uploadID, p)
+				if err != nil {
+					setErr(err)
+				} else {
+					mu.Lock()
+					completed = append(completed, part)
+					uploaded += int64(len(p.data))
+					if onPart != nil {
+						onPart(part, int64(len(p.data)))
+					}
+					if u.Progress != nil {
+						u.Progress(uploaded, in.Size)
+					}
+					mu.Unlock()
+				}
+				free <- p.data[:cap(p.data)]
+			}
+		}()
+	}
+
+	size, err := src(ctx, free, queue)
+	close(queue)
+	wg.Wait()
+	if err != nil {
+		setErr(err)
+	}
+	if firstErr != nil {
+		return nil, 0, firstErr
+	}
+
+	sort.Slice(completed, func(i, j int) bool {
+		return aws.ToInt32(completed[i].PartNumber) < aws.ToInt32(completed[j].PartNumber)
+	})
+	return completed, size, nil
+}
+
+// readParts returns a partSource that queues first as part 1 and then each
+// further part of body, until body is exhausted.
+func readParts(body io.Reader, first []byte) partSource {
+	return func(ctx context.Context, free chan []byte, queue chan<- filePart) (int64, error) {
+		return readSequential(ctx, body, first, free, queue)
+	}
+}
+
+func readSequential(ctx context.Context, body io.Reader, first []byte, free chan []byte, queue chan<- filePart) (int64, error) {
+	size := int64(len(first))
+	next := filePart{number: 1, data: first}
+	for {
+		select {
+		case queue <- next:
+		case <-ctx.Done():
+			return size, ctx.Err()
+		}
+
+		var buf []byte
+		select {
+		case buf = <-free:
+		case <-ctx.Done():
+			return size, ctx.Err()
+		}
+		n, err := io.ReadFull(body, buf)
+		if n == 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+			return size, nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return size, fmt.Errorf("read error: %w", err)
+		}
+		if next.number == maxParts {
+			return size, fmt.Errorf("body needs more than %d parts of %d bytes; raise the part size", maxParts, len(buf))
+		}
+		size += int64(n)
+		next = filePart{number: next.number + 1, data: buf[:n]}
+	}
+}
+
+// uploadPart sends one part, retrying up to PartRetries times with a growing
+// pause, and returns its ETag and checksum.
+func (u *Uploader) uploadPart(ctx context.Context, in *UploadInput, uploadID string, p filePart) (types.CompletedPart, error) {
+	var err error
+	for attempt := 0; attempt <= u.PartRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
+			case <-ctx.Done():
+				return types.CompletedPart{}, ctx.Err()
+			}
+		}
+		var out *s3.UploadPartOutput
+		out, err = u.Client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:            aws.String(in.Bucket),
+			Key:               aws.String(in.Key),
+			UploadId:          aws.String(uploadID),
+			PartNumber:        aws.Int32(p.number),
+			Body:              bytes.NewReader(p.data),
+			ChecksumAlgorithm: u.ChecksumAlgorithm,
+		})
+		if err == nil {
+			return CompletedPart(p.number, out), nil
+		}
+		if ctx.Err() != nil {
+			return types.CompletedPart{}, ctx.Err()
+		}
+	}
+	return types.CompletedPart{}, fmt.Errorf("upload part %d error (%d attempts): %w", p.number, u.PartRetries+1, err)
+}
+
+// abort aborts the multipart upload after cause, even when ctx is cancelled,
+// and returns cause joined with any abort failure.
+func (u *Uploader) abort(ctx context.Context, in *UploadInput, uploadID string, cause error) error {
+	_, err := u.Client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(in.Bucket),
+		Key:      aws.String(in.Key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return errors.Join(cause, fmt.Errorf("abort multipart upload %s error: %w", uploadID, err))
+	}
+	return cause
+}
+
+func tagging(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+func contentType(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
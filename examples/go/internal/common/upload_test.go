@@ -0,0 +1,286 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"s3setup/internal/fakes3"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestUploadSplitsParts(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		size     int
+		unknown  bool
+		parts    int
+		lastPart int
+	}{
+		{name: "empty", size: 0, parts: 1},
+		{name: "below one part", size: MinPartSize - 1, parts: 1},
+		{name: "exactly one part", size: MinPartSize, parts: 1, lastPart: MinPartSize},
+		{name: "two parts and a byte", size: 2*MinPartSize + 1, parts: 3, lastPart: 1},
+		{name: "unknown size", size: 2*MinPartSize + 1, unknown: true, parts: 3, lastPart: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, client, bucket := newTestBucket(t)
+			data := testData(tc.size)
+			var body io.Reader = bytes.NewReader(data)
+			size := int64(len(data))
+			if tc.unknown {
+				body, size = io.MultiReader(body), -1
+			}
+			var last, total int64
+			uploader := NewUploader(client, func(u *Uploader) {
+				u.PartSize = MinPartSize
+				u.Concurrency = 3
+				u.Progress = func(done, t int64) { last, total = done, t }
+			})
+			res, err := uploader.Upload(context.Background(), &UploadInput{Bucket: bucket, Key: "k", Body: body, Size: size})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Parts != tc.parts || res.Size != int64(len(data)) {
+				t.Errorf("uploaded %d bytes in %d parts, want %d bytes in %d", res.Size, res.Parts, len(data), tc.parts)
+			}
+			// A single PutObject reports its own length; parts report the given size.
+			wantTotal := size
+			if tc.lastPart == 0 {
+				wantTotal = int64(len(data))
+			}
+			if last != int64(len(data)) || total != wantTotal {
+				t.Errorf("last progress %d of %d, want %d of %d", last, total, len(data), wantTotal)
+			}
+
+			sent := fake.requests("UploadPart")
+			if tc.lastPart == 0 {
+				// Smaller than a part: one PutObject, no multipart upload.
+				if res.UploadID != "" || len(sent) != 0 || len(fake.requests("PutObject")) != 1 {
+					t.Errorf("upload %q sent %d parts, want a single PutObject", res.UploadID, len(sent))
+				}
+				if want := `"` + md5Hex(data) + `"`; res.ETag != want {
+					t.Errorf("ETag %s, want %s", res.ETag, want)
+				}
+			} else {
+				if res.UploadID == "" || len(sent) != tc.parts {
+					t.Fatalf("upload %q sent %d parts, want %d", res.UploadID, len(sent), tc.parts)
+				}
+				for _, r := range sent {
+					want := int64(MinPartSize)
+					if r.URL.Query().Get("partNumber") == fmt.Sprint(tc.parts) {
+						want = int64(tc.lastPart)
+					}
+					if r.ContentLength != want {
+						t.Errorf("part %s is %d bytes, want %d", r.URL.Query().Get("partNumber"), r.ContentLength, want)
+					}
+				}
+				if want := `"` + partsETag(data, MinPartSize) + `"`; res.ETag != want {
+					t.Errorf("ETag %s, want %s", res.ETag, want)
+				}
+			}
+			if got := getObject(t, client, bucket, "k"); !bytes.Equal(got, data) {
+				t.Errorf("stored %d bytes that differ from the %d uploaded", len(got), len(data))
+			}
+		})
+	}
+}
+
+func TestUploadAbortsFailedPart(t *testing.T) {
+	fake, client, bucket := newTestBucket(t)
+	fake.setFail(func(r *http.Request) bool {
+		return r.URL.Query().Get("x-id") == "UploadPart" && r.URL.Query().Get("partNumber") == "2"
+	})
+	uploader := NewUploader(client, func(u *Uploader) {
+		u.PartSize = MinPartSize
+		u.PartRetries = 1
+	})
+	data := testData(3 * MinPartSize)
+	_, err := uploader.Upload(context.Background(), &UploadInput{Bucket: bucket, Key: "k", Body: bytes.NewReader(data), Size: int64(len(data))})
+	if err == nil || !strings.Contains(err.Error(), "upload part 2 error (2 attempts)") {
+		t.Fatalf("upload: %v, want a part 2 error after 2 attempts", err)
+	}
+	if n := len(fake.requests("AbortMultipartUpload")); n != 1 {
+		t.Errorf("sent %d AbortMultipartUpload, want 1", n)
+	}
+	out, err := client.ListMultipartUploads(context.Background(), &s3.ListMultipartUploadsInput{Bucket: &bucket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Uploads) != 0 {
+		t.Errorf("%d multipart uploads left after the failure, want 0", len(out.Uploads))
+	}
+}
+
+func TestUploadSendsChecksums(t *testing.T) {
+	fake, client, bucket := newTestBucket(t)
+	uploader := NewUploader(client, func(u *Uploader) {
+		u.PartSize = MinPartSize
+		u.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	})
+	data := testData(MinPartSize + 10)
+	res, err := uploader.Upload(context.Background(), &UploadInput{Bucket: bucket, Key: "k", Body: bytes.NewReader(data), Size: int64(len(data))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ChecksumAlgorithm != types.ChecksumAlgorithmSha256 || !strings.HasSuffix(res.Checksum, "-2") {
+		t.Errorf("checksum %s %q, want a composite SHA256 of 2 parts", res.ChecksumAlgorithm, res.Checksum)
+	}
+	for _, r := range fake.requests("UploadPart") {
+		if r.Header.Get("X-Amz-Checksum-Sha256") == "" && r.Header.Get("X-Amz-Trailer") != "x-amz-checksum-sha256" {
+			t.Errorf("part %s sent without a SHA256 checksum", r.URL.Query().Get("partNumber"))
+		}
+	}
+}
+
+func TestPartSizeFor(t *testing.T) {
+	if _, err := PartSizeFor(MinPartSize-1, 0); err == nil {
+		t.Error("part size below the minimum accepted")
+	}
+	for _, tc := range []struct{ ps, size, want int64 }{
+		{MinPartSize, 0, MinPartSize},
+		{MinPartSize, -1, MinPartSize},
+		{MinPartSize, maxParts * MinPartSize, MinPartSize},
+		{MinPartSize, maxParts*MinPartSize + 1, 6 << 20},
+		{DefaultPartSize, 1 << 40, 105 << 20},
+	} {
+		got, err := PartSizeFor(tc.ps, tc.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("PartSizeFor(%d, %d) = %d, want %d", tc.ps, tc.size, got, tc.want)
+		}
+		if tc.size > 0 && (tc.size+got-1)/got > maxParts {
+			t.Errorf("PartSizeFor(%d, %d) = %d needs more than %d parts", tc.ps, tc.size, got, maxParts)
+		}
+	}
+}
+
+// fakeS3 serves the fake S3 handler, recording every request and failing those
+// fail reports true for with a 500.
+type fakeS3 struct {
+	next http.Handler
+
+	mu   sync.Mutex
+	sent []*http.Request
+	fail func(*http.Request) bool
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.sent = append(f.sent, r)
+	fail := f.fail != nil && f.fail(r)
+	f.mu.Unlock()
+	if fail {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	f.next.ServeHTTP(w, r)
+}
+
+// requests returns the requests sent for the operation op (the SDK's x-id
+// query parameter), in order.
+func (f *fakeS3) requests(op string) []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var rs []*http.Request
+	for _, r := range f.sent {
+		if r.URL.Query().Get("x-id") == op {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+func (f *fakeS3) setFail(fn func(*http.Request) bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = fn
+}
+
+// reset forgets the requests sent so far.
+func (f *fakeS3) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = nil
+}
+
+// newTestBucket starts the fake S3 server and returns it, a client for it that
+// retries without pausing, and a new bucket.
+func newTestBucket(t *testing.T) (*fakeS3, *s3.Client, string) {
+	t.Helper()
+	fake := &fakeS3{next: fakes3.NewHandler()}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(srv.URL),
+		UsePathStyle: true,
+		Retryer: retry.NewStandard(func(o *retry.StandardOptions) {
+			o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+		}),
+	})
+	bucket := "common-test"
+	if _, err := client.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: &bucket}); err != nil {
+		t.Fatal(err)
+	}
+	fake.reset()
+	return fake, client, bucket
+}
+
+func getObject(t *testing.T, client *s3.Client, bucket, key string) []byte {
+	t.Helper()
+	out, err := client.GetObject(context.Background(), &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ReadAll(out.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testData returns n bytes that differ from part to part, so parts sent out of
+// place do not go unnoticed.
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7 / 1021)
+	}
+	return data
+}
+
+func md5Hex(b []byte) string {
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// partsETag returns the ETag S3 gives data uploaded in parts of partSize.
+func partsETag(data []byte, partSize int) string {
+	h := md5.New()
+	n := 0
+	for off := 0; off < len(data); off += partSize {
+		sum := md5.Sum(data[off:min(off+partSize, len(data))])
+		h.Write(sum[:])
+		n++
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), n)
+}
@@ -0,0 +1,180 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"s3setup/acs"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// Problem is one misconfiguration found while loading the connection settings.
+type Problem struct {
+	Message string
+	Hint    string
+}
+
+// ConfigError collects every problem found in the connection settings, so a
+// bad setup is reported up front instead of as an opaque HTTP error later.
+type ConfigError struct {
+	Problems []Problem
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid ACS configuration (%d problem", len(e.Problems))
+	if len(e.Problems) != 1 {
+		b.WriteString("s")
+	}
+	b.WriteString("):")
+	for _, p := range e.Problems {
+		fmt.Fprintf(&b, "\n  - %s", p.Message)
+		if p.Hint != "" {
+			fmt.Fprintf(&b, "\n    hint: %s", p.Hint)
+		}
+	}
+	return b.String()
+}
+
+// credentialTimeout bounds the credential lookup, which may probe instance metadata.
+const credentialTimeout = 10 * time.Second
+
+// validateSettings checks the settings and the credential chain, returning the
+// loaded config or a *ConfigError listing everything that is wrong.
+func validateSettings(ctx context.Context, s Settings) (acs.Config, error) {
+	var problems []Problem
+	verr := acs.Validate(s.Options()...)
+	problems = append(problems, settingProblems(s, verr)...)
+
+	// With invalid settings, load defaults so the credential chain is still checked.
+	opts := s.Options()
+	if verr != nil {
+		opts = nil
+		if s.AWSProfile != "" {
+			opts = append(opts, acs.WithSharedConfigProfile(s.AWSProfile))
+		}
+		if s.AccessKeyID != "" {
+			opts = append(opts, acs.WithCredentials(credentials.NewStaticCredentialsProvider(s.AccessKeyID, s.SecretAccessKey, s.SessionToken)))
+		}
+	}
+	if (s.AccessKeyID == "") != (s.SecretAccessKey == "") {
+		problems = append(problems, Problem{
+			Message: fmt.Sprintf("only one of %sAWS_ACCESS_KEY_ID and %sAWS_SECRET_ACCESS_KEY is set", s.EnvPrefix, s.EnvPrefix),
+			Hint:    "set both, or neither to use the default credential chain",
+		})
+	}
+	cfg, err := acs.LoadConfig(ctx, opts...)
+	if err != nil {
+		problems = append(problems, Problem{
+			Message: fmt.Sprintf("cannot load AWS config: %v", err),
+			Hint:    "check ~/.aws/config and ~/.aws/credentials, and the aws_profile of your ACS profile",
+		})
+	} else {
+		cctx, cancel := context.WithTimeout(ctx, credentialTimeout)
+		defer cancel()
+		if err := cfg.CheckCredentials(cctx); err != nil {
+			hint := "set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, add them to ~/.aws/credentials, or set aws_profile in your ACS profile"
+			if s.EnvPrefix != "" {
+				hint = fmt.Sprintf("set %[1]sAWS_ACCESS_KEY_ID and %[1]sAWS_SECRET_ACCESS_KEY, set %[1]sAWS_PROFILE, or set aws_profile in your ACS profile", s.EnvPrefix)
+			}
+			problems = append(problems, Problem{
+				Message: "no credentials in default chain",
+				Hint:    hint,
+			})
+		}
+	}
+
+	if len(problems) > 0 {
+		return acs.Config{}, &ConfigError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// settingProblems turns acs validation errors into problems that name the env
+// var or profile entry the bad value came from.
+func settingProblems(s Settings, err error) []Problem {
+	if err == nil {
+		return nil
+	}
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else {
+		errs = []error{err}
+	}
+
+	var problems []Problem
+	for _, e := range errs {
+		var endpointErr *acs.InvalidEndpointError
+		var styleErr *acs.InvalidAddressingStyleError
+		var checksumErr *acs.InvalidChecksumAlgorithmError
+		var signingErr *acs.InvalidPayloadSigningError
+		switch {
+		case errors.As(e, &endpointErr):
+			envKey := "S3_ENDPOINT"
+			if endpointErr.Option == "iam_endpoint" {
+				envKey = "IAM_ENDPOINT"
+			}
+			problems = append(problems, Problem{
+				Message: fmt.Sprintf("%s %s (%q)", settingLabel(s, endpointErr.Option, envKey), endpointErr.Reason, endpointErr.Endpoint),
+				Hint:    "use an absolute URL with scheme and host, e.g. " + acs.DefaultEndpoint,
+			})
+		case errors.As(e, &styleErr):
+			problems = append(problems, Problem{
+				Message: fmt.Sprintf("%s has invalid value %q", settingLabel(s, "addressing_style", "S3_ADDRESSING_STYLE"), styleErr.Value),
+				Hint:    "use one of virtual, path or auto (lowercase)",
+			})
+		case errors.As(e, &checksumErr):
+			problems = append(problems, Problem{
+				Message: fmt.Sprintf("%s has invalid value %q", settingLabel(s, "checksum_algorithm", "S3_CHECKSUM_ALGORITHM"), s.ChecksumAlgorithm),
+				Hint:    "use one of CRC32, CRC32C, SHA1 or SHA256, or leave it unset",
+			})
+		case errors.As(e, &signingErr):
+			problems = append(problems, Problem{
+				Message: fmt.Sprintf("%s has invalid value %q", settingLabel(s, "payload_signing", "S3_PAYLOAD_SIGNING"), s.PayloadSigning),
+				Hint:    "use one of signed, unsigned or streaming, or leave it unset for the SDK default",
+			})
+		case errors.Is(e, acs.ErrMissingRegion):
+			problems = append(problems, Problem{
+				Message: settingLabel(s, "region", "AWS_REGION") + " is empty",
+				Hint:    fmt.Sprintf("set %sAWS_REGION; ACS uses %q", s.EnvPrefix, acs.DefaultRegion),
+			})
+		default:
+			problems = append(problems, Problem{Message: e.Error()})
+		}
+	}
+	return problems
+}
+
+// settingLabel names a setting by where it came from: the env var or profile
+// entry that set it, or envKey when the built-in default was used.
+func settingLabel(s Settings, setting, envKey string) string {
+	src := s.Sources[setting]
+	switch {
+	case strings.HasPrefix(src, "env "):
+		return strings.TrimPrefix(src, "env ")
+	case strings.HasPrefix(src, "profile "):
+		return fmt.Sprintf("%s in %s", setting, src)
+	default:
+		return s.EnvPrefix + envKey
+	}
+}
+
+// profileProblem explains a LoadSettings failure, listing the profiles that do exist.
+func profileProblem(err error) *ConfigError {
+	hint := "check ACS_PROFILE and " + ConfigFilePath()
+	if profiles, perr := readProfiles(ConfigFilePath()); perr == nil && len(profiles) > 0 {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		hint = "available profiles: " + strings.Join(names, ", ")
+	}
+	return &ConfigError{Problems: []Problem{{Message: err.Error(), Hint: hint}}}
+}
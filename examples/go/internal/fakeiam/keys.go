@@ -0,0 +1,145 @@
+package fakeiam
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type accessKeyXML struct {
+	UserName        string `xml:"UserName"`
+	AccessKeyID     string `xml:"AccessKeyId"`
+	Status          string `xml:"Status"`
+	SecretAccessKey string `xml:"SecretAccessKey,omitempty"`
+	CreateDate      string `xml:"CreateDate"`
+}
+
+type createAccessKeyResponse struct {
+	XMLName          xml.Name         `xml:"CreateAccessKeyResponse"`
+	Xmlns            string           `xml:"xmlns,attr"`
+	AccessKey        accessKeyXML     `xml:"CreateAccessKeyResult>AccessKey"`
+	ResponseMetadata responseMetadata `xml:"ResponseMetadata"`
+}
+
+type listAccessKeysResponse struct {
+	XMLName          xml.Name         `xml:"ListAccessKeysResponse"`
+	Xmlns            string           `xml:"xmlns,attr"`
+	Result           listKeysResult   `xml:"ListAccessKeysResult"`
+	ResponseMetadata responseMetadata `xml:"ResponseMetadata"`
+}
+
+type listKeysResult struct {
+	AccessKeyMetadata []accessKeyXML `xml:"AccessKeyMetadata>member"`
+	IsTruncated       bool           `xml:"IsTruncated"`
+}
+
+// emptyResponse is the body for actions that return no result element.
+type emptyResponse struct {
+	XMLName          xml.Name
+	Xmlns            string           `xml:"xmlns,attr"`
+	ResponseMetadata responseMetadata `xml:"ResponseMetadata"`
+}
+
+func writeEmpty(w http.ResponseWriter, action string) {
+	writeXML(w, http.StatusOK, emptyResponse{
+		XMLName:          xml.Name{Local: action + "Response"},
+		Xmlns:            xmlns,
+		ResponseMetadata: responseMetadata{RequestID: "fakeiam"},
+	})
+}
+
+func (h *Handler) createAccessKey(w http.ResponseWriter, r *http.Request) {
+	owner := r.Form.Get("UserName")
+	if owner == "" {
+		owner = callerKeyID(r)
+	}
+	secret := make([]byte, 30)
+	_, _ = rand.Read(secret)
+	k := &accessKey{
+		id:      randomID("AKIA", 16),
+		secret:  base64.StdEncoding.EncodeToString(secret),
+		owner:   owner,
+		status:  "Active",
+		created: time.Now(),
+	}
+	h.keys[k.id] = k
+	writeXML(w, http.StatusOK, createAccessKeyResponse{
+		Xmlns: xmlns,
+		AccessKey: accessKeyXML{
+			UserName:        k.id,
+			AccessKeyID:     k.id,
+			Status:          k.status,
+			SecretAccessKey: k.secret,
+			CreateDate:      isoTime(k.created),
+		},
+		ResponseMetadata: responseMetadata{RequestID: "fakeiam"},
+	})
+}
+
+func (h *Handler) listAccessKeys(w http.ResponseWriter, r *http.Request) {
+	user := r.Form.Get("UserName")
+	res := listAccessKeysResponse{Xmlns: xmlns, ResponseMetadata: responseMetadata{RequestID: "fakeiam"}}
+	for _, k := range h.keys {
+		if user != "" && user != k.id && user != k.owner {
+			continue
+		}
+		res.Result.AccessKeyMetadata = append(res.Result.AccessKeyMetadata, accessKeyXML{
+			UserName:    k.id,
+			AccessKeyID: k.id,
+			Status:      k.status,
+			CreateDate:  isoTime(k.created),
+		})
+	}
+	sort.Slice(res.Result.AccessKeyMetadata, func(i, j int) bool {
+		return res.Result.AccessKeyMetadata[i].CreateDate < res.Result.AccessKeyMetadata[j].CreateDate
+	})
+	writeXML(w, http.StatusOK, res)
+}
+
+func (h *Handler) updateAccessKey(w http.ResponseWriter, r *http.Request) {
+	k, ok := h.keys[r.Form.Get("AccessKeyId")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchEntity", "The Access Key with id "+r.Form.Get("AccessKeyId")+" cannot be found.")
+		return
+	}
+	switch status := r.Form.Get("Status"); status {
+	case "Active", "Inactive":
+		k.status = status
+	default:
+		writeError(w, http.StatusBadRequest, "ValidationError", "Status must be Active or Inactive")
+		return
+	}
+	writeEmpty(w, "UpdateAccessKey")
+}
+
+func (h *Handler) deleteAccessKey(w http.ResponseWriter, r *http.Request) {
+	id := r.Form.Get("AccessKeyId")
+	if _, ok := h.keys[id]; !ok {
+		writeError(w, http.StatusNotFound, "NoSuchEntity", "The Access Key with id "+id+" cannot be found.")
+		return
+	}
+	delete(h.keys, id)
+	delete(h.attachments, id)
+	h.deleted[id] = true
+	writeEmpty(w, "DeleteAccessKey")
+}
+
+// SecretKeys returns a fakes3 Handler.SecretKey that verifies requests signed
+// with an access key created through h using its secret, and rejects the key
+// once inactive or deleted. Access key IDs h never created have the secret def.
+func (h *Handler) SecretKeys(def string) func(id string) (string, bool) {
+	return func(id string) (string, bool) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if k, ok := h.keys[id]; ok {
+			return k.secret, k.status == "Active"
+		}
+		if h.deleted[id] {
+			return "", false
+		}
+		return def, true
+	}
+}
@@ -0,0 +1,52 @@
+package fakeiam
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// TestSecretKeys follows a key from creation to deletion: only IDs the handler
+// never created may fall back to the default secret.
+func TestSecretKeys(t *testing.T) {
+	h := NewHandler()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	client := iam.New(iam.Options{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(srv.URL),
+	})
+	ctx := context.Background()
+	secretKey := h.SecretKeys("default")
+
+	created, err := client.CreateAccessKey(ctx, &iam.CreateAccessKeyInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.AccessKey.AccessKeyId
+	check := func(state, id, want string, wantOK bool) {
+		t.Helper()
+		if secret, ok := secretKey(id); secret != want || ok != wantOK {
+			t.Errorf("%s key: secret %q, %v, want %q, %v", state, secret, ok, want, wantOK)
+		}
+	}
+	check("unknown", "AKIAUNKNOWN", "default", true)
+	check("active", *id, aws.ToString(created.AccessKey.SecretAccessKey), true)
+
+	if _, err := client.UpdateAccessKey(ctx, &iam.UpdateAccessKeyInput{AccessKeyId: id, Status: iamtypes.StatusTypeInactive}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := secretKey(*id); ok {
+		t.Error("inactive key accepted")
+	}
+	if _, err := client.DeleteAccessKey(ctx, &iam.DeleteAccessKeyInput{AccessKeyId: id}); err != nil {
+		t.Fatal(err)
+	}
+	check("deleted", *id, "", false)
+}
@@ -0,0 +1,217 @@
+package fakeiam
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type policyXML struct {
+	PolicyName       string `xml:"PolicyName"`
+	PolicyID         string `xml:"PolicyId"`
+	Arn              string `xml:"Arn"`
+	Path             string `xml:"Path"`
+	DefaultVersionID string `xml:"DefaultVersionId"`
+	AttachmentCount  int    `xml:"AttachmentCount"`
+	IsAttachable     bool   `xml:"IsAttachable"`
+	Description      string `xml:"Description,omitempty"`
+	CreateDate       string `xml:"CreateDate"`
+	UpdateDate       string `xml:"UpdateDate"`
+}
+
+type createPolicyResponse struct {
+	XMLName          xml.Name         `xml:"CreatePolicyResponse"`
+	Xmlns            string           `xml:"xmlns,attr"`
+	Policy           policyXML        `xml:"CreatePolicyResult>Policy"`
+	ResponseMetadata responseMetadata `xml:"ResponseMetadata"`
+}
+
+type listPoliciesResponse struct {
+	XMLName          xml.Name         `xml:"ListPoliciesResponse"`
+	Xmlns            string           `xml:"xmlns,attr"`
+	Result           policiesResult   `xml:"ListPoliciesResult"`
+	ResponseMetadata responseMetadata `xml:"ResponseMetadata"`
+}
+
+type policiesResult struct {
+	Policies    []policyXML `xml:"Policies>member"`
+	IsTruncated bool        `xml:"IsTruncated"`
+}
+
+type attachedPolicyXML struct {
+	PolicyName string `xml:"PolicyName"`
+	PolicyArn  string `xml:"PolicyArn"`
+}
+
+type listAttachedUserPoliciesResponse struct {
+	XMLName          xml.Name         `xml:"ListAttachedUserPoliciesResponse"`
+	Xmlns            string           `xml:"xmlns,attr"`
+	Result           attachedResult   `xml:"ListAttachedUserPoliciesResult"`
+	ResponseMetadata responseMetadata `xml:"ResponseMetadata"`
+}
+
+type attachedResult struct {
+	AttachedPolicies []attachedPolicyXML `xml:"AttachedPolicies>member"`
+	IsTruncated      bool                `xml:"IsTruncated"`
+}
+
+func (h *Handler) attachmentCount(arn string) int {
+	n := 0
+	for _, set := range h.attachments {
+		if set[arn] {
+			n++
+		}
+	}
+	return n
+}
+
+func (h *Handler) policyXML(p *policy) policyXML {
+	return policyXML{
+		PolicyName:       p.name,
+		PolicyID:         p.id,
+		Arn:              p.arn,
+		Path:             p.path,
+		DefaultVersionID: "v1",
+		AttachmentCount:  h.attachmentCount(p.arn),
+		IsAttachable:     true,
+		Description:      p.description,
+		CreateDate:       isoTime(p.created),
+		UpdateDate:       isoTime(p.created),
+	}
+}
+
+func (h *Handler) createPolicy(w http.ResponseWriter, r *http.Request) {
+	name := r.Form.Get("PolicyName")
+	doc := r.Form.Get("PolicyDocument")
+	if name == "" || doc == "" {
+		writeError(w, http.StatusBadRequest, "ValidationError", "PolicyName and PolicyDocument are required")
+		return
+	}
+	if !json.Valid([]byte(doc)) {
+		writeError(w, http.StatusBadRequest, "MalformedPolicyDocument", "Syntax errors in policy.")
+		return
+	}
+	path := r.Form.Get("Path")
+	if path == "" {
+		path = "/"
+	}
+	arn := "arn:aws:iam::000000000000:policy" + path + name
+	if _, ok := h.policies[arn]; ok {
+		writeError(w, http.StatusConflict, "EntityAlreadyExists", "A policy called "+name+" already exists.")
+		return
+	}
+	p := &policy{
+		name:        name,
+		id:          randomID("ANPA", 17),
+		arn:         arn,
+		path:        path,
+		document:    doc,
+		description: r.Form.Get("Description"),
+		created:     time.Now(),
+	}
+	h.policies[arn] = p
+	writeXML(w, http.StatusOK, createPolicyResponse{
+		Xmlns:            xmlns,
+		Policy:           h.policyXML(p),
+		ResponseMetadata: responseMetadata{RequestID: "fakeiam"},
+	})
+}
+
+func (h *Handler) deletePolicy(w http.ResponseWriter, r *http.Request) {
+	arn := r.Form.Get("PolicyArn")
+	if _, ok := h.policies[arn]; !ok {
+		writeError(w, http.StatusNotFound, "NoSuchEntity", "Policy "+arn+" was not found.")
+		return
+	}
+	if h.attachmentCount(arn) > 0 {
+		writeError(w, http.StatusConflict, "DeleteConflict", "Cannot delete a policy attached to entities.")
+		return
+	}
+	delete(h.policies, arn)
+	writeEmpty(w, "DeletePolicy")
+}
+
+// userKey resolves the UserName parameter, which ACS expects to be an access key ID.
+func (h *Handler) userKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user := r.Form.Get("UserName")
+	if _, ok := h.keys[user]; !ok {
+		writeError(w, http.StatusNotFound, "NoSuchEntity", "The user with name "+user+" cannot be found.")
+		return "", false
+	}
+	return user, true
+}
+
+func (h *Handler) attachUserPolicy(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userKey(w, r)
+	if !ok {
+		return
+	}
+	arn := r.Form.Get("PolicyArn")
+	if _, ok := h.policies[arn]; !ok {
+		writeError(w, http.StatusNotFound, "NoSuchEntity", "Policy "+arn+" does not exist or is not attachable.")
+		return
+	}
+	if h.attachments[user] == nil {
+		h.attachments[user] = map[string]bool{}
+	}
+	h.attachments[user][arn] = true
+	writeEmpty(w, "AttachUserPolicy")
+}
+
+func (h *Handler) detachUserPolicy(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userKey(w, r)
+	if !ok {
+		return
+	}
+	arn := r.Form.Get("PolicyArn")
+	if !h.attachments[user][arn] {
+		writeError(w, http.StatusNotFound, "NoSuchEntity", "Policy "+arn+" was not found.")
+		return
+	}
+	delete(h.attachments[user], arn)
+	writeEmpty(w, "DetachUserPolicy")
+}
+
+func (h *Handler) listAttachedUserPolicies(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userKey(w, r)
+	if !ok {
+		return
+	}
+	res := listAttachedUserPoliciesResponse{Xmlns: xmlns, ResponseMetadata: responseMetadata{RequestID: "fakeiam"}}
+	for arn := range h.attachments[user] {
+		res.Result.AttachedPolicies = append(res.Result.AttachedPolicies, attachedPolicyXML{
+			PolicyName: h.policies[arn].name,
+			PolicyArn:  arn,
+		})
+	}
+	sort.Slice(res.Result.AttachedPolicies, func(i, j int) bool {
+		return res.Result.AttachedPolicies[i].PolicyArn < res.Result.AttachedPolicies[j].PolicyArn
+	})
+	writeXML(w, http.StatusOK, res)
+}
+
+// listPolicies lists customer managed policies. The fake holds no AWS managed
+// policies, so Scope=AWS lists nothing; PathPrefix and OnlyAttached filter as in IAM.
+func (h *Handler) listPolicies(w http.ResponseWriter, r *http.Request) {
+	res := listPoliciesResponse{Xmlns: xmlns, ResponseMetadata: responseMetadata{RequestID: "fakeiam"}}
+	if r.Form.Get("Scope") == "AWS" {
+		writeXML(w, http.StatusOK, res)
+		return
+	}
+	prefix := r.Form.Get("PathPrefix")
+	onlyAttached := r.Form.Get("OnlyAttached") == "true"
+	for _, p := range h.policies {
+		x := h.policyXML(p)
+		if !strings.HasPrefix(p.path, prefix) || (onlyAttached && x.AttachmentCount == 0) {
+			continue
+		}
+		res.Result.Policies = append(res.Result.Policies, x)
+	}
+	sort.Slice(res.Result.Policies, func(i, j int) bool {
+		return res.Result.Policies[i].Arn < res.Result.Policies[j].Arn
+	})
+	writeXML(w, http.StatusOK, res)
+}
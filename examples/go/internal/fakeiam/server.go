@@ -0,0 +1,163 @@
+// Package fakeiam is an in-memory IAM Query-protocol server covering the
+// access-key and managed-policy calls used by the iam_examples program.
+//
+// It mirrors the ACS convention that an access key ID doubles as the IAM
+// UserName: policies are attached to, listed for and detached from the key ID.
+package fakeiam
+
+import (
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const xmlns = "https://iam.amazonaws.com/doc/2010-05-08/"
+
+// Handler serves the IAM Query API from memory. The zero value is not usable; use NewHandler.
+type Handler struct {
+	mu          sync.Mutex
+	keys        map[string]*accessKey
+	deleted     map[string]bool // IDs of access keys since deleted
+	policies    map[string]*policy
+	attachments map[string]map[string]bool // access key ID -> policy ARN set
+}
+
+type accessKey struct {
+	id      string
+	secret  string
+	owner   string
+	status  string
+	created time.Time
+}
+
+type policy struct {
+	name        string
+	id          string
+	arn         string
+	path        string
+	document    string
+	description string
+	created     time.Time
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		keys:        map[string]*accessKey{},
+		deleted:     map[string]bool{},
+		policies:    map[string]*policy{},
+		attachments: map[string]map[string]bool{},
+	}
+}
+
+// NewServer starts an httptest.Server backed by a fresh Handler. Point IAM_ENDPOINT at its URL.
+func NewServer() *httptest.Server {
+	return httptest.NewServer(NewHandler())
+}
+
+// IsQueryRequest reports whether r looks like an IAM Query-protocol call, so a
+// combined endpoint can route it here and everything else to S3.
+func IsQueryRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && (r.URL.Path == "/" || r.URL.Path == "") &&
+		strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedQueryString", err.Error())
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch action := r.Form.Get("Action"); action {
+	case "CreateAccessKey":
+		h.createAccessKey(w, r)
+	case "ListAccessKeys":
+		h.listAccessKeys(w, r)
+	case "UpdateAccessKey":
+		h.updateAccessKey(w, r)
+	case "DeleteAccessKey":
+		h.deleteAccessKey(w, r)
+	case "CreatePolicy":
+		h.createPolicy(w, r)
+	case "DeletePolicy":
+		h.deletePolicy(w, r)
+	case "AttachUserPolicy":
+		h.attachUserPolicy(w, r)
+	case "DetachUserPolicy":
+		h.detachUserPolicy(w, r)
+	case "ListAttachedUserPolicies":
+		h.listAttachedUserPolicies(w, r)
+	case "ListPolicies":
+		h.listPolicies(w, r)
+	default:
+		writeError(w, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("Could not find operation %q for version 2010-05-08", action))
+	}
+}
+
+type responseMetadata struct {
+	RequestID string `xml:"RequestId"`
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"ErrorResponse"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Error   struct {
+		Type    string `xml:"Type"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+	RequestID string `xml:"RequestId"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	var res errorResponse
+	res.Xmlns = xmlns
+	res.Error.Type = "Sender"
+	res.Error.Code = code
+	res.Error.Message = msg
+	res.RequestID = "fakeiam"
+	writeXML(w, status, res)
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	out, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(out)
+}
+
+// callerKeyID extracts the access key ID from a SigV4 Authorization header.
+func callerKeyID(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	_, cred, ok := strings.Cut(auth, "Credential=")
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(cred, "/")
+	return id
+}
+
+const idAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+func randomID(prefix string, n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = idAlphabet[int(b[i])%len(idAlphabet)]
+	}
+	return prefix + string(b)
+}
+
+func isoTime(t time.Time) string { return t.UTC().Format("2006-01-02T15:04:05Z") }
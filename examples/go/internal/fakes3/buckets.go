@@ -0,0 +1,170 @@
+package fakes3
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name      `xml:"ListAllMyBucketsResult"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	Owner   owner         `xml:"Owner"`
+	Buckets []bucketEntry `xml:"Buckets>Bucket"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type bucketEntry struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type listBucketResultV2 struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []objectEntry  `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type objectEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func (h *Handler) listBuckets(w http.ResponseWriter, r *http.Request) {
+	res := listAllMyBucketsResult{Xmlns: xmlns, Owner: owner{ID: "fakes3", DisplayName: "fakes3"}}
+	for _, b := range h.buckets {
+		res.Buckets = append(res.Buckets, bucketEntry{Name: b.name, CreationDate: isoTime(b.created)})
+	}
+	sort.Slice(res.Buckets, func(i, j int) bool { return res.Buckets[i].Name < res.Buckets[j].Name })
+	writeXML(w, http.StatusOK, res)
+}
+
+func (h *Handler) createBucket(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := h.buckets[name]; ok {
+		writeError(w, r, http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
+		return
+	}
+	h.buckets[name] = &bucket{name: name, created: time.Now(), objects: map[string]*object{}, versions: map[string][]*object{}}
+	w.Header().Set("Location", "/"+name)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) headBucket(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := h.buckets[name]; !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) deleteBucket(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := h.buckets[name]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	if len(b.versions) > 0 {
+		writeError(w, r, http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty")
+		return
+	}
+	delete(h.buckets, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listObjectsV2(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := h.buckets[name]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+	maxKeys := 1000
+	if v := q.Get("max-keys"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n < maxKeys {
+			maxKeys = n
+		}
+	}
+	after := q.Get("start-after")
+	if token := q.Get("continuation-token"); token != "" {
+		after = token
+	}
+
+	keys := make([]string, 0, len(b.objects))
+	for k := range b.objects {
+		if strings.HasPrefix(k, prefix) && k > after {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	res := listBucketResultV2{
+		Xmlns:             xmlns,
+		Name:              name,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		StartAfter:        q.Get("start-after"),
+		MaxKeys:           maxKeys,
+		ContinuationToken: q.Get("continuation-token"),
+	}
+	seen := map[string]bool{}
+	last := ""
+	for _, k := range keys {
+		cp := ""
+		if delimiter != "" {
+			if i := strings.Index(k[len(prefix):], delimiter); i >= 0 {
+				cp = k[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if cp != "" && seen[cp] {
+			last = k
+			continue
+		}
+		if res.KeyCount >= maxKeys {
+			res.IsTruncated = true
+			res.NextContinuationToken = last
+			break
+		}
+		last = k
+		res.KeyCount++
+		if cp != "" {
+			seen[cp] = true
+			res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: cp})
+			continue
+		}
+		o := b.objects[k]
+		res.Contents = append(res.Contents, objectEntry{
+			Key:          k,
+			LastModified: isoTime(o.lastModified),
+			ETag:         o.etag,
+			Size:         len(o.data),
+			StorageClass: "STANDARD",
+		})
+	}
+	writeXML(w, http.StatusOK, res)
+}
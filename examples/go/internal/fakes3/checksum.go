@@ -0,0 +1,138 @@
+package fakes3
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"net/http"
+	"strings"
+)
+
+// checksumAlgorithms are the flexible checksum algorithms the fake stores.
+var checksumAlgorithms = []string{"CRC32", "CRC32C", "SHA1", "SHA256"}
+
+// checksum is a stored flexible checksum. value is base64; for an object
+// completed from parts it is composite, ending in "-<parts>".
+type checksum struct {
+	algorithm string
+	value     string
+}
+
+// checksumFields are the Checksum<ALG> elements of XML results and of the
+// parts in a CompleteMultipartUpload request.
+type checksumFields struct {
+	ChecksumCRC32  string `xml:"ChecksumCRC32,omitempty"`
+	ChecksumCRC32C string `xml:"ChecksumCRC32C,omitempty"`
+	ChecksumSHA1   string `xml:"ChecksumSHA1,omitempty"`
+	ChecksumSHA256 string `xml:"ChecksumSHA256,omitempty"`
+}
+
+func (c checksum) fields() checksumFields {
+	var f checksumFields
+	switch c.algorithm {
+	case "CRC32":
+		f.ChecksumCRC32 = c.value
+	case "CRC32C":
+		f.ChecksumCRC32C = c.value
+	case "SHA1":
+		f.ChecksumSHA1 = c.value
+	case "SHA256":
+		f.ChecksumSHA256 = c.value
+	}
+	return f
+}
+
+func (f checksumFields) get(alg string) string {
+	switch alg {
+	case "CRC32":
+		return f.ChecksumCRC32
+	case "CRC32C":
+		return f.ChecksumCRC32C
+	case "SHA1":
+		return f.ChecksumSHA1
+	case "SHA256":
+		return f.ChecksumSHA256
+	}
+	return ""
+}
+
+// setHeader sends the checksum as x-amz-checksum-<alg>, if there is one.
+func (c checksum) setHeader(w http.ResponseWriter) {
+	if c.algorithm != "" {
+		w.Header().Set("x-amz-checksum-"+strings.ToLower(c.algorithm), c.value)
+	}
+}
+
+func newHash(alg string) hash.Hash {
+	switch alg {
+	case "CRC32":
+		return crc32.NewIEEE()
+	case "CRC32C":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "SHA1":
+		return sha1.New()
+	case "SHA256":
+		return sha256.New()
+	}
+	return nil
+}
+
+func validAlgorithm(alg string) bool {
+	return newHash(alg) != nil
+}
+
+func computeChecksum(alg string, data []byte) checksum {
+	h := newHash(alg)
+	h.Write(data)
+	return checksum{algorithm: alg, value: base64.StdEncoding.EncodeToString(h.Sum(nil))}
+}
+
+// compositeChecksum is the checksum of the concatenated raw part checksums,
+// with the part count appended.
+func compositeChecksum(alg string, parts []checksum) checksum {
+	h := newHash(alg)
+	for _, p := range parts {
+		raw, _ := base64.StdEncoding.DecodeString(p.value)
+		h.Write(raw)
+	}
+	return checksum{algorithm: alg, value: fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(h.Sum(nil)), len(parts))}
+}
+
+// requestChecksum checks the x-amz-checksum-<alg> header of a PutObject or
+// UploadPart request against data. Without one, the checksum is computed with
+// the algorithm the request names in x-amz-sdk-checksum-algorithm, or else
+// with want (a multipart upload's algorithm). A bad or mismatched checksum
+// writes the error and returns false.
+func requestChecksum(w http.ResponseWriter, r *http.Request, data []byte, want string) (checksum, bool) {
+	for _, alg := range checksumAlgorithms {
+		v := r.Header.Get("x-amz-checksum-" + strings.ToLower(alg))
+		if v == "" {
+			continue
+		}
+		if want != "" && alg != want {
+			writeError(w, r, http.StatusBadRequest, "InvalidRequest", fmt.Sprintf("Checksum Type mismatch occurred, expected checksum Type: %s, actual checksum Type: %s", strings.ToLower(want), strings.ToLower(alg)))
+			return checksum{}, false
+		}
+		c := computeChecksum(alg, data)
+		if c.value != v {
+			writeError(w, r, http.StatusBadRequest, "BadDigest", fmt.Sprintf("The %s you specified did not match the calculated checksum.", alg))
+			return checksum{}, false
+		}
+		return c, true
+	}
+	alg := strings.ToUpper(r.Header.Get("x-amz-sdk-checksum-algorithm"))
+	if alg == "" {
+		alg = want
+	}
+	if alg == "" {
+		return checksum{}, true
+	}
+	if !validAlgorithm(alg) {
+		writeError(w, r, http.StatusBadRequest, "InvalidRequest", "Value for x-amz-sdk-checksum-algorithm header is invalid.")
+		return checksum{}, false
+	}
+	return computeChecksum(alg, data), true
+}
@@ -0,0 +1,441 @@
+package fakes3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type upload struct {
+	id          string
+	bucket      string
+	key         string
+	contentType string
+	metadata    map[string]string
+	tags        map[string]string
+	initiated   time.Time
+	parts       map[int]*part
+	// checksumAlgorithm, when set, is computed for every part and required in
+	// the CompleteMultipartUpload request.
+	checksumAlgorithm string
+}
+
+type part struct {
+	data         []byte
+	etag         string
+	lastModified time.Time
+	checksum     checksum
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+		checksumFields
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+	checksumFields
+}
+
+type listMultipartUploadsResult struct {
+	XMLName            xml.Name      `xml:"ListMultipartUploadsResult"`
+	Xmlns              string        `xml:"xmlns,attr"`
+	Bucket             string        `xml:"Bucket"`
+	Prefix             string        `xml:"Prefix"`
+	KeyMarker          string        `xml:"KeyMarker"`
+	UploadIdMarker     string        `xml:"UploadIdMarker"`
+	NextKeyMarker      string        `xml:"NextKeyMarker,omitempty"`
+	NextUploadIdMarker string        `xml:"NextUploadIdMarker,omitempty"`
+	MaxUploads         int           `xml:"MaxUploads"`
+	IsTruncated        bool          `xml:"IsTruncated"`
+	Uploads            []uploadEntry `xml:"Upload"`
+}
+
+type uploadEntry struct {
+	Key          string `xml:"Key"`
+	UploadId     string `xml:"UploadId"`
+	Initiated    string `xml:"Initiated"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type listPartsResult struct {
+	XMLName              xml.Name    `xml:"ListPartsResult"`
+	Xmlns                string      `xml:"xmlns,attr"`
+	Bucket               string      `xml:"Bucket"`
+	Key                  string      `xml:"Key"`
+	UploadID             string      `xml:"UploadId"`
+	PartNumberMarker     int         `xml:"PartNumberMarker"`
+	NextPartNumberMarker int         `xml:"NextPartNumberMarker,omitempty"`
+	MaxParts             int         `xml:"MaxParts"`
+	IsTruncated          bool        `xml:"IsTruncated"`
+	StorageClass         string      `xml:"StorageClass"`
+	ChecksumAlgorithm    string      `xml:"ChecksumAlgorithm,omitempty"`
+	Parts                []partEntry `xml:"Part"`
+}
+
+type partEntry struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	checksumFields
+}
+
+// minPartSize is the S3 lower bound for every part except the last.
+const minPartSize = 5 * 1024 * 1024
+
+func (h *Handler) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	if _, ok := h.buckets[bucketName]; !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	alg := strings.ToUpper(r.Header.Get("x-amz-checksum-algorithm"))
+	if alg != "" && !validAlgorithm(alg) {
+		writeError(w, r, http.StatusBadRequest, "InvalidRequest", "Value for x-amz-checksum-algorithm header is invalid.")
+		return
+	}
+	u := &upload{
+		id:          h.nextID("upload-"),
+		bucket:      bucketName,
+		key:         key,
+		contentType: r.Header.Get("Content-Type"),
+		metadata:    userMetadata(r.Header),
+		tags:        headerTags(r),
+		initiated:   time.Now(),
+		parts:       map[int]*part{},
+
+		checksumAlgorithm: alg,
+	}
+	h.uploads[u.id] = u
+	if alg != "" {
+		w.Header().Set("x-amz-checksum-algorithm", alg)
+	}
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{Xmlns: xmlns, Bucket: bucketName, Key: key, UploadID: u.id})
+}
+
+// lookupUpload finds an in-progress upload, writing NoSuchUpload when it is unknown.
+func (h *Handler) lookupUpload(w http.ResponseWriter, r *http.Request, bucketName, key string) *upload {
+	u, ok := h.uploads[r.URL.Query().Get("uploadId")]
+	if !ok || u.bucket != bucketName || u.key != key {
+		writeError(w, r, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return nil
+	}
+	return u
+}
+
+func (h *Handler) uploadPart(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	u := h.lookupUpload(w, r, bucketName, key)
+	if u == nil {
+		return
+	}
+	n, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || n < 1 || n > 10000 {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive")
+		return
+	}
+	data, ok := readPayload(w, r)
+	if !ok {
+		return
+	}
+	sum, ok := requestChecksum(w, r, data, u.checksumAlgorithm)
+	if !ok {
+		return
+	}
+	p := &part{data: data, etag: md5ETag(data), lastModified: time.Now(), checksum: sum}
+	u.parts[n] = p
+	p.checksum.setHeader(w)
+	w.Header().Set("ETag", p.etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+type copyPartResult struct {
+	XMLName      xml.Name `xml:"CopyPartResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	ETag         string   `xml:"ETag"`
+	LastModified string   `xml:"LastModified"`
+	checksumFields
+}
+
+// uploadPartCopy stores a part copied from the x-amz-copy-source object, or
+// from the x-amz-copy-source-range of it.
+func (h *Handler) uploadPartCopy(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	u := h.lookupUpload(w, r, bucketName, key)
+	if u == nil {
+		return
+	}
+	n, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || n < 1 || n > 10000 {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive")
+		return
+	}
+	src, code := h.copySource(r)
+	if src == nil {
+		writeError(w, r, http.StatusNotFound, code, "The specified copy source does not exist")
+		return
+	}
+	if !copySourceMatches(r, src) {
+		writeError(w, r, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+		return
+	}
+	data := src.data
+	if rng := r.Header.Get("x-amz-copy-source-range"); rng != "" {
+		start, end, ok := parseCopyRange(rng, int64(len(data)))
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy")
+			return
+		}
+		data = data[start : end+1]
+	}
+	p := &part{data: append([]byte(nil), data...), etag: md5ETag(data), lastModified: time.Now()}
+	if u.checksumAlgorithm != "" {
+		p.checksum = computeChecksum(u.checksumAlgorithm, p.data)
+	}
+	u.parts[n] = p
+	writeXML(w, http.StatusOK, copyPartResult{Xmlns: xmlns, ETag: p.etag, LastModified: isoTime(p.lastModified), checksumFields: p.checksum.fields()})
+}
+
+// parseCopyRange handles "bytes=first-last". Unlike a GET Range, both offsets
+// are required and the range must lie within the source.
+func parseCopyRange(spec string, size int64) (int64, int64, bool) {
+	first, last, ok := strings.Cut(strings.TrimPrefix(spec, "bytes="), "-")
+	start, err1 := strconv.ParseInt(first, 10, 64)
+	end, err2 := strconv.ParseInt(last, 10, 64)
+	if !ok || !strings.HasPrefix(spec, "bytes=") || err1 != nil || err2 != nil || start < 0 || end < start || end >= size {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+func (h *Handler) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	u := h.lookupUpload(w, r, bucketName, key)
+	if u == nil {
+		return
+	}
+	b, ok := h.buckets[bucketName]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	var req completeMultipartUpload
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.")
+		return
+	}
+
+	var data []byte
+	var sizes []int
+	var checksums []checksum
+	sums := md5.New()
+	prev := 0
+	for i, cp := range req.Parts {
+		if cp.PartNumber <= prev {
+			writeError(w, r, http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order.")
+			return
+		}
+		prev = cp.PartNumber
+		p, ok := u.parts[cp.PartNumber]
+		if !ok || strings.Trim(cp.ETag, `"`) != strings.Trim(p.etag, `"`) {
+			writeError(w, r, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+			return
+		}
+		if alg := u.checksumAlgorithm; alg != "" {
+			switch got := cp.get(alg); {
+			case got == "":
+				writeError(w, r, http.StatusBadRequest, "InvalidRequest", fmt.Sprintf("The upload was created using a %s checksum. The complete request must include the checksum for each part. It was missing for part %d in the request.", strings.ToLower(alg), cp.PartNumber))
+				return
+			case got != p.checksum.value:
+				writeError(w, r, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+				return
+			}
+			checksums = append(checksums, p.checksum)
+		}
+		if i < len(req.Parts)-1 && len(p.data) < minPartSize {
+			writeError(w, r, http.StatusBadRequest, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.")
+			return
+		}
+		raw, _ := hex.DecodeString(strings.Trim(p.etag, `"`))
+		sums.Write(raw)
+		data = append(data, p.data...)
+		sizes = append(sizes, len(p.data))
+	}
+
+	o := &object{
+		key:          key,
+		data:         data,
+		etag:         fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sums.Sum(nil)), len(req.Parts)),
+		contentType:  u.contentType,
+		metadata:     u.metadata,
+		tags:         u.tags,
+		lastModified: time.Now(),
+		partSizes:    sizes,
+	}
+	if u.checksumAlgorithm != "" {
+		o.checksum = compositeChecksum(u.checksumAlgorithm, checksums)
+	}
+	b.store(o, h.nextID("v"))
+	if b.versioning != "" {
+		w.Header().Set("x-amz-version-id", o.versionID)
+	}
+	delete(h.uploads, u.id)
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Xmlns:    xmlns,
+		Location: "/" + bucketName + "/" + key,
+		Bucket:   bucketName,
+		Key:      key,
+		ETag:     o.etag,
+
+		checksumFields: o.checksum.fields(),
+	})
+}
+
+// listMultipartUploads lists in-progress uploads by key and upload ID, paginated
+// by key-marker and upload-id-marker.
+func (h *Handler) listMultipartUploads(w http.ResponseWriter, r *http.Request, bucketName string) {
+	if _, ok := h.buckets[bucketName]; !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	keyMarker := q.Get("key-marker")
+	idMarker := q.Get("upload-id-marker")
+	maxUploads := 1000
+	if v := q.Get("max-uploads"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n < maxUploads {
+			maxUploads = n
+		}
+	}
+
+	var uploads []*upload
+	for _, u := range h.uploads {
+		if u.bucket != bucketName || !strings.HasPrefix(u.key, prefix) {
+			continue
+		}
+		if u.key < keyMarker || (u.key == keyMarker && (idMarker == "" || u.id <= idMarker)) {
+			continue
+		}
+		uploads = append(uploads, u)
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].key != uploads[j].key {
+			return uploads[i].key < uploads[j].key
+		}
+		return uploads[i].id < uploads[j].id
+	})
+
+	res := listMultipartUploadsResult{
+		Xmlns:          xmlns,
+		Bucket:         bucketName,
+		Prefix:         prefix,
+		KeyMarker:      keyMarker,
+		UploadIdMarker: idMarker,
+		MaxUploads:     maxUploads,
+	}
+	if len(uploads) > maxUploads {
+		uploads = uploads[:maxUploads]
+		res.IsTruncated = true
+		if maxUploads > 0 {
+			res.NextKeyMarker = uploads[maxUploads-1].key
+			res.NextUploadIdMarker = uploads[maxUploads-1].id
+		}
+	}
+	for _, u := range uploads {
+		res.Uploads = append(res.Uploads, uploadEntry{Key: u.key, UploadId: u.id, Initiated: isoTime(u.initiated), StorageClass: "STANDARD"})
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+// listParts lists the parts uploaded so far in part-number order, paginated by
+// part-number-marker.
+func (h *Handler) listParts(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	u := h.lookupUpload(w, r, bucketName, key)
+	if u == nil {
+		return
+	}
+	q := r.URL.Query()
+	marker, _ := strconv.Atoi(q.Get("part-number-marker"))
+	maxParts := 1000
+	if v := q.Get("max-parts"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n < maxParts {
+			maxParts = n
+		}
+	}
+
+	var numbers []int
+	for n := range u.parts {
+		if n > marker {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+
+	res := listPartsResult{
+		Xmlns:            xmlns,
+		Bucket:           bucketName,
+		Key:              key,
+		UploadID:         u.id,
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+		StorageClass:     "STANDARD",
+
+		ChecksumAlgorithm: u.checksumAlgorithm,
+	}
+	if len(numbers) > maxParts {
+		numbers = numbers[:maxParts]
+		res.IsTruncated = true
+		if maxParts > 0 {
+			res.NextPartNumberMarker = numbers[maxParts-1]
+		}
+	}
+	for _, n := range numbers {
+		p := u.parts[n]
+		res.Parts = append(res.Parts, partEntry{PartNumber: n, LastModified: isoTime(p.lastModified), ETag: p.etag, Size: len(p.data), checksumFields: p.checksum.fields()})
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+func (h *Handler) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	u := h.lookupUpload(w, r, bucketName, key)
+	if u == nil {
+		return
+	}
+	delete(h.uploads, u.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// partRange returns the byte range of part n of o, as GET ?partNumber=n serves
+// it, and the object's part count. An object not uploaded in parts has one part.
+func (o *object) partRange(n int) (start, end int64, count int, ok bool) {
+	if len(o.partSizes) == 0 {
+		return 0, int64(len(o.data)) - 1, 1, n == 1
+	}
+	if n < 1 || n > len(o.partSizes) {
+		return 0, 0, len(o.partSizes), false
+	}
+	for _, size := range o.partSizes[:n-1] {
+		start += int64(size)
+	}
+	return start, start + int64(o.partSizes[n-1]) - 1, len(o.partSizes), true
+}
@@ -0,0 +1,356 @@
+package fakes3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	ETag         string   `xml:"ETag"`
+	LastModified string   `xml:"LastModified"`
+	checksumFields
+}
+
+func (h *Handler) putObject(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	b, ok := h.buckets[bucketName]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	data, ok := readPayload(w, r)
+	if !ok {
+		return
+	}
+	sum, ok := requestChecksum(w, r, data, "")
+	if !ok {
+		return
+	}
+	o := &object{
+		key:          key,
+		data:         data,
+		etag:         md5ETag(data),
+		contentType:  r.Header.Get("Content-Type"),
+		metadata:     userMetadata(r.Header),
+		tags:         headerTags(r),
+		lastModified: time.Now(),
+		checksum:     sum,
+	}
+	b.store(o, h.nextID("v"))
+	if b.versioning != "" {
+		w.Header().Set("x-amz-version-id", o.versionID)
+	}
+	o.checksum.setHeader(w)
+	w.Header().Set("ETag", o.etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	b, ok := h.buckets[bucketName]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	o, ok := b.objects[key]
+	if id := r.URL.Query().Get("versionId"); id != "" {
+		o = b.version(key, id)
+		switch {
+		case o == nil:
+			writeError(w, r, http.StatusNotFound, "NoSuchVersion", "The specified version does not exist.")
+			return
+		case o.deleteMarker:
+			w.Header().Set("x-amz-delete-marker", "true")
+			w.Header().Set("x-amz-version-id", id)
+			writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+			return
+		}
+	} else if !ok {
+		if b.latestIsDeleteMarker(key) {
+			w.Header().Set("x-amz-delete-marker", "true")
+		}
+		writeError(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+
+	if m := r.Header.Get("If-Match"); m != "" && m != "*" && m != o.etag {
+		writeError(w, r, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+		return
+	}
+	if m := r.Header.Get("If-None-Match"); m == "*" || m == o.etag {
+		w.Header().Set("ETag", o.etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := o.data
+	status := http.StatusOK
+	whole := true
+	if pn := r.URL.Query().Get("partNumber"); pn != "" {
+		n, _ := strconv.Atoi(pn)
+		start, end, count, ok := o.partRange(n)
+		if !ok {
+			writeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidPartNumber", "The requested partnumber is not satisfiable")
+			return
+		}
+		body = o.data[start : end+1]
+		status = http.StatusPartialContent
+		whole = count == 1
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(o.data)))
+		w.Header().Set("x-amz-mp-parts-count", strconv.Itoa(count))
+	} else if rng := r.Header.Get("Range"); rng != "" {
+		start, end, ok := parseRange(rng, int64(len(o.data)))
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(o.data)))
+			writeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable")
+			return
+		}
+		body = o.data[start : end+1]
+		status = http.StatusPartialContent
+		whole = false
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(o.data)))
+	}
+
+	hdr := w.Header()
+	hdr.Set("ETag", o.etag)
+	hdr.Set("Last-Modified", o.lastModified.UTC().Format(http.TimeFormat))
+	hdr.Set("Content-Length", strconv.Itoa(len(body)))
+	hdr.Set("Accept-Ranges", "bytes")
+	if b.versioning != "" {
+		hdr.Set("x-amz-version-id", o.versionID)
+	}
+	if o.contentType != "" {
+		hdr.Set("Content-Type", o.contentType)
+	}
+	for k, v := range o.metadata {
+		hdr.Set("x-amz-meta-"+k, v)
+	}
+	// Like S3, checksums are returned on request, and only for the whole object.
+	if whole && strings.EqualFold(r.Header.Get("x-amz-checksum-mode"), "ENABLED") {
+		o.checksum.setHeader(w)
+	}
+	setTagCount(w, o)
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+func (h *Handler) deleteObject(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	b, ok := h.buckets[bucketName]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	id, marker := h.remove(b, key, r.URL.Query().Get("versionId"))
+	if id != "" {
+		w.Header().Set("x-amz-version-id", id)
+	}
+	if marker {
+		w.Header().Set("x-amz-delete-marker", "true")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type deleteRequest struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key       string `xml:"Key"`
+		VersionId string `xml:"VersionId"`
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+type deletedObject struct {
+	Key                   string `xml:"Key"`
+	VersionId             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionId string `xml:"DeleteMarkerVersionId,omitempty"`
+}
+
+type deleteError struct {
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId,omitempty"`
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message"`
+}
+
+// maxDeleteObjects is the S3 limit on keys per DeleteObjects request.
+const maxDeleteObjects = 1000
+
+func (h *Handler) deleteObjects(w http.ResponseWriter, r *http.Request, bucketName string) {
+	b, ok := h.buckets[bucketName]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	var req deleteRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Objects) == 0 || len(req.Objects) > maxDeleteObjects {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.")
+		return
+	}
+
+	res := deleteResult{Xmlns: xmlns}
+	for _, o := range req.Objects {
+		if o.Key == "" {
+			res.Errors = append(res.Errors, deleteError{VersionId: o.VersionId, Code: "InvalidArgument", Message: "Key is required"})
+			continue
+		}
+		id, marker := h.remove(b, o.Key, o.VersionId)
+		if req.Quiet {
+			continue
+		}
+		d := deletedObject{Key: o.Key, VersionId: o.VersionId, DeleteMarker: marker}
+		if marker && o.VersionId == "" {
+			d.DeleteMarkerVersionId = id
+		}
+		res.Deleted = append(res.Deleted, d)
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+func (h *Handler) copyObject(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	dst, ok := h.buckets[bucketName]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	src, code := h.copySource(r)
+	if src == nil {
+		writeError(w, r, http.StatusNotFound, code, "The specified copy source does not exist")
+		return
+	}
+	if !copySourceMatches(r, src) {
+		writeError(w, r, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+		return
+	}
+
+	o := &object{
+		key:          key,
+		data:         append([]byte(nil), src.data...),
+		etag:         src.etag,
+		contentType:  src.contentType,
+		metadata:     src.metadata,
+		tags:         src.tags,
+		lastModified: time.Now(),
+		partSizes:    src.partSizes,
+	}
+	// The copy is one part, so it gets a full-object checksum: with the
+	// requested algorithm, or else the source's.
+	alg := strings.ToUpper(r.Header.Get("x-amz-checksum-algorithm"))
+	if alg == "" {
+		alg = src.checksum.algorithm
+	}
+	if alg != "" {
+		if !validAlgorithm(alg) {
+			writeError(w, r, http.StatusBadRequest, "InvalidRequest", "Value for x-amz-checksum-algorithm header is invalid.")
+			return
+		}
+		o.checksum = computeChecksum(alg, o.data)
+	}
+	if strings.EqualFold(r.Header.Get("x-amz-metadata-directive"), "REPLACE") {
+		o.contentType = r.Header.Get("Content-Type")
+		o.metadata = userMetadata(r.Header)
+	}
+	if strings.EqualFold(r.Header.Get("x-amz-tagging-directive"), "REPLACE") {
+		o.tags = headerTags(r)
+	}
+	dst.store(o, h.nextID("v"))
+	if dst.versioning != "" {
+		w.Header().Set("x-amz-version-id", o.versionID)
+	}
+	writeXML(w, http.StatusOK, copyObjectResult{Xmlns: xmlns, ETag: o.etag, LastModified: isoTime(o.lastModified), checksumFields: o.checksum.fields()})
+}
+
+// copySource resolves the x-amz-copy-source header, returning the error code to report when it is missing.
+func (h *Handler) copySource(r *http.Request) (*object, string) {
+	raw := r.Header.Get("x-amz-copy-source")
+	raw, query, _ := strings.Cut(raw, "?")
+	if s, err := url.PathUnescape(raw); err == nil {
+		raw = s
+	}
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(raw, "/"), "/")
+	b, ok := h.buckets[bucketName]
+	if !ok {
+		return nil, "NoSuchBucket"
+	}
+	if id, ok := strings.CutPrefix(query, "versionId="); ok {
+		if o := b.version(key, id); o != nil && !o.deleteMarker {
+			return o, ""
+		}
+		return nil, "NoSuchVersion"
+	}
+	o, ok := b.objects[key]
+	if !ok {
+		return nil, "NoSuchKey"
+	}
+	return o, ""
+}
+
+// copySourceMatches checks x-amz-copy-source-if-match against the source's ETag.
+func copySourceMatches(r *http.Request, src *object) bool {
+	m := r.Header.Get("x-amz-copy-source-if-match")
+	return m == "" || m == "*" || m == src.etag || `"`+m+`"` == src.etag
+}
+
+func userMetadata(hdr http.Header) map[string]string {
+	md := map[string]string{}
+	for k, v := range hdr {
+		if name, ok := strings.CutPrefix(strings.ToLower(k), "x-amz-meta-"); ok && len(v) > 0 {
+			md[name] = v[0]
+		}
+	}
+	return md
+}
+
+func md5ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// parseRange handles the single-range forms "bytes=a-b", "bytes=a-" and "bytes=-n".
+func parseRange(spec string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(spec, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, _ := strings.Cut(spec, "-")
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if last != "" {
+		e, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || e < start {
+			return 0, 0, false
+		}
+		if e < end {
+			end = e
+		}
+	}
+	return start, end, true
+}
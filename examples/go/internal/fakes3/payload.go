@@ -0,0 +1,103 @@
+package fakes3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// readPayload reads a PutObject or UploadPart body. An aws-chunked body
+// (x-amz-content-sha256 STREAMING-...) is decoded, and its trailers are added
+// to r.Header, where requestChecksum finds a trailing checksum like a header
+// one. A signed payload hash must match the body. Errors are written and
+// reported by returning false.
+func readPayload(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return nil, false
+	}
+	hash := r.Header.Get("x-amz-content-sha256")
+	switch {
+	case strings.HasPrefix(hash, "STREAMING-"):
+		var trailers http.Header
+		data, trailers, err = decodeChunked(data)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return nil, false
+		}
+		if n, err := strconv.Atoi(r.Header.Get("x-amz-decoded-content-length")); err != nil || n != len(data) {
+			writeError(w, r, http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the x-amz-decoded-content-length HTTP header.")
+			return nil, false
+		}
+		for _, name := range strings.Split(r.Header.Get("x-amz-trailer"), ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			v := trailers.Get(name)
+			if v == "" {
+				writeError(w, r, http.StatusBadRequest, "MalformedTrailerError", "The request contained trailing data that was not well-formed or did not conform to our published schema.")
+				return nil, false
+			}
+			r.Header.Set(name, v)
+		}
+	case len(hash) == sha256.Size*2:
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != strings.ToLower(hash) {
+			writeError(w, r, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.")
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// decodeChunked decodes an aws-chunked body: chunks of "<hex size>[;chunk-
+// signature=...]\r\n<data>\r\n" ending with a zero-size chunk, then optional
+// "name:value\r\n" trailers and "\r\n".
+func decodeChunked(body []byte) ([]byte, http.Header, error) {
+	br := bufio.NewReader(bytes.NewReader(body))
+	var data []byte
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, nil, fmt.Errorf("aws-chunked body ends before the final chunk")
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size < 0 {
+			return nil, nil, fmt.Errorf("invalid aws-chunked chunk size %q", sizeHex)
+		}
+		if size == 0 {
+			break
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(br, chunk); err != nil || !bytes.HasSuffix(chunk, []byte("\r\n")) {
+			return nil, nil, fmt.Errorf("aws-chunked chunk shorter than its size %d", size)
+		}
+		data = append(data, chunk[:size]...)
+	}
+	trailers := http.Header{}
+	for {
+		line, err := br.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			// Trailers end with an empty line (or, leniently, the body).
+			return data, trailers, nil
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid aws-chunked trailer %q", line)
+		}
+		trailers.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+		if err != nil {
+			return data, trailers, nil
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package fakes3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPresignExpires is the longest validity S3 accepts for a presigned URL.
+const maxPresignExpires = 7 * 24 * 60 * 60
+
+// isPresigned reports whether r is authenticated by a SigV4 query string.
+func isPresigned(r *http.Request) bool {
+	return r.URL.Query().Has("X-Amz-Signature")
+}
+
+// checkPresigned enforces a presigned request's expiry and, when h.SecretKey
+// knows its access key, its signature. Errors are written and reported by
+// returning false.
+func (h *Handler) checkPresigned(w http.ResponseWriter, r *http.Request) bool {
+	q := r.URL.Query()
+	if q.Get("X-Amz-Algorithm") != "AWS4-HMAC-SHA256" {
+		writeError(w, r, http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Algorithm only supports \"AWS4-HMAC-SHA256\"")
+		return false
+	}
+	signed, err := time.Parse("20060102T150405Z", q.Get("X-Amz-Date"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Date must be in the ISO8601 Long Format \"yyyyMMdd'T'HHmmss'Z'\"")
+		return false
+	}
+	expires, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+	if err != nil || expires < 0 || expires > maxPresignExpires {
+		writeError(w, r, http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Expires must be a number of seconds between 0 and 604800")
+		return false
+	}
+	if time.Now().After(signed.Add(time.Duration(expires) * time.Second)) {
+		writeError(w, r, http.StatusForbidden, "AccessDenied", "Request has expired")
+		return false
+	}
+
+	credential := strings.Split(q.Get("X-Amz-Credential"), "/")
+	if len(credential) != 5 || credential[4] != "aws4_request" {
+		writeError(w, r, http.StatusBadRequest, "AuthorizationQueryParametersError", "Error parsing the X-Amz-Credential parameter")
+		return false
+	}
+	if h.SecretKey == nil {
+		return true
+	}
+	secret, ok := h.SecretKey(credential[0])
+	if !ok {
+		writeError(w, r, http.StatusForbidden, "InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records.")
+		return false
+	}
+	if want := presignSignature(r, secret, credential); !hmac.Equal([]byte(want), []byte(q.Get("X-Amz-Signature"))) {
+		writeError(w, r, http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your key and signing method.")
+		return false
+	}
+	return true
+}
+
+// presignSignature computes the SigV4 signature of a presigned request: the
+// path as sent, every query parameter but X-Amz-Signature, the headers named
+// in X-Amz-SignedHeaders, and an unsigned payload.
+func presignSignature(r *http.Request, secret string, credential []string) string {
+	q := r.URL.Query()
+	path, _, _ := strings.Cut(r.RequestURI, "?")
+	if path == "" || strings.HasPrefix(path, "http") {
+		path = r.URL.EscapedPath()
+	}
+
+	var params []string
+	for k, vs := range q {
+		if k == "X-Amz-Signature" {
+			continue
+		}
+		for _, v := range vs {
+			params = append(params, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	sort.Strings(params)
+
+	names := strings.Split(q.Get("X-Amz-SignedHeaders"), ";")
+	var headers strings.Builder
+	for _, name := range names {
+		v := r.Header.Get(name)
+		if name == "host" {
+			v = r.Host
+		}
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.Join(strings.Fields(v), " "))
+	}
+
+	payload := q.Get("X-Amz-Content-Sha256")
+	if payload == "" {
+		payload = "UNSIGNED-PAYLOAD"
+	}
+	canonical := strings.Join([]string{r.Method, path, strings.Join(params, "&"), headers.String(), strings.Join(names, ";"), payload}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	scope := strings.Join(credential[1:], "/")
+	stringToSign := "AWS4-HMAC-SHA256\n" + q.Get("X-Amz-Date") + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + secret)
+	for _, part := range credential[1:] {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// uriEncode percent-encodes everything but unreserved characters, as SigV4
+// canonical query strings require.
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,259 @@
+// Package fakes3 is an in-memory S3-compatible server covering the REST subset
+// used by the example programs, so they can run without network access.
+package fakes3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// Handler serves the S3 REST API from memory. The zero value is not usable; use NewHandler.
+type Handler struct {
+	// Domain, when set, enables virtual-hosted requests of the form <bucket>.<Domain>.
+	// Hosts ending in ".localhost" are always treated as virtual-hosted.
+	Domain string
+	// SecretKey, when set, returns the secret key for an access key ID, and
+	// presigned requests must carry a valid signature. Their expiry is always
+	// enforced. Other requests are not authenticated.
+	SecretKey func(accessKeyID string) (string, bool)
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	uploads map[string]*upload
+	seq     int
+}
+
+type bucket struct {
+	name    string
+	created time.Time
+	// objects maps each key to its current version; versions holds every version
+	// and delete marker per key, oldest first (see store).
+	objects  map[string]*object
+	versions map[string][]*object
+	// versioning is "", "Enabled" or "Suspended".
+	versioning string
+}
+
+type object struct {
+	key          string
+	versionID    string
+	deleteMarker bool
+	data         []byte
+	etag         string
+	contentType  string
+	metadata     map[string]string
+	tags         map[string]string
+	lastModified time.Time
+	// partSizes holds the part lengths of an object completed by multipart upload.
+	partSizes []int
+	checksum  checksum
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		buckets: map[string]*bucket{},
+		uploads: map[string]*upload{},
+	}
+}
+
+// NewServer starts an httptest.Server backed by a fresh Handler, adjusted by
+// optFns. Point S3_ENDPOINT at its URL.
+func NewServer(optFns ...func(*Handler)) *httptest.Server {
+	h := NewHandler()
+	for _, fn := range optFns {
+		fn(h)
+	}
+	return httptest.NewServer(h)
+}
+
+// ServeHTTP handles one request under the handler's lock. The request body is
+// read before the lock is taken, and the response is buffered and written
+// after it is released, so a client sending or reading a large body slowly
+// (e.g. streaming a download into an upload to the same server) does not
+// stall every other request.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	rec := httptest.NewRecorder()
+	h.mu.Lock()
+	h.serve(rec, r)
+	h.mu.Unlock()
+
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.Code)
+	_, _ = w.Write(rec.Body.Bytes())
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	if isPresigned(r) && !h.checkPresigned(w, r) {
+		return
+	}
+	bucketName, key := h.route(r)
+	q := r.URL.Query()
+
+	if bucketName == "" {
+		if r.Method == http.MethodGet {
+			h.listBuckets(w, r)
+			return
+		}
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+		return
+	}
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodPut && len(q) == 0:
+			h.createBucket(w, r, bucketName)
+		case r.Method == http.MethodHead:
+			h.headBucket(w, r, bucketName)
+		case r.Method == http.MethodDelete && len(q) == 0:
+			h.deleteBucket(w, r, bucketName)
+		case r.Method == http.MethodGet && q.Get("list-type") == "2":
+			h.listObjectsV2(w, r, bucketName)
+		case r.Method == http.MethodPut && q.Has("versioning"):
+			h.putBucketVersioning(w, r, bucketName)
+		case r.Method == http.MethodGet && q.Has("versioning"):
+			h.getBucketVersioning(w, r, bucketName)
+		case r.Method == http.MethodGet && q.Has("versions"):
+			h.listObjectVersions(w, r, bucketName)
+		case r.Method == http.MethodGet && q.Has("uploads"):
+			h.listMultipartUploads(w, r, bucketName)
+		case r.Method == http.MethodPost && q.Has("delete"):
+			h.deleteObjects(w, r, bucketName)
+		default:
+			notImplemented(w, r)
+		}
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && q.Has("tagging"):
+		h.getObjectTagging(w, r, bucketName, key)
+	case r.Method == http.MethodPut && q.Has("tagging"):
+		h.putObjectTagging(w, r, bucketName, key)
+	case r.Method == http.MethodDelete && q.Has("tagging"):
+		h.deleteObjectTagging(w, r, bucketName, key)
+	case hasSubresource(q):
+		notImplemented(w, r)
+	case r.Method == http.MethodPut && q.Has("uploadId") && r.Header.Get("x-amz-copy-source") != "":
+		h.uploadPartCopy(w, r, bucketName, key)
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		h.uploadPart(w, r, bucketName, key)
+	case r.Method == http.MethodPut && r.Header.Get("x-amz-copy-source") != "":
+		h.copyObject(w, r, bucketName, key)
+	case r.Method == http.MethodPut:
+		h.putObject(w, r, bucketName, key)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && !q.Has("uploadId"):
+		h.getObject(w, r, bucketName, key)
+	case r.Method == http.MethodGet && q.Has("uploadId"):
+		h.listParts(w, r, bucketName, key)
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		h.abortMultipartUpload(w, r, bucketName, key)
+	case r.Method == http.MethodDelete:
+		h.deleteObject(w, r, bucketName, key)
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		h.createMultipartUpload(w, r, bucketName, key)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		h.completeMultipartUpload(w, r, bucketName, key)
+	default:
+		notImplemented(w, r)
+	}
+}
+
+// route splits a request into bucket and key, honouring both path-style and virtual-hosted addressing.
+func (h *Handler) route(r *http.Request) (string, string) {
+	host := r.Host
+	if hh, _, err := net.SplitHostPort(host); err == nil {
+		host = hh
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	if b, ok := h.virtualBucket(host); ok {
+		return b, path
+	}
+	b, k, _ := strings.Cut(path, "/")
+	return b, k
+}
+
+// objectSubresources are the object sub-resources the fake does not serve. Without
+// this check, e.g. PUT ?acl would overwrite the object with the ACL document.
+var objectSubresources = []string{"acl", "retention", "legal-hold", "attributes", "torrent", "restore", "select"}
+
+func hasSubresource(q url.Values) bool {
+	for _, name := range objectSubresources {
+		if q.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) virtualBucket(host string) (string, bool) {
+	for _, suffix := range []string{"." + h.Domain, ".localhost"} {
+		if suffix == "." {
+			continue
+		}
+		if b, ok := strings.CutSuffix(host, suffix); ok && b != "" {
+			return b, true
+		}
+	}
+	return "", false
+}
+
+func (h *Handler) nextID(prefix string) string {
+	h.seq++
+	return fmt.Sprintf("%s%08d%d", prefix, h.seq, time.Now().UnixNano())
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	w.Header().Set("x-amz-request-id", "fakes3")
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	writeXML(w, status, errorResponse{Code: code, Message: msg, Resource: r.URL.Path, RequestID: "fakes3"})
+}
+
+func notImplemented(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotImplemented, "NotImplemented", "A header or query you provided implies functionality that is not implemented.")
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	out, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(out)
+}
+
+func isoTime(t time.Time) string { return t.UTC().Format("2006-01-02T15:04:05.000Z") }
@@ -0,0 +1,103 @@
+package fakes3
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// headerTags parses the URL-encoded x-amz-tagging header of PutObject,
+// CreateMultipartUpload and CopyObject.
+func headerTags(r *http.Request) map[string]string {
+	q, err := url.ParseQuery(r.Header.Get("x-amz-tagging"))
+	if err != nil || len(q) == 0 {
+		return nil
+	}
+	tags := map[string]string{}
+	for k, v := range q {
+		tags[k] = v[0]
+	}
+	return tags
+}
+
+func setTagCount(w http.ResponseWriter, o *object) {
+	if len(o.tags) > 0 {
+		w.Header().Set("x-amz-tagging-count", strconv.Itoa(len(o.tags)))
+	}
+}
+
+// taggedObject finds the object (or ?versionId version) a tagging request names.
+func (h *Handler) taggedObject(w http.ResponseWriter, r *http.Request, bucketName, key string) *object {
+	b, ok := h.buckets[bucketName]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return nil
+	}
+	o, ok := b.objects[key]
+	if id := r.URL.Query().Get("versionId"); id != "" {
+		o = b.version(key, id)
+		ok = o != nil && !o.deleteMarker
+	}
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return nil
+	}
+	return o
+}
+
+func (h *Handler) getObjectTagging(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	o := h.taggedObject(w, r, bucketName, key)
+	if o == nil {
+		return
+	}
+	res := tagging{Xmlns: xmlns, TagSet: []tag{}}
+	for k, v := range o.tags {
+		res.TagSet = append(res.TagSet, tag{Key: k, Value: v})
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+func (h *Handler) putObjectTagging(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	o := h.taggedObject(w, r, bucketName, key)
+	if o == nil {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	var req tagging
+	if err := xml.Unmarshal(body, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		return
+	}
+	tags := map[string]string{}
+	for _, t := range req.TagSet {
+		tags[t.Key] = t.Value
+	}
+	o.tags = tags
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) deleteObjectTagging(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	o := h.taggedObject(w, r, bucketName, key)
+	if o == nil {
+		return
+	}
+	o.tags = nil
+	w.WriteHeader(http.StatusNoContent)
+}
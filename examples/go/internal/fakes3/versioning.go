@@ -0,0 +1,227 @@
+package fakes3
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nullVersion is the version ID of objects written while versioning is off or suspended.
+const nullVersion = "null"
+
+type versioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+type listVersionsResult struct {
+	XMLName             xml.Name            `xml:"ListVersionsResult"`
+	Xmlns               string              `xml:"xmlns,attr"`
+	Name                string              `xml:"Name"`
+	Prefix              string              `xml:"Prefix"`
+	KeyMarker           string              `xml:"KeyMarker"`
+	VersionIdMarker     string              `xml:"VersionIdMarker"`
+	MaxKeys             int                 `xml:"MaxKeys"`
+	IsTruncated         bool                `xml:"IsTruncated"`
+	NextKeyMarker       string              `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string              `xml:"NextVersionIdMarker,omitempty"`
+	Versions            []versionEntry      `xml:"Version"`
+	DeleteMarkers       []deleteMarkerEntry `xml:"DeleteMarker"`
+}
+
+type versionEntry struct {
+	Key          string `xml:"Key"`
+	VersionId    string `xml:"VersionId"`
+	IsLatest     bool   `xml:"IsLatest"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type deleteMarkerEntry struct {
+	Key          string `xml:"Key"`
+	VersionId    string `xml:"VersionId"`
+	IsLatest     bool   `xml:"IsLatest"`
+	LastModified string `xml:"LastModified"`
+}
+
+// store makes o the latest version of its key. With versioning enabled it gets
+// id as a new version; otherwise it replaces the key's null version.
+func (b *bucket) store(o *object, id string) {
+	if b.versioning == "Enabled" {
+		o.versionID = id
+	} else {
+		o.versionID = nullVersion
+		b.removeVersion(o.key, nullVersion)
+	}
+	b.versions[o.key] = append(b.versions[o.key], o)
+	b.refresh(o.key)
+}
+
+// removeVersion deletes one version of key and returns it, or nil if there is no such version.
+func (b *bucket) removeVersion(key, id string) *object {
+	history := b.versions[key]
+	for i, o := range history {
+		if o.versionID == id {
+			history = append(history[:i:i], history[i+1:]...)
+			if len(history) == 0 {
+				delete(b.versions, key)
+			} else {
+				b.versions[key] = history
+			}
+			b.refresh(key)
+			return o
+		}
+	}
+	return nil
+}
+
+// version returns one version of key, or nil.
+func (b *bucket) version(key, id string) *object {
+	for _, o := range b.versions[key] {
+		if o.versionID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+// refresh points objects[key] at the latest version, unless that is a delete marker.
+func (b *bucket) refresh(key string) {
+	history := b.versions[key]
+	if len(history) == 0 || history[len(history)-1].deleteMarker {
+		delete(b.objects, key)
+		return
+	}
+	b.objects[key] = history[len(history)-1]
+}
+
+// latestIsDeleteMarker reports whether key currently reads as deleted because of a delete marker.
+func (b *bucket) latestIsDeleteMarker(key string) bool {
+	history := b.versions[key]
+	return len(history) > 0 && history[len(history)-1].deleteMarker
+}
+
+// remove deletes key as DeleteObject does: a given version is deleted for good,
+// otherwise a versioned bucket gets a new delete marker. It returns the version
+// ID acted on ("" when unversioned) and whether that version is a delete marker.
+func (h *Handler) remove(b *bucket, key, versionID string) (string, bool) {
+	if versionID != "" {
+		o := b.removeVersion(key, versionID)
+		return versionID, o != nil && o.deleteMarker
+	}
+	if b.versioning == "" {
+		b.removeVersion(key, nullVersion)
+		return "", false
+	}
+	marker := &object{key: key, deleteMarker: true, lastModified: time.Now()}
+	b.store(marker, h.nextID("v"))
+	return marker.versionID, true
+}
+
+func (h *Handler) putBucketVersioning(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := h.buckets[name]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	var cfg versioningConfiguration
+	if err := xml.NewDecoder(r.Body).Decode(&cfg); err != nil || (cfg.Status != "Enabled" && cfg.Status != "Suspended") {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.")
+		return
+	}
+	b.versioning = cfg.Status
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) getBucketVersioning(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := h.buckets[name]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	writeXML(w, http.StatusOK, versioningConfiguration{Xmlns: xmlns, Status: b.versioning})
+}
+
+// listObjectVersions lists versions and delete markers by key, newest first,
+// paginated by key-marker and version-id-marker.
+func (h *Handler) listObjectVersions(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := h.buckets[name]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	keyMarker := q.Get("key-marker")
+	versionMarker := q.Get("version-id-marker")
+	maxKeys := 1000
+	if v := q.Get("max-keys"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	keys := make([]string, 0, len(b.versions))
+	for k := range b.versions {
+		if strings.HasPrefix(k, prefix) && (k > keyMarker || (k == keyMarker && versionMarker != "")) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	res := listVersionsResult{
+		Xmlns:           xmlns,
+		Name:            name,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionMarker,
+		MaxKeys:         maxKeys,
+	}
+	count := 0
+	var last *object
+	for _, k := range keys {
+		history := b.versions[k]
+		skipping := k == keyMarker
+		for i := len(history) - 1; i >= 0; i-- {
+			o := history[i]
+			if skipping {
+				skipping = o.versionID != versionMarker
+				continue
+			}
+			if count >= maxKeys {
+				res.IsTruncated = true
+				if last != nil {
+					res.NextKeyMarker = last.key
+					res.NextVersionIdMarker = last.versionID
+				}
+				writeXML(w, http.StatusOK, res)
+				return
+			}
+			count++
+			last = o
+			latest := i == len(history)-1
+			if o.deleteMarker {
+				res.DeleteMarkers = append(res.DeleteMarkers, deleteMarkerEntry{
+					Key: k, VersionId: o.versionID, IsLatest: latest, LastModified: isoTime(o.lastModified),
+				})
+				continue
+			}
+			res.Versions = append(res.Versions, versionEntry{
+				Key:          k,
+				VersionId:    o.versionID,
+				IsLatest:     latest,
+				LastModified: isoTime(o.lastModified),
+				ETag:         o.etag,
+				Size:         len(o.data),
+				StorageClass: "STANDARD",
+			})
+		}
+	}
+	writeXML(w, http.StatusOK, res)
+}
@@ -0,0 +1,60 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Basics creates a bucket and round-trips a small object.
+var Basics = Scenario{Name: "basics", Run: runBasics}
+
+func runBasics(ctx context.Context, t *T) error {
+	t.Step("init client")
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+
+	prefix := common.Env("BUCKET_PREFIX", "smoketest")
+	objectKey := "hello.txt"
+	body := []byte("hello world\n")
+
+	printConfig(t, cfg)
+
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, client, prefix)
+	if err != nil {
+		return err
+	}
+	defer cleanupBucket(ctx, t, client, bucket)
+	printBucket(t, cfg, bucket)
+	t.Println("Created bucket")
+
+	t.Step("put object")
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: &objectKey, Body: common.BytesReader(body)})
+	if err != nil {
+		return fmt.Errorf("put object error: %w", err)
+	}
+	t.Printf("Put object: %s\n", objectKey)
+
+	t.Step("get object")
+	getOut, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &objectKey})
+	if err != nil {
+		return fmt.Errorf("get object error: %w", err)
+	}
+	data, err := common.ReadAll(getOut.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %w", err)
+	}
+	if string(data) != string(body) {
+		return Fail(2, "ERROR: content mismatch")
+	}
+	t.Printf("Got object: %s (%d bytes)\n", objectKey, len(data))
+
+	t.Println("basics test succeeded ✔")
+	return nil
+}
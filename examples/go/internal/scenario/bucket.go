@@ -0,0 +1,60 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Bucket exercises bucket create/head/list/delete.
+var Bucket = Scenario{Name: "bucket", Run: runBucket}
+
+func runBucket(ctx context.Context, t *T) error {
+	t.Step("init client")
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+
+	prefix := common.Env("BUCKET_PREFIX", "acs-bucket-test")
+
+	printConfig(t, cfg)
+
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, client, prefix)
+	if err != nil {
+		return err
+	}
+	defer cleanupBucket(ctx, t, client, bucket)
+	printBucket(t, cfg, bucket)
+	t.Println("Created bucket")
+
+	t.Step("head bucket")
+	if _, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &bucket}); err != nil {
+		return fmt.Errorf("head bucket error: %w", err)
+	}
+	t.Println("Head bucket OK")
+
+	t.Step("list buckets")
+	lb, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return fmt.Errorf("list buckets error: %w", err)
+	}
+	found := false
+	for _, b := range lb.Buckets {
+		if b.Name != nil && *b.Name == bucket {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return Fail(2, "ERROR: Created bucket not found in list_buckets()")
+	}
+	t.Println("List buckets contains created bucket")
+
+	t.Println("Bucket lifecycle test succeeded ✔")
+	return nil
+}
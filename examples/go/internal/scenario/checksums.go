@@ -0,0 +1,189 @@
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"s3setup/acs"
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Checksums uploads objects with each flexible checksum algorithm (CRC32,
+// CRC32C, SHA1, SHA256), with PutObject and as multipart uploads with a
+// composite checksum, and reports for each whether the endpoint stores the
+// checksum and returns it from HeadObject and GetObject, and whether a
+// download verifies against it. Every algorithm is tried before the scenario
+// fails.
+var Checksums = Scenario{Name: "checksums", Run: runChecksums}
+
+func runChecksums(ctx context.Context, t *T) error {
+	t.Step("init client")
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+
+	prefix := common.Env("BUCKET_PREFIX", "checksumtest")
+	small := []byte("hello flexible checksums\n")
+	large := bytes.Repeat([]byte("0123456789abcdef"), (2*common.MinPartSize+1024*1024)/16)
+
+	printConfig(t, cfg)
+
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, client, prefix)
+	if err != nil {
+		return err
+	}
+	defer cleanupBucket(ctx, t, client, bucket)
+	printBucket(t, cfg, bucket)
+	t.Println("Created bucket")
+
+	var failed []string
+	for _, alg := range acs.ChecksumAlgorithms {
+		t.Step(fmt.Sprintf("%s put object", alg))
+		if err := checkPutChecksum(ctx, t, client, bucket, alg, small); err != nil {
+			t.Printf("%s PutObject: ❌ %v\n", alg, err)
+			t.endStep(err)
+			failed = append(failed, string(alg)+" PutObject")
+		}
+
+		t.Step(fmt.Sprintf("%s multipart upload", alg))
+		if err := checkMultipartChecksum(ctx, t, client, bucket, alg, large); err != nil {
+			t.Printf("%s multipart: ❌ %v\n", alg, err)
+			t.endStep(err)
+			failed = append(failed, string(alg)+" multipart")
+		}
+	}
+	t.endStep(nil)
+
+	if len(failed) > 0 {
+		return Fail(2, "ERROR: Checksums not stored or returned correctly: %s", strings.Join(failed, ", "))
+	}
+	t.Println("Every checksum algorithm is stored and returned correctly")
+	return nil
+}
+
+// checkPutChecksum puts data with alg and checks the checksum in the PutObject
+// response, from HeadObject, and from GetObject, which the SDK validates
+// against the body.
+func checkPutChecksum(ctx context.Context, t *T, client *s3.Client, bucket string, alg types.ChecksumAlgorithm, data []byte) error {
+	key := "put-" + strings.ToLower(string(alg)) + ".txt"
+	want, err := common.Checksum(alg, data)
+	if err != nil {
+		return err
+	}
+	put, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: &key, Body: common.BytesReader(data), ChecksumAlgorithm: alg})
+	if err != nil {
+		return fmt.Errorf("put object error: %w", err)
+	}
+	if got := checksumField(alg, put.ChecksumCRC32, put.ChecksumCRC32C, put.ChecksumSHA1, put.ChecksumSHA256); got != "" && got != want {
+		return fmt.Errorf("PutObject returned %s %s, want %s", alg, got, want)
+	}
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: &key, ChecksumMode: types.ChecksumModeEnabled})
+	if err != nil {
+		return fmt.Errorf("head object error: %w", err)
+	}
+	switch got := checksumField(alg, head.ChecksumCRC32, head.ChecksumCRC32C, head.ChecksumSHA1, head.ChecksumSHA256); {
+	case got == "":
+		return fmt.Errorf("not stored: HeadObject returns no %s checksum", alg)
+	case got != want:
+		return fmt.Errorf("HeadObject returned %s %s, want %s", alg, got, want)
+	}
+
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key, ChecksumMode: types.ChecksumModeEnabled})
+	if err != nil {
+		return fmt.Errorf("get object error: %w", err)
+	}
+	body, err := common.ReadAll(get.Body)
+	if err != nil {
+		return fmt.Errorf("read body error (checksum validation): %w", err)
+	}
+	if !bytes.Equal(body, data) {
+		return fmt.Errorf("GetObject returned different content")
+	}
+	if got := checksumField(alg, get.ChecksumCRC32, get.ChecksumCRC32C, get.ChecksumSHA1, get.ChecksumSHA256); got != want {
+		return fmt.Errorf("GetObject returned %s %q, want %s", alg, got, want)
+	}
+	t.Printf("%s PutObject: ✅ %s stored, returned by HeadObject and GetObject\n", alg, want)
+	return nil
+}
+
+// checkMultipartChecksum uploads data in parts with alg, checks the composite
+// checksum against one computed locally, and downloads the object, verifying
+// it against the composite checksum.
+func checkMultipartChecksum(ctx context.Context, t *T, client *s3.Client, bucket string, alg types.ChecksumAlgorithm, data []byte) error {
+	key := "multipart-" + strings.ToLower(string(alg)) + ".bin"
+	uploader := common.NewUploader(client, func(u *common.Uploader) {
+		u.PartSize = common.MinPartSize
+		u.ChecksumAlgorithm = alg
+	})
+	res, err := uploader.Upload(ctx, &common.UploadInput{Bucket: bucket, Key: key, Body: bytes.NewReader(data), Size: int64(len(data))})
+	if err != nil {
+		return err
+	}
+	var parts []string
+	for off := 0; off < len(data); off += common.MinPartSize {
+		part, err := common.Checksum(alg, data[off:min(off+common.MinPartSize, len(data))])
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+	}
+	want, err := common.CompositeChecksum(alg, parts)
+	if err != nil {
+		return err
+	}
+	switch {
+	case res.Checksum == "":
+		return fmt.Errorf("CompleteMultipartUpload returned no %s checksum", alg)
+	case res.Checksum != want:
+		return fmt.Errorf("CompleteMultipartUpload returned %s %s, want %s", alg, res.Checksum, want)
+	}
+
+	downloader := common.NewDownloader(client)
+	obj, err := downloader.Stat(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if got := obj.Checksums[string(alg)]; got != want {
+		return fmt.Errorf("HeadObject returned %s %q, want %s", alg, got, want)
+	}
+	f, err := os.CreateTemp("", "acs-checksums-*.bin")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	verified, err := downloader.Download(ctx, f, obj, nil)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(verified, string(alg)) {
+		return fmt.Errorf("download verified by %q, not the %s checksum (part boundaries not reported?)", verified, alg)
+	}
+	t.Printf("%s multipart: ✅ %s stored (%d parts), download verified by %s\n", alg, want, res.Parts, verified)
+	return nil
+}
+
+// checksumField picks alg's value among a response's Checksum<ALG> fields.
+func checksumField(alg types.ChecksumAlgorithm, crc32, crc32c, sha1, sha256 *string) string {
+	switch alg {
+	case types.ChecksumAlgorithmCrc32:
+		return aws.ToString(crc32)
+	case types.ChecksumAlgorithmCrc32c:
+		return aws.ToString(crc32c)
+	case types.ChecksumAlgorithmSha1:
+		return aws.ToString(sha1)
+	case types.ChecksumAlgorithmSha256:
+		return aws.ToString(sha256)
+	}
+	return ""
+}
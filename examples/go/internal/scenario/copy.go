@@ -0,0 +1,72 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Copy copies an object within a bucket and verifies the copy.
+var Copy = Scenario{Name: "copy", Run: runCopy}
+
+func runCopy(ctx context.Context, t *T) error {
+	t.Step("init client")
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+
+	prefix := common.Env("BUCKET_PREFIX", "copytest")
+	srcKey := "src/hello.txt"
+	dstKey := "dst/hello-copy.txt"
+	body := []byte("hello copy api\n")
+
+	printConfig(t, cfg)
+
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, client, prefix)
+	if err != nil {
+		return err
+	}
+	defer cleanupBucket(ctx, t, client, bucket)
+	printBucket(t, cfg, bucket)
+	t.Println("Created bucket")
+
+	t.Step("put source object")
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: &srcKey, Body: common.BytesReader(body), ContentType: aws.String("text/plain")}); err != nil {
+		return fmt.Errorf("put src object error: %w", err)
+	}
+	t.Println("Put source object")
+
+	t.Step("copy object")
+	src := s3.CopyObjectInput{
+		Bucket:     &bucket,
+		Key:        &dstKey,
+		CopySource: aws.String(common.CopySource(bucket, srcKey, "")),
+	}
+	if _, err := client.CopyObject(ctx, &src); err != nil {
+		return fmt.Errorf("copy object error: %w", err)
+	}
+	t.Println("Copied object")
+
+	t.Step("verify copy")
+	g, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &dstKey})
+	if err != nil {
+		return fmt.Errorf("get dst object error: %w", err)
+	}
+	data, err := common.ReadAll(g.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %w", err)
+	}
+	if string(data) != string(body) {
+		return Fail(2, "ERROR: Copied object content mismatch")
+	}
+	t.Println("Copy verification OK")
+
+	t.Println("Copy object test succeeded ✔")
+	return nil
+}
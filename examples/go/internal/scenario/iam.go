@@ -0,0 +1,214 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+)
+
+// IAM runs the access key lifecycle with a bucket-scoped policy.
+var IAM = Scenario{Name: "iam", Run: runIAM}
+
+func runIAM(ctx context.Context, t *T) error {
+	t.Step("init client")
+	clients, cfg, err := common.NewClients(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+	iamClient, s3Client := clients.IAM, clients.S3
+
+	t.Printf("Using endpoint: %s\n", cfg.IAMEndpoint)
+	t.Printf("Region:        %s\n", cfg.IAMRegion)
+	if cfg.Profile != "" {
+		t.Printf("Profile:       %s\n", cfg.Profile)
+	}
+	if cfg.Endpoint != cfg.IAMEndpoint {
+		t.Printf("S3 endpoint:   %s\n", cfg.Endpoint)
+	}
+	t.Println("User:          [current IAM identity]")
+
+	var accessKeyID *string
+	var userName *string
+	var policyArn *string
+	var attached bool
+	var bucketName *string
+
+	// Cleanup function
+	defer func() {
+		ctx := context.WithoutCancel(ctx)
+		if policyArn != nil {
+			if attached {
+				if _, err := iamClient.DetachUserPolicy(ctx, &iam.DetachUserPolicyInput{
+					UserName:  userName,
+					PolicyArn: policyArn,
+				}); err != nil {
+					t.Printf("Cleanup incomplete: detach policy %s error: %v\n", *policyArn, err)
+				}
+			}
+			if _, err := iamClient.DeletePolicy(ctx, &iam.DeletePolicyInput{PolicyArn: policyArn}); err != nil {
+				t.Printf("Cleanup incomplete: delete policy %s error: %v\n", *policyArn, err)
+			}
+		}
+		if accessKeyID != nil {
+			if _, err := iamClient.DeleteAccessKey(ctx, &iam.DeleteAccessKeyInput{AccessKeyId: accessKeyID}); err != nil {
+				t.Printf("Cleanup incomplete: delete access key %s error: %v\n", *accessKeyID, err)
+			}
+		}
+		if bucketName != nil {
+			cleanupBucket(ctx, t, s3Client, *bucketName)
+		}
+	}()
+
+	// Create a test bucket
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, s3Client, "iam-policy-test")
+	if err != nil {
+		return err
+	}
+	bucketName = aws.String(bucket)
+	t.Printf("Created test bucket: %s\n", *bucketName)
+
+	// Create access key
+	t.Step("create access key")
+	created, err := iamClient.CreateAccessKey(ctx, &iam.CreateAccessKeyInput{})
+	if err != nil || created.AccessKey == nil || created.AccessKey.AccessKeyId == nil {
+		return fmt.Errorf("create access key error: %v", err)
+	}
+	accessKeyID = created.AccessKey.AccessKeyId
+	// For policy attachment, UserName parameter should be the access key ID
+	userName = accessKeyID
+	t.Printf("Created access key: %s****\n", (*accessKeyID)[:4])
+
+	// List access keys to verify
+	t.Step("list access keys")
+	listed, err := iamClient.ListAccessKeys(ctx, &iam.ListAccessKeysInput{})
+	if err != nil {
+		return fmt.Errorf("list access keys error: %w", err)
+	}
+	found := false
+	for _, meta := range listed.AccessKeyMetadata {
+		if meta.AccessKeyId != nil && *meta.AccessKeyId == *accessKeyID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return Fail(2, "ERROR: Created access key not found in list_access_keys")
+	}
+	t.Println("Listed access keys (found created key)")
+
+	// Create a policy document limiting access to the specific bucket
+	t.Step("create policy")
+	policyDocument := fmt.Sprintf(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": "s3:*",
+      "Resource": [
+        "arn:aws:s3:::%s",
+        "arn:aws:s3:::%s/*"
+      ]
+    }
+  ]
+}`, *bucketName, *bucketName)
+
+	policyName := fmt.Sprintf("S3BucketPolicy-%s", uuid.New().String())
+	createPolicyResp, err := iamClient.CreatePolicy(ctx, &iam.CreatePolicyInput{
+		PolicyName:     aws.String(policyName),
+		PolicyDocument: aws.String(policyDocument),
+		Description:    aws.String(fmt.Sprintf("Allow all S3 operations on bucket %s", *bucketName)),
+	})
+	if err != nil || createPolicyResp.Policy == nil || createPolicyResp.Policy.Arn == nil {
+		return Fail(3, "create policy error: %v", err)
+	}
+	policyArn = createPolicyResp.Policy.Arn
+	t.Printf("Created policy: %s\n", policyName)
+	t.Printf("Policy ARN: %s\n", *policyArn)
+
+	// Attach the policy to the access key (user)
+	t.Step("attach user policy")
+	if _, err := iamClient.AttachUserPolicy(ctx, &iam.AttachUserPolicyInput{
+		UserName:  userName,
+		PolicyArn: policyArn,
+	}); err != nil {
+		return fmt.Errorf("attach user policy error: %w", err)
+	}
+	attached = true
+	t.Println("Attached policy to access key (user)")
+
+	// List attached policies to verify
+	t.Step("list attached user policies")
+	attachedPolicies, err := iamClient.ListAttachedUserPolicies(ctx, &iam.ListAttachedUserPoliciesInput{
+		UserName: userName,
+	})
+	if err != nil {
+		return fmt.Errorf("list attached user policies error: %w", err)
+	}
+	policyFound := false
+	for _, policy := range attachedPolicies.AttachedPolicies {
+		if policy.PolicyArn != nil && *policy.PolicyArn == *policyArn {
+			policyFound = true
+			break
+		}
+	}
+	if !policyFound {
+		return Fail(4, "ERROR: Policy not found in attached policies")
+	}
+	t.Println("Verified policy attachment")
+
+	// Update access key status
+	t.Step("update access key")
+	if _, err := iamClient.UpdateAccessKey(ctx, &iam.UpdateAccessKeyInput{
+		AccessKeyId: accessKeyID,
+		Status:      iamtypes.StatusTypeInactive,
+	}); err != nil {
+		return fmt.Errorf("update access key error: %w", err)
+	}
+	t.Println("Updated access key to inactive")
+
+	// Detach the policy before deleting the access key
+	t.Step("detach user policy")
+	if _, err := iamClient.DetachUserPolicy(ctx, &iam.DetachUserPolicyInput{
+		UserName:  userName,
+		PolicyArn: policyArn,
+	}); err != nil {
+		return fmt.Errorf("detach user policy error: %w", err)
+	}
+	attached = false
+	t.Println("Detached policy from access key (user)")
+
+	// Delete the policy
+	t.Step("delete policy")
+	if _, err := iamClient.DeletePolicy(ctx, &iam.DeletePolicyInput{PolicyArn: policyArn}); err != nil {
+		return fmt.Errorf("delete policy error: %w", err)
+	}
+	t.Println("Deleted policy")
+	policyArn = nil
+
+	// Delete the access key
+	t.Step("delete access key")
+	if _, err := iamClient.DeleteAccessKey(ctx, &iam.DeleteAccessKeyInput{AccessKeyId: accessKeyID}); err != nil {
+		return fmt.Errorf("delete access key error: %w", err)
+	}
+	t.Println("Deleted access key")
+	accessKeyID = nil
+
+	// Delete the test bucket
+	t.Step("delete bucket")
+	if _, err := s3Client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: bucketName}); err != nil {
+		return fmt.Errorf("delete bucket error: %w", err)
+	}
+	t.Printf("Deleted test bucket: %s\n", *bucketName)
+	bucketName = nil
+
+	t.Println("IAM access key and policy test succeeded ✔")
+	return nil
+}
@@ -0,0 +1,122 @@
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Multipart uploads a 5 MiB + 2 MiB object in two parts and verifies it.
+var Multipart = Scenario{Name: "multipart", Run: runMultipart}
+
+func runMultipart(ctx context.Context, t *T) error {
+	t.Step("init client")
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+
+	prefix := common.Env("BUCKET_PREFIX", "mpuploadtest")
+	key := "large/data.bin"
+
+	part1 := bytes.Repeat([]byte("a"), 5*1024*1024)
+	part2 := bytes.Repeat([]byte("b"), 2*1024*1024)
+	totalLen := len(part1) + len(part2)
+
+	printConfig(t, cfg)
+
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, client, prefix)
+	if err != nil {
+		return err
+	}
+	var uploadID *string
+	defer func() {
+		if uploadID != nil {
+			if _, err := client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{Bucket: &bucket, Key: &key, UploadId: uploadID}); err != nil {
+				t.Printf("Cleanup incomplete: abort upload %s error: %v\n", *uploadID, err)
+			}
+		}
+		cleanupBucket(ctx, t, client, bucket)
+	}()
+	printBucket(t, cfg, bucket)
+	t.Println("Created bucket")
+
+	t.Step("create multipart upload")
+	initOut, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: &bucket, Key: &key, ContentType: aws.String("application/octet-stream")})
+	if err != nil || initOut.UploadId == nil {
+		return fmt.Errorf("init MPU error: %v", err)
+	}
+	uploadID = initOut.UploadId
+	t.Printf("Initiated MPU: %s\n", *uploadID)
+
+	t.Step("upload part 1")
+	up1, err := client.UploadPart(ctx, &s3.UploadPartInput{Bucket: &bucket, Key: &key, PartNumber: aws.Int32(1), UploadId: uploadID, Body: bytes.NewReader(part1)})
+	if err != nil || up1.ETag == nil {
+		return fmt.Errorf("upload part1 error: %v", err)
+	}
+	t.Println("Uploaded part 1")
+
+	t.Step("upload part 2")
+	up2, err := client.UploadPart(ctx, &s3.UploadPartInput{Bucket: &bucket, Key: &key, PartNumber: aws.Int32(2), UploadId: uploadID, Body: bytes.NewReader(part2)})
+	if err != nil || up2.ETag == nil {
+		return fmt.Errorf("upload part2 error: %v", err)
+	}
+	t.Println("Uploaded part 2")
+
+	t.Step("complete multipart upload")
+	comp, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   &bucket,
+		Key:      &key,
+		UploadId: uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: []types.CompletedPart{
+				common.CompletedPart(1, up1),
+				common.CompletedPart(2, up2),
+			},
+		},
+	})
+	if err == nil {
+		// A completed upload is gone; there is nothing left to abort.
+		uploadID = nil
+	}
+	if err != nil || comp.ETag == nil {
+		return fmt.Errorf("complete MPU error: %v", err)
+	}
+	t.Println("Completed MPU")
+
+	// Verify
+	t.Step("verify object")
+	h, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil || h.ContentLength == nil || int(*h.ContentLength) != totalLen {
+		return Fail(2, "ERROR: Head size mismatch after MPU")
+	}
+
+	g, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return fmt.Errorf("get object error: %w", err)
+	}
+	data, err := common.ReadAll(g.Body)
+	if err != nil || len(data) != totalLen {
+		return Fail(2, "ERROR: Retrieved content mismatch after MPU")
+	}
+	for i := 0; i < len(part1); i++ {
+		if data[i] != 'a' {
+			return Fail(2, "ERROR: Retrieved content mismatch after MPU")
+		}
+	}
+	for i := 0; i < len(part2); i++ {
+		if data[len(part1)+i] != 'b' {
+			return Fail(2, "ERROR: Retrieved content mismatch after MPU")
+		}
+	}
+
+	t.Println("Multipart upload test succeeded ✔")
+	return nil
+}
@@ -0,0 +1,84 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Object exercises object put/head/get/list.
+var Object = Scenario{Name: "object", Run: runObject}
+
+func runObject(ctx context.Context, t *T) error {
+	t.Step("init client")
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+
+	prefix := common.Env("BUCKET_PREFIX", "objecttest")
+	key := "folder/hello.txt"
+	body := []byte("hello object api\n")
+
+	printConfig(t, cfg)
+
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, client, prefix)
+	if err != nil {
+		return err
+	}
+	defer cleanupBucket(ctx, t, client, bucket)
+	printBucket(t, cfg, bucket)
+	t.Println("Created bucket")
+
+	t.Step("put object")
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: &key, Body: common.BytesReader(body), ContentType: aws.String("text/plain")}); err != nil {
+		return fmt.Errorf("put object error: %w", err)
+	}
+	t.Println("Put object")
+
+	t.Step("head object")
+	if _, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: &key}); err != nil {
+		return fmt.Errorf("head object error: %w", err)
+	}
+	t.Println("Head object OK")
+
+	t.Step("get object")
+	getOut, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return fmt.Errorf("get object error: %w", err)
+	}
+	data, err := common.ReadAll(getOut.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %w", err)
+	}
+	if string(data) != string(body) {
+		return Fail(2, "ERROR: Get object content mismatch")
+	}
+	t.Println("Get object OK")
+
+	// List with prefix
+	t.Step("list objects v2")
+	out, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: &bucket, Prefix: aws.String("folder/")})
+	if err != nil {
+		return fmt.Errorf("list objects v2 error: %w", err)
+	}
+	present := false
+	for _, o := range out.Contents {
+		if o.Key != nil && *o.Key == key {
+			present = true
+			break
+		}
+	}
+	if !present {
+		return Fail(2, "ERROR: Object not found in list_objects_v2")
+	}
+	t.Println("ListObjectsV2 OK")
+
+	t.Println("Object CRUD test succeeded ✔")
+	return nil
+}
@@ -0,0 +1,269 @@
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Presign presigns GET, HEAD, PUT and DELETE URLs and UploadPart URLs for a
+// multipart upload, and uses each with a plain net/http client that has no
+// credentials. It then checks that the endpoint rejects a URL after it expires
+// and URLs whose signature, key or expiry were tampered with.
+var Presign = Scenario{Name: "presign", Run: runPresign}
+
+func runPresign(ctx context.Context, t *T) error {
+	t.Step("init client")
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+
+	prefix := common.Env("BUCKET_PREFIX", "presigntest")
+	key := "shared/hello.txt"
+	body := []byte("hello presigned urls\n")
+	httpClient := &http.Client{Timeout: time.Minute}
+	presigner := common.NewPresigner(client, func(p *common.Presigner) { p.Expires = 5 * time.Minute })
+
+	printConfig(t, cfg)
+
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, client, prefix)
+	if err != nil {
+		return err
+	}
+	defer cleanupBucket(ctx, t, client, bucket)
+	printBucket(t, cfg, bucket)
+	t.Println("Created bucket")
+
+	t.Step("put object")
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: &key, Body: common.BytesReader(body)}); err != nil {
+		return fmt.Errorf("put object error: %w", err)
+	}
+	t.Println("Put object")
+
+	t.Step("presigned get")
+	get, err := presigner.GetObject(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	resp, data, err := doPresigned(ctx, httpClient, get, nil, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(data, body) {
+		return Fail(2, "ERROR: Presigned GET returned HTTP %d with %q", resp.StatusCode, data)
+	}
+	t.Printf("Presigned GET OK (expires %s)\n", get.Expires.Format(time.RFC3339))
+
+	t.Step("presigned head")
+	head, err := presigner.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	resp, _, err = doPresigned(ctx, httpClient, head, nil, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+		return Fail(2, "ERROR: Presigned HEAD returned HTTP %d, Content-Length %q", resp.StatusCode, resp.Header.Get("Content-Length"))
+	}
+	t.Println("Presigned HEAD OK")
+
+	t.Step("presigned put")
+	putKey := "shared/uploaded.txt"
+	uploaded := []byte("uploaded without credentials\n")
+	put, err := presigner.PutObject(ctx, bucket, putKey)
+	if err != nil {
+		return err
+	}
+	resp, data, err = doPresigned(ctx, httpClient, put, uploaded, "text/plain")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Fail(2, "ERROR: Presigned PUT returned HTTP %d: %s", resp.StatusCode, data)
+	}
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &putKey})
+	if err != nil {
+		return fmt.Errorf("get object error: %w", err)
+	}
+	got, err := common.ReadAll(out.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %w", err)
+	}
+	if !bytes.Equal(got, uploaded) || aws.ToString(out.ContentType) != "text/plain" {
+		return Fail(2, "ERROR: Presigned PUT stored %q with Content-Type %q", got, aws.ToString(out.ContentType))
+	}
+	t.Println("Presigned PUT OK")
+
+	t.Step("presigned multipart upload")
+	parts, err := presignedMultipart(ctx, client, presigner, httpClient, bucket, "shared/multipart.bin")
+	if err != nil {
+		return err
+	}
+	t.Printf("Presigned UploadPart OK (%d parts, completed)\n", parts)
+
+	t.Step("tampered urls")
+	for _, tamper := range []struct {
+		what string
+		fn   func(*url.URL)
+	}{
+		{"signature", func(u *url.URL) {
+			q := u.Query()
+			sig := []byte(q.Get("X-Amz-Signature"))
+			sig[len(sig)-1] ^= 1
+			q.Set("X-Amz-Signature", string(sig))
+			u.RawQuery = q.Encode()
+		}},
+		{"key", func(u *url.URL) {
+			u.Path += ".other"
+			u.RawPath = ""
+		}},
+		{"expiry", func(u *url.URL) {
+			q := u.Query()
+			q.Set("X-Amz-Expires", "604800")
+			u.RawQuery = q.Encode()
+		}},
+	} {
+		u, err := url.Parse(get.URL)
+		if err != nil {
+			return err
+		}
+		tamper.fn(u)
+		bad := *get
+		bad.URL = u.String()
+		resp, _, err := doPresigned(ctx, httpClient, &bad, nil, "")
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusForbidden {
+			return Fail(2, "ERROR: Presigned GET with a tampered %s returned HTTP %d, want 403", tamper.what, resp.StatusCode)
+		}
+		t.Printf("Tampered %s rejected (403)\n", tamper.what)
+	}
+
+	t.Step("expired url")
+	short, err := common.NewPresigner(client, func(p *common.Presigner) { p.Expires = time.Second }).GetObject(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-time.After(time.Until(short.Expires.Add(time.Second))):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	resp, _, err = doPresigned(ctx, httpClient, short, nil, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		return Fail(2, "ERROR: Expired presigned GET returned HTTP %d, want 403", resp.StatusCode)
+	}
+	t.Println("Expired URL rejected (403)")
+
+	t.Step("presigned delete")
+	del, err := presigner.DeleteObject(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	resp, data, err = doPresigned(ctx, httpClient, del, nil, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return Fail(2, "ERROR: Presigned DELETE returned HTTP %d: %s", resp.StatusCode, data)
+	}
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: &key})
+	var notFound *types.NotFound
+	if !errors.As(err, &notFound) {
+		return Fail(2, "ERROR: Object still present after presigned DELETE (head: %v)", err)
+	}
+	t.Println("Presigned DELETE OK")
+
+	t.Println("Presigned URL test succeeded ✔")
+	return nil
+}
+
+// presignedMultipart starts a multipart upload, uploads a MinPartSize part
+// and a small last part through presigned UploadPart URLs, completes it with
+// the returned ETags and checks the object, returning the part count.
+func presignedMultipart(ctx context.Context, client *s3.Client, presigner *common.Presigner, httpClient *http.Client, bucket, key string) (int, error) {
+	uploadID, err := presigner.CreateMultipartUpload(ctx, bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	data := bytes.Repeat([]byte("presigned part. "), (common.MinPartSize+64*1024)/16)
+	var completed []types.CompletedPart
+	for off, n := 0, int32(1); off < len(data); off, n = off+common.MinPartSize, n+1 {
+		part, err := presigner.UploadPart(ctx, bucket, key, uploadID, n)
+		if err != nil {
+			return 0, err
+		}
+		resp, body, err := doPresigned(ctx, httpClient, part, data[off:min(off+common.MinPartSize, len(data))], "")
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == "" {
+			_, _ = client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{Bucket: &bucket, Key: &key, UploadId: &uploadID})
+			return 0, Fail(2, "ERROR: Presigned UploadPart %d returned HTTP %d: %s", n, resp.StatusCode, body)
+		}
+		completed = append(completed, types.CompletedPart{ETag: aws.String(resp.Header.Get("ETag")), PartNumber: aws.Int32(n)})
+	}
+	if _, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket: &bucket, Key: &key, UploadId: &uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	}); err != nil {
+		return 0, fmt.Errorf("complete multipart upload error: %w", err)
+	}
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return 0, fmt.Errorf("get object error: %w", err)
+	}
+	got, err := common.ReadAll(out.Body)
+	if err != nil {
+		return 0, fmt.Errorf("read body error: %w", err)
+	}
+	if !bytes.Equal(got, data) {
+		return 0, Fail(2, "ERROR: Object uploaded with presigned parts differs (%d bytes, want %d)", len(got), len(data))
+	}
+	return len(completed), nil
+}
+
+// doPresigned sends req with net/http and no credentials, returning the
+// response and its body. A non-empty contentType is sent as an unsigned header.
+func doPresigned(ctx context.Context, client *http.Client, req *common.PresignedRequest, body []byte, contentType string) (*http.Response, []byte, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	httpReq, err := req.NewRequest(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("presigned %s error: %w", req.Method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("presigned %s error: %w", req.Method, err)
+	}
+	return resp, data, nil
+}
@@ -0,0 +1,205 @@
+package scenario
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Outcomes reported for scenarios and steps. A step that fails with exit code 1
+// hit an operation error; any other non-zero code is a verification failure.
+const (
+	OutcomePassed = "passed"
+	OutcomeFailed = "failed"
+	OutcomeError  = "error"
+)
+
+func outcome(err error, code int) string {
+	switch {
+	case err == nil:
+		return OutcomePassed
+	case code == 1:
+		return OutcomeError
+	default:
+		return OutcomeFailed
+	}
+}
+
+type jsonReport struct {
+	Suite     string         `json:"suite"`
+	Timestamp time.Time      `json:"timestamp"`
+	Passed    int            `json:"passed"`
+	Failed    int            `json:"failed"`
+	Scenarios []jsonScenario `json:"scenarios"`
+}
+
+type jsonScenario struct {
+	Name       string     `json:"name"`
+	Outcome    string     `json:"outcome"`
+	ExitCode   int        `json:"exit_code"`
+	DurationMS int64      `json:"duration_ms"`
+	Error      string     `json:"error,omitempty"`
+	Steps      []jsonStep `json:"steps"`
+}
+
+type jsonStep struct {
+	Name       string `json:"name"`
+	Outcome    string `json:"outcome"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+func errText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// WriteJSON writes results as a JSON document with per-step outcomes.
+func WriteJSON(w io.Writer, suite string, results []Result) error {
+	rep := jsonReport{Suite: suite, Timestamp: time.Now().UTC(), Scenarios: []jsonScenario{}}
+	for _, r := range results {
+		if r.Passed() {
+			rep.Passed++
+		} else {
+			rep.Failed++
+		}
+		js := jsonScenario{
+			Name:       r.Name,
+			Outcome:    outcome(r.Err, r.Code),
+			ExitCode:   r.Code,
+			DurationMS: r.Duration.Milliseconds(),
+			Error:      errText(r.Err),
+			Steps:      []jsonStep{},
+		}
+		for _, s := range r.Steps {
+			js.Steps = append(js.Steps, jsonStep{
+				Name:       s.Name,
+				Outcome:    outcome(s.Err, s.Code),
+				DurationMS: s.Duration.Milliseconds(),
+				Error:      errText(s.Err),
+			})
+		}
+		rep.Scenarios = append(rep.Scenarios, js)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string { return fmt.Sprintf("%.3f", d.Seconds()) }
+
+// WriteJUnit writes results as JUnit XML: one testsuite per scenario and one testcase per step.
+// Verification failures are reported as <failure>, operation errors as <error>.
+func WriteJUnit(w io.Writer, suite string, results []Result) error {
+	doc := junitTestSuites{Name: suite}
+	var total time.Duration
+	for _, r := range results {
+		ts := junitTestSuite{
+			Name:      r.Name,
+			Time:      seconds(r.Duration),
+			Timestamp: r.Started.UTC().Format("2006-01-02T15:04:05"),
+			SystemOut: r.Output,
+		}
+		steps := r.Steps
+		if len(steps) == 0 {
+			steps = []StepResult{{Name: r.Name, Err: r.Err, Code: r.Code, Duration: r.Duration}}
+		}
+		for _, s := range steps {
+			tc := junitTestCase{Name: s.Name, Classname: suite + "." + r.Name, Time: seconds(s.Duration)}
+			switch outcome(s.Err, s.Code) {
+			case OutcomeFailed:
+				tc.Failure = &junitProblem{Message: s.Err.Error(), Type: fmt.Sprintf("exit%d", s.Code), Text: s.Err.Error()}
+				ts.Failures++
+			case OutcomeError:
+				tc.Error = &junitProblem{Message: s.Err.Error(), Type: "error", Text: s.Err.Error()}
+				ts.Errors++
+			}
+			ts.Cases = append(ts.Cases, tc)
+			ts.Tests++
+		}
+		doc.Tests += ts.Tests
+		doc.Failures += ts.Failures
+		doc.Errors += ts.Errors
+		total += r.Duration
+		doc.Suites = append(doc.Suites, ts)
+	}
+	doc.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteReports writes the JUnit and JSON reports to the given paths; an empty path skips that format.
+func WriteReports(junitPath, jsonPath, suite string, results []Result) error {
+	if junitPath != "" {
+		if err := writeFile(junitPath, func(w io.Writer) error { return WriteJUnit(w, suite, results) }); err != nil {
+			return err
+		}
+	}
+	if jsonPath != "" {
+		if err := writeFile(jsonPath, func(w io.Writer) error { return WriteJSON(w, suite, results) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
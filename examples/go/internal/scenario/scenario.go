@@ -0,0 +1,186 @@
+// Package scenario holds the setup guides as reusable scenarios, so each one can
+// run standalone from its cmd program or together under cmd/acs-suite.
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Scenario is a named setup guide. Run reports progress through t and returns
+// nil on success; deferred cleanup inside Run always executes.
+type Scenario struct {
+	Name string
+	Run  func(ctx context.Context, t *T) error
+}
+
+// T carries the output stream and step log of a running scenario.
+type T struct {
+	name  string
+	out   io.Writer
+	steps []StepResult
+	step  string
+	start time.Time
+}
+
+func (t *T) Name() string { return t.name }
+
+func (t *T) Printf(format string, args ...any) { fmt.Fprintf(t.out, format, args...) }
+
+func (t *T) Println(args ...any) { fmt.Fprintln(t.out, args...) }
+
+// Step marks the start of a named step and records the previous one as passed.
+// The step in progress when the scenario returns is recorded with its error.
+func (t *T) Step(name string) {
+	t.endStep(nil)
+	t.step = name
+	t.start = time.Now()
+}
+
+func (t *T) endStep(err error) {
+	if t.step == "" {
+		return
+	}
+	t.steps = append(t.steps, StepResult{Name: t.step, Err: err, Code: ExitCode(err), Duration: time.Since(t.start)})
+	t.step = ""
+}
+
+// Failure is a scenario error that carries the exit code the standalone program reports for it.
+// Plain errors map to exit code 1.
+type Failure struct {
+	Code int
+	Msg  string
+}
+
+func (f *Failure) Error() string { return f.Msg }
+
+// Fail returns a Failure with the given exit code and formatted message.
+func Fail(code int, format string, args ...any) error {
+	return &Failure{Code: code, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ExitCode maps a scenario error to a process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var f *Failure
+	if errors.As(err, &f) {
+		return f.Code
+	}
+	return 1
+}
+
+// Result is the outcome of one scenario run.
+type Result struct {
+	Name     string
+	Err      error
+	Code     int
+	Started  time.Time
+	Duration time.Duration
+	Steps    []StepResult
+	// Output is the captured progress output; it is empty when the run streamed to a writer.
+	Output string
+}
+
+func (r Result) Passed() bool { return r.Err == nil }
+
+// StepResult is the outcome of one step within a scenario.
+type StepResult struct {
+	Name     string
+	Err      error
+	Code     int
+	Duration time.Duration
+}
+
+func (r StepResult) Passed() bool { return r.Err == nil }
+
+// Run executes s, streaming its progress output to out.
+func Run(ctx context.Context, s Scenario, out io.Writer) Result {
+	start := time.Now()
+	t := &T{name: s.Name, out: out}
+	err := s.Run(ctx, t)
+	t.endStep(err)
+	return Result{Name: s.Name, Err: err, Code: ExitCode(err), Started: start, Duration: time.Since(start), Steps: t.steps}
+}
+
+// RunCaptured executes s and returns its progress output in Result.Output, for
+// runs whose output must not interleave with others.
+func RunCaptured(ctx context.Context, s Scenario) Result {
+	var buf bytes.Buffer
+	res := Run(ctx, s, &buf)
+	res.Output = buf.String()
+	return res
+}
+
+// Main runs s as a standalone program and returns the process exit code.
+// The -junit and -json flags write machine-readable results for the run.
+func Main(s Scenario) int {
+	junitPath := flag.String("junit", common.Env("REPORT_JUNIT", ""), "write JUnit XML results to this file")
+	jsonPath := flag.String("json", common.Env("REPORT_JSON", ""), "write JSON results to this file")
+	flag.Parse()
+
+	res := Run(context.Background(), s, os.Stdout)
+	if res.Err != nil {
+		fmt.Fprintln(os.Stderr, res.Err)
+	}
+	if err := WriteReports(*junitPath, *jsonPath, "acs-setup", []Result{res}); err != nil {
+		fmt.Fprintf(os.Stderr, "report error: %v\n", err)
+		if res.Code == 0 {
+			return 1
+		}
+	}
+	return res.Code
+}
+
+// All returns every registered scenario in suite order.
+func All() []Scenario {
+	return []Scenario{Basics, Bucket, Object, Copy, CrossCopy, Multipart, Checksums, Signing, Presign, Versioning, IAM}
+}
+
+// cleanupBucket empties and deletes bucket when a scenario ends, printing what
+// it could not remove. It runs even if ctx was cancelled.
+func cleanupBucket(ctx context.Context, t *T, client *s3.Client, bucket string) {
+	report, err := common.EmptyAndDeleteBucket(context.WithoutCancel(ctx), client, bucket)
+	if err == nil {
+		return
+	}
+	t.Printf("Cleanup incomplete: %s\n", report)
+	for _, f := range report.Failures {
+		t.Printf("  - %v\n", f)
+	}
+}
+
+// printConfig prints the resolved connection settings at the start of an S3 scenario.
+func printConfig(t *T, cfg common.ConfigValues) {
+	t.Printf("Using endpoint: %s\n", cfg.Endpoint)
+	t.Printf("Region:        %s\n", cfg.Region)
+	if cfg.Profile != "" {
+		t.Printf("Profile:       %s\n", cfg.Profile)
+	}
+}
+
+// printBucket prints the name of the scenario's bucket and how it is addressed.
+func printBucket(t *T, cfg common.ConfigValues, bucket string) {
+	t.Printf("Bucket:        %s\n", bucket)
+	t.Printf("Addressing:    %s\n", addressing(cfg, bucket))
+}
+
+// addressing formats the configured addressing style, adding the style actually
+// used for bucket when the two differ (e.g. "auto (path)").
+func addressing(cfg common.ConfigValues, bucket string) string {
+	if used := cfg.StyleFor(bucket); used != cfg.AddressingStyle {
+		return fmt.Sprintf("%s (%s)", cfg.AddressingStyle, used)
+	}
+	return cfg.AddressingStyle
+}
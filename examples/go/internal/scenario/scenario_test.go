@@ -0,0 +1,52 @@
+package scenario_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"s3setup/internal/fakeiam"
+	"s3setup/internal/fakes3"
+	"s3setup/internal/scenario"
+)
+
+// TestAllAgainstFakes runs every acs-suite scenario against the in-memory S3
+// and IAM fakes, set up like fakeacs.
+func TestAllAgainstFakes(t *testing.T) {
+	iamh := fakeiam.NewHandler()
+	iamSrv := httptest.NewServer(iamh)
+	defer iamSrv.Close()
+	s3Srv := fakes3.NewServer(func(h *fakes3.Handler) {
+		h.SecretKey = iamh.SecretKeys("test")
+	})
+	defer s3Srv.Close()
+
+	for k, v := range map[string]string{
+		"S3_ENDPOINT":           s3Srv.URL,
+		"IAM_ENDPOINT":          iamSrv.URL,
+		"AWS_ACCESS_KEY_ID":     "test",
+		"AWS_SECRET_ACCESS_KEY": "test",
+		"AWS_SESSION_TOKEN":     "",
+		"AWS_PROFILE":           "",
+		"ACS_PROFILE":           "",
+		"ACS_CONFIG_FILE":       filepath.Join(t.TempDir(), "none"),
+		"S3_ADDRESSING_STYLE":   "path",
+		"S3_CHECKSUM_ALGORITHM": "",
+		"S3_PAYLOAD_SIGNING":    "",
+		"BUCKET_PREFIX":         "",
+	} {
+		t.Setenv(k, v)
+	}
+
+	for _, s := range scenario.All() {
+		t.Run(s.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			res := scenario.Run(context.Background(), s, &out)
+			if !res.Passed() {
+				t.Errorf("%s failed (exit %d): %v\n%s", s.Name, res.Code, res.Err, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"s3setup/acs"
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Signing uploads with each payload signing mode (signed SHA-256,
+// UNSIGNED-PAYLOAD, and aws-chunked streaming with a trailing checksum), with
+// PutObject and as a multipart upload, and reports for each whether the
+// endpoint accepts the requests and stores the content and CRC32C checksum
+// intact. S3-compatible endpoints differ here, streaming most of all. Every
+// mode is tried before the scenario fails.
+var Signing = Scenario{Name: "signing", Run: runSigning}
+
+func runSigning(ctx context.Context, t *T) error {
+	t.Step("init client")
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+
+	prefix := common.Env("BUCKET_PREFIX", "signingtest")
+	small := []byte("hello payload signing\n")
+	large := bytes.Repeat([]byte("0123456789abcdef"), (2*common.MinPartSize+1024*1024)/16)
+
+	printConfig(t, cfg)
+	if cfg.PayloadSigning != "" {
+		t.Printf("Payload signing: %s (overridden per mode below)\n", cfg.PayloadSigning)
+	}
+
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, client, prefix)
+	if err != nil {
+		return err
+	}
+	defer cleanupBucket(ctx, t, client, bucket)
+	printBucket(t, cfg, bucket)
+	t.Println("Created bucket")
+
+	var failed []string
+	for _, mode := range acs.PayloadSigningModes {
+		sent := &sentPayloads{}
+		modeClient := s3.New(client.Options(), acs.UsePayloadSigning(mode), sent.record)
+
+		t.Step(fmt.Sprintf("%s put object", mode))
+		if err := checkSignedPut(ctx, t, modeClient, sent, bucket, mode, small); err != nil {
+			t.Printf("%s PutObject: ❌ %v\n", mode, err)
+			t.endStep(err)
+			failed = append(failed, string(mode)+" PutObject")
+		}
+
+		t.Step(fmt.Sprintf("%s multipart upload", mode))
+		if err := checkSignedMultipart(ctx, t, modeClient, sent, bucket, mode, large); err != nil {
+			t.Printf("%s multipart: ❌ %v\n", mode, err)
+			t.endStep(err)
+			failed = append(failed, string(mode)+" multipart")
+		}
+	}
+	t.endStep(nil)
+
+	if len(failed) > 0 {
+		return Fail(2, "ERROR: Payload signing modes not accepted: %s", strings.Join(failed, ", "))
+	}
+	t.Println("Every payload signing mode is accepted")
+	return nil
+}
+
+// checkSignedPut puts data with a CRC32C checksum and reads it back, checking
+// the request was signed as mode asks and the content and checksum arrived.
+func checkSignedPut(ctx context.Context, t *T, client *s3.Client, sent *sentPayloads, bucket string, mode acs.PayloadSigning, data []byte) error {
+	key := "put-" + string(mode) + ".txt"
+	sent.reset()
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: &key, Body: common.BytesReader(data), ChecksumAlgorithm: types.ChecksumAlgorithmCrc32c}); err != nil {
+		return fmt.Errorf("put object error: %w", err)
+	}
+	hashes, err := sent.check(mode)
+	if err != nil {
+		return err
+	}
+	if err := checkStored(ctx, client, bucket, key, data); err != nil {
+		return err
+	}
+	t.Printf("%s PutObject: ✅ accepted (x-amz-content-sha256: %s), content and CRC32C intact\n", mode, hashes)
+	return nil
+}
+
+// checkSignedMultipart uploads data in parts with a CRC32C checksum, checking
+// every UploadPart was signed as mode asks and the object arrived intact.
+func checkSignedMultipart(ctx context.Context, t *T, client *s3.Client, sent *sentPayloads, bucket string, mode acs.PayloadSigning, data []byte) error {
+	key := "multipart-" + string(mode) + ".bin"
+	sent.reset()
+	uploader := common.NewUploader(client, func(u *common.Uploader) {
+		u.PartSize = common.MinPartSize
+		u.ChecksumAlgorithm = types.ChecksumAlgorithmCrc32c
+	})
+	res, err := uploader.Upload(ctx, &common.UploadInput{Bucket: bucket, Key: key, Body: bytes.NewReader(data), Size: int64(len(data))})
+	if err != nil {
+		return err
+	}
+	hashes, err := sent.check(mode)
+	if err != nil {
+		return err
+	}
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return fmt.Errorf("get object error: %w", err)
+	}
+	body, err := common.ReadAll(get.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %w", err)
+	}
+	if !bytes.Equal(body, data) {
+		return fmt.Errorf("GetObject returned different content")
+	}
+	t.Printf("%s multipart: ✅ %d parts accepted (x-amz-content-sha256: %s), content intact\n", mode, res.Parts, hashes)
+	return nil
+}
+
+// checkStored reads key back, comparing its content and stored CRC32C checksum with data.
+func checkStored(ctx context.Context, client *s3.Client, bucket, key string, data []byte) error {
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key, ChecksumMode: types.ChecksumModeEnabled})
+	if err != nil {
+		return fmt.Errorf("get object error: %w", err)
+	}
+	body, err := common.ReadAll(get.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %w", err)
+	}
+	if !bytes.Equal(body, data) {
+		return fmt.Errorf("GetObject returned different content")
+	}
+	want, err := common.Checksum(types.ChecksumAlgorithmCrc32c, data)
+	if err != nil {
+		return err
+	}
+	if got := checksumField(types.ChecksumAlgorithmCrc32c, get.ChecksumCRC32, get.ChecksumCRC32C, get.ChecksumSHA1, get.ChecksumSHA256); got != want {
+		return fmt.Errorf("GetObject returned CRC32C %q, want %s", got, want)
+	}
+	return nil
+}
+
+// sentPayloads records the x-amz-content-sha256 of the PutObject and
+// UploadPart requests a client sends, as signed.
+type sentPayloads struct {
+	mu     sync.Mutex
+	hashes []string
+}
+
+func (s *sentPayloads) record(o *s3.Options) {
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		if stack.ID() != "PutObject" && stack.ID() != "UploadPart" {
+			return nil
+		}
+		return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("RecordPayloadHash", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if req, ok := in.Request.(*smithyhttp.Request); ok {
+				s.mu.Lock()
+				s.hashes = append(s.hashes, req.Header.Get("X-Amz-Content-Sha256"))
+				s.mu.Unlock()
+			}
+			return next.HandleFinalize(ctx, in)
+		}), middleware.After)
+	})
+}
+
+func (s *sentPayloads) reset() {
+	s.mu.Lock()
+	s.hashes = nil
+	s.mu.Unlock()
+}
+
+// check confirms every recorded request was signed as mode asks, returning
+// the distinct payload hashes sent (a SHA-256 shown as "<sha256>").
+func (s *sentPayloads) check(mode acs.PayloadSigning) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var seen []string
+	for _, h := range s.hashes {
+		var ok bool
+		label := h
+		switch mode {
+		case acs.SignedPayload:
+			ok = len(h) == 64 && !strings.HasPrefix(h, "STREAMING-")
+			label = "<sha256>"
+		case acs.UnsignedPayload:
+			ok = h == "UNSIGNED-PAYLOAD"
+		case acs.StreamingPayload:
+			ok = h == "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+		}
+		if !ok {
+			return "", fmt.Errorf("request sent with x-amz-content-sha256 %q, not %s", h, mode)
+		}
+		if !slices.Contains(seen, label) {
+			seen = append(seen, label)
+		}
+	}
+	if len(seen) == 0 {
+		return "", fmt.Errorf("no upload requests recorded")
+	}
+	return strings.Join(seen, ", "), nil
+}
@@ -0,0 +1,151 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"s3setup/internal/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Versioning exercises bucket versioning: several versions of one key, reading a
+// specific version, and adding and removing a delete marker.
+var Versioning = Scenario{Name: "versioning", Run: runVersioning}
+
+func runVersioning(ctx context.Context, t *T) error {
+	t.Step("init client")
+	client, cfg, err := common.NewS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+
+	prefix := common.Env("BUCKET_PREFIX", "versiontest")
+	key := "versioned/doc.txt"
+	bodies := []string{"version 1\n", "version 2\n", "version 3\n"}
+
+	printConfig(t, cfg)
+
+	t.Step("create bucket")
+	bucket, err := common.CreateTestBucket(ctx, client, prefix)
+	if err != nil {
+		return err
+	}
+	defer cleanupBucket(ctx, t, client, bucket)
+	printBucket(t, cfg, bucket)
+	t.Println("Created bucket")
+
+	t.Step("enable versioning")
+	if _, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  &bucket,
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	}); err != nil {
+		return fmt.Errorf("put bucket versioning error: %w", err)
+	}
+	vOut, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: &bucket})
+	if err != nil {
+		return fmt.Errorf("get bucket versioning error: %w", err)
+	}
+	if vOut.Status != types.BucketVersioningStatusEnabled {
+		return Fail(2, "ERROR: Versioning status is %q after enabling", vOut.Status)
+	}
+	t.Println("Versioning enabled")
+
+	t.Step("put versions")
+	var versionIDs []string
+	for _, body := range bodies {
+		out, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: &bucket, Key: &key, Body: common.BytesReader([]byte(body))})
+		if err != nil {
+			return fmt.Errorf("put object error: %w", err)
+		}
+		id := aws.ToString(out.VersionId)
+		if id == "" || id == "null" {
+			return Fail(2, "ERROR: PutObject returned version ID %q in a versioned bucket", id)
+		}
+		versionIDs = append(versionIDs, id)
+	}
+	t.Printf("Put %d versions\n", len(versionIDs))
+
+	// Versions are listed newest first.
+	t.Step("list object versions")
+	lOut, err := client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: &bucket, Prefix: &key})
+	if err != nil {
+		return fmt.Errorf("list object versions error: %w", err)
+	}
+	if len(lOut.Versions) != len(versionIDs) {
+		return Fail(2, "ERROR: Listed %d versions, want %d", len(lOut.Versions), len(versionIDs))
+	}
+	for i, v := range lOut.Versions {
+		want := versionIDs[len(versionIDs)-1-i]
+		if aws.ToString(v.VersionId) != want || aws.ToBool(v.IsLatest) != (i == 0) {
+			return Fail(2, "ERROR: Version %d is %s (latest=%t), want %s", i, aws.ToString(v.VersionId), aws.ToBool(v.IsLatest), want)
+		}
+	}
+	t.Println("ListObjectVersions OK")
+
+	t.Step("get specific version")
+	getOut, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key, VersionId: &versionIDs[0]})
+	if err != nil {
+		return fmt.Errorf("get object version error: %w", err)
+	}
+	data, err := common.ReadAll(getOut.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %w", err)
+	}
+	if string(data) != bodies[0] {
+		return Fail(2, "ERROR: Version %s content mismatch", versionIDs[0])
+	}
+	t.Println("Get first version OK")
+
+	t.Step("create delete marker")
+	dOut, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return fmt.Errorf("delete object error: %w", err)
+	}
+	markerID := aws.ToString(dOut.VersionId)
+	if !aws.ToBool(dOut.DeleteMarker) || markerID == "" {
+		return Fail(2, "ERROR: DeleteObject did not create a delete marker")
+	}
+	_, err = client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	var noSuchKey *types.NoSuchKey
+	if !errors.As(err, &noSuchKey) {
+		return Fail(2, "ERROR: Get behind a delete marker returned %v, want NoSuchKey", err)
+	}
+	t.Println("Delete marker hides the object")
+
+	t.Step("remove delete marker")
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &bucket, Key: &key, VersionId: &markerID}); err != nil {
+		return fmt.Errorf("delete marker removal error: %w", err)
+	}
+	getOut, err = client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return fmt.Errorf("get object error: %w", err)
+	}
+	data, err = common.ReadAll(getOut.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %w", err)
+	}
+	if string(data) != bodies[len(bodies)-1] {
+		return Fail(2, "ERROR: Latest version not restored after removing the delete marker")
+	}
+	t.Println("Removed delete marker; latest version restored")
+
+	t.Step("purge versions")
+	removed, err := common.DeleteAllVersions(ctx, client, bucket)
+	if err != nil {
+		return err
+	}
+	if removed != len(versionIDs) {
+		return Fail(2, "ERROR: Purged %d versions, want %d", removed, len(versionIDs))
+	}
+	if _, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: &bucket}); err != nil {
+		return fmt.Errorf("delete bucket error: %w", err)
+	}
+	t.Printf("Purged %d versions and deleted bucket\n", removed)
+
+	t.Println("Versioning test succeeded ✔")
+	return nil
+}